@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/go-chi/chi/v5 v5.0.8
+	github.com/gorilla/websocket v1.5.0
 	github.com/jackc/pgx v3.6.2+incompatible
 	github.com/o1egl/paseto v1.0.0
 	github.com/stretchr/testify v1.8.3
@@ -0,0 +1,5 @@
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS completed,
+    DROP COLUMN IF EXISTS completed_at,
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS priority;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS completed    boolean   NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS completed_at timestamp NULL,
+    ADD COLUMN IF NOT EXISTS due_at       timestamp NULL,
+    ADD COLUMN IF NOT EXISTS priority     smallint  NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 3);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS todos_user_created_idx, todos_user_updated_idx, todos_user_due_idx, todos_user_priority_idx;
\ No newline at end of file
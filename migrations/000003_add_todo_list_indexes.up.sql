@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS todos_user_created_idx ON todos (user_id, created_at, todo_id);
+
+CREATE INDEX IF NOT EXISTS todos_user_updated_idx ON todos (user_id, updated_at, todo_id);
+
+CREATE INDEX IF NOT EXISTS todos_user_due_idx ON todos (user_id, (coalesce(due_at, 'infinity'::timestamp)), todo_id);
+
+CREATE INDEX IF NOT EXISTS todos_user_priority_idx ON todos (user_id, priority, todo_id);
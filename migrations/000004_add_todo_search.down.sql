@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS todos_search_idx, todos_title_trgm_idx, todos_content_trgm_idx;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS search;
+
+DROP EXTENSION IF EXISTS pg_trgm;
\ No newline at end of file
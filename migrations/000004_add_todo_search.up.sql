@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS todos_search_idx ON todos USING gin (search);
+
+CREATE INDEX IF NOT EXISTS todos_title_trgm_idx ON todos USING gin (title gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS todos_content_trgm_idx ON todos USING gin (content gin_trgm_ops);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS todo_tags, tags;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS tags
+(
+    tag_id     bigserial NOT NULL,
+    user_id    bigint    NOT NULL,
+    name       text      NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tag_id),
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS todo_tags
+(
+    todo_id bigint NOT NULL,
+    tag_id  bigint NOT NULL,
+    PRIMARY KEY (todo_id, tag_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (todo_id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags (tag_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_tags_tag_idx ON todo_tags (tag_id);
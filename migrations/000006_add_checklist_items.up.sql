@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS checklist_items
+(
+    item_id    bigserial NOT NULL,
+    todo_id    bigint    NOT NULL,
+    title      text      NOT NULL,
+    done       boolean   NOT NULL DEFAULT false,
+    position   int       NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    updated_at timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (item_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (todo_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS checklist_items_todo_idx ON checklist_items (todo_id, position);
@@ -0,0 +1,4 @@
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS list_id;
+
+DROP TABLE IF EXISTS lists;
\ No newline at end of file
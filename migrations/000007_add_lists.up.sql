@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS lists
+(
+    list_id     bigserial NOT NULL,
+    user_id     bigint    NOT NULL,
+    name        text      NOT NULL,
+    archived_at timestamp NULL,
+    created_at  timestamp NOT NULL DEFAULT NOW(),
+    updated_at  timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id),
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS list_id bigint NULL REFERENCES lists (list_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS todos_list_idx ON todos (list_id);
@@ -0,0 +1,6 @@
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS recurrence_next_id,
+    DROP COLUMN IF EXISTS recurrence_index,
+    DROP COLUMN IF EXISTS recurrence_start,
+    DROP COLUMN IF EXISTS recurrence_tz,
+    DROP COLUMN IF EXISTS recurrence_rule;
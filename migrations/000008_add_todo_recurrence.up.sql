@@ -0,0 +1,6 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS recurrence_rule    text      NULL,
+    ADD COLUMN IF NOT EXISTS recurrence_tz      text      NULL,
+    ADD COLUMN IF NOT EXISTS recurrence_start   timestamp NULL,
+    ADD COLUMN IF NOT EXISTS recurrence_index   integer   NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS recurrence_next_id bigint    NULL REFERENCES todos (todo_id) ON DELETE SET NULL;
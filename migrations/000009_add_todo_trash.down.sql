@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todos_trash_idx;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp NULL;
+
+CREATE INDEX IF NOT EXISTS todos_trash_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS todo_revisions
+(
+    revision_id bigserial NOT NULL,
+    todo_id     bigint    NOT NULL,
+    user_id     bigint    NULL,
+    action      text      NOT NULL,
+    old_title   text      NULL,
+    new_title   text      NULL,
+    old_content text      NULL,
+    new_content text      NULL,
+    created_at  timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (revision_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (todo_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS todo_revisions_todo_idx ON todo_revisions (todo_id, revision_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    scope        text      NOT NULL,
+    key          text      NOT NULL,
+    request_hash text      NOT NULL,
+    status       int       NULL,
+    headers      text      NULL,
+    body         bytea     NULL,
+    created_at   timestamp NOT NULL DEFAULT NOW(),
+    expires_at   timestamp NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_sync_idx;
+
+DROP TABLE IF EXISTS todo_tombstones;
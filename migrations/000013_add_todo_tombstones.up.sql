@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS todo_tombstones
+(
+    todo_id    bigint    NOT NULL,
+    user_id    bigint    NOT NULL,
+    deleted_at timestamp NOT NULL,
+    PRIMARY KEY (todo_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_tombstones_sync_idx ON todo_tombstones (user_id, deleted_at, todo_id);
+
+CREATE INDEX IF NOT EXISTS todos_sync_idx ON todos (user_id, updated_at, todo_id);
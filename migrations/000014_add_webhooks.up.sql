@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    webhook_id bigserial NOT NULL,
+    user_id    bigint    NOT NULL,
+    url        text      NOT NULL,
+    secret     text      NOT NULL,
+    events     text[]    NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (webhook_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_idx ON webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    delivery_id      bigserial NOT NULL,
+    webhook_id       bigint    NOT NULL,
+    event            text      NOT NULL,
+    payload          text      NOT NULL,
+    status           text      NOT NULL DEFAULT 'pending',
+    attempts         int       NOT NULL DEFAULT 0,
+    next_attempt_at  timestamp NOT NULL,
+    last_status_code int       NULL,
+    last_error       text      NULL,
+    created_at       timestamp NOT NULL DEFAULT NOW(),
+    delivered_at     timestamp NULL,
+    PRIMARY KEY (delivery_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (webhook_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_log_idx ON webhook_deliveries (webhook_id, delivery_id);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_queue_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
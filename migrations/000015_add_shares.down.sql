@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS list_shares;
+
+DROP TABLE IF EXISTS todo_shares;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS todo_shares
+(
+    todo_id    bigint    NOT NULL,
+    user_id    bigint    NOT NULL,
+    role       text      NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (todo_id, user_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (todo_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_shares_user_idx ON todo_shares (user_id);
+
+CREATE TABLE IF NOT EXISTS list_shares
+(
+    list_id    bigint    NOT NULL,
+    user_id    bigint    NOT NULL,
+    role       text      NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id, user_id),
+    FOREIGN KEY (list_id) REFERENCES lists (list_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS list_shares_user_idx ON list_shares (user_id);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS todo_comments;
+
+DROP INDEX IF EXISTS todos_assignee_idx;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS assignee_id;
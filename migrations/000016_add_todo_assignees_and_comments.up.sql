@@ -0,0 +1,20 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS assignee_id bigint NULL REFERENCES users (user_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS todos_assignee_idx ON todos (assignee_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS todo_comments
+(
+    comment_id bigserial NOT NULL,
+    todo_id    bigint    NOT NULL,
+    user_id    bigint    NOT NULL,
+    body       text      NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    updated_at timestamp NOT NULL DEFAULT NOW(),
+    edited_at  timestamp NULL,
+    PRIMARY KEY (comment_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (todo_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_comments_todo_idx ON todo_comments (todo_id, comment_id);
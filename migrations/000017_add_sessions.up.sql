@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+    session_id bigserial NOT NULL,
+    user_id    bigint    NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    expires_at timestamp NOT NULL,
+    revoked_at timestamp NULL,
+    PRIMARY KEY (session_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    token_hash text      NOT NULL,
+    session_id bigint    NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    expires_at timestamp NOT NULL,
+    used_at    timestamp NULL,
+    PRIMARY KEY (token_hash),
+    FOREIGN KEY (session_id) REFERENCES sessions (session_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (session_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS password_resets
+(
+    token_hash text      NOT NULL,
+    user_id    bigint    NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    expires_at timestamp NOT NULL,
+    used_at    timestamp NULL,
+    PRIMARY KEY (token_hash),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_idx ON password_resets (user_id);
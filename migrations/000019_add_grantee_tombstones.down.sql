@@ -0,0 +1,6 @@
+DELETE FROM todo_tombstones t USING todo_tombstones other
+WHERE t.todo_id = other.todo_id
+  AND (t.deleted_at < other.deleted_at OR (t.deleted_at = other.deleted_at AND t.user_id > other.user_id));
+
+ALTER TABLE todo_tombstones DROP CONSTRAINT IF EXISTS todo_tombstones_pkey;
+ALTER TABLE todo_tombstones ADD PRIMARY KEY (todo_id);
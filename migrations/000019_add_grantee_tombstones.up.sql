@@ -0,0 +1,2 @@
+ALTER TABLE todo_tombstones DROP CONSTRAINT IF EXISTS todo_tombstones_pkey;
+ALTER TABLE todo_tombstones ADD PRIMARY KEY (todo_id, user_id);
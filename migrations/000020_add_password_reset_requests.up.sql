@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS password_reset_requests
+(
+    request_id      bigserial NOT NULL,
+    email           text      NOT NULL,
+    ip              text      NOT NULL,
+    created_at      timestamp NOT NULL DEFAULT NOW(),
+    attempts        int       NOT NULL DEFAULT 0,
+    next_attempt_at timestamp NOT NULL DEFAULT NOW(),
+    last_error      text      NULL,
+    sent_at         timestamp NULL,
+    PRIMARY KEY (request_id)
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_requests_email_idx ON password_reset_requests (lower(btrim(email)), created_at);
+CREATE INDEX IF NOT EXISTS password_reset_requests_ip_idx ON password_reset_requests (ip, created_at);
+CREATE INDEX IF NOT EXISTS password_reset_requests_due_idx ON password_reset_requests (next_attempt_at) WHERE sent_at IS NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS todo_grants
+(
+    todo_id    bigint    NOT NULL,
+    user_id    bigint    NOT NULL,
+    granted_at timestamp NOT NULL,
+    PRIMARY KEY (todo_id, user_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (todo_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_grants_user_idx ON todo_grants (user_id, granted_at);
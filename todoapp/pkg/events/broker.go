@@ -0,0 +1,149 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/policy"
+	"log"
+	"sync"
+	"time"
+)
+
+// SubscriptionBuffer is how many events a subscriber can fall behind before it is dropped.
+const SubscriptionBuffer = 64
+
+// Subscription receives the events its filter matches on C. C is closed when the subscriber
+// falls behind or the broker loses its connection, since events may have been missed; the
+// subscriber should then read the todos again.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter func(Event) bool
+}
+
+// Broker listens for the events notified by every pod, and fans them out to the subscribers
+// of this pod.
+type Broker struct {
+	config pgx.ConnConfig
+
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewBroker(config pgx.ConnConfig) *Broker {
+	return &Broker{
+		config:        config,
+		subscriptions: map[*Subscription]struct{}{},
+	}
+}
+
+func (b *Broker) Subscribe(filter func(Event) bool) *Subscription {
+	ch := make(chan Event, SubscriptionBuffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions[s] = struct{}{}
+	return s
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(s)
+}
+
+// drop closes a subscription, and must be called with mu held.
+func (b *Broker) drop(s *Subscription) {
+	if _, ok := b.subscriptions[s]; ok {
+		delete(b.subscriptions, s)
+		close(s.ch)
+	}
+}
+
+func (b *Broker) publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscriptions {
+		if !s.filter(event) {
+			continue
+		}
+		select {
+		case s.ch <- event:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+func (b *Broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscriptions {
+		b.drop(s)
+	}
+}
+
+// Run listens on a dedicated connection until ctx is done, reconnecting when it is lost.
+func (b *Broker) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			b.dropAll()
+			return
+		}
+		log.Println("error listening for todo events: ", err.Error())
+		b.dropAll()
+
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(b.config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.Listen(Channel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event Event
+		err = json.Unmarshal([]byte(notification.Payload), &event)
+		if err != nil {
+			log.Println("invalid todo event: ", notification.Payload)
+			continue
+		}
+		// The grantees left out of the payload are read now, so the users who lost the todo
+		// when its list was deleted don't get the event.
+		if event.GranteesOmitted {
+			event.SharedWith, err = policy.TodoGrantees(conn, event.TodoID, event.ListID, event.FromListID)
+			if err != nil {
+				return err
+			}
+		}
+		b.publish(event)
+	}
+}
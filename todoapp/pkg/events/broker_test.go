@@ -0,0 +1,59 @@
+package events
+
+import (
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBroker(t *testing.T) {
+
+	t.Run("publish should only deliver the events a subscription matches", func(t *testing.T) {
+		b := NewBroker(pgx.ConnConfig{})
+		mine := b.Subscribe(func(e Event) bool { return e.UserID == 1 })
+		defer b.Unsubscribe(mine)
+
+		b.publish(Event{Type: TodoCreated, UserID: 2, TodoID: 10})
+		b.publish(Event{Type: TodoUpdated, UserID: 1, TodoID: 11})
+
+		require.Equal(t, Event{Type: TodoUpdated, UserID: 1, TodoID: 11}, <-mine.C)
+		require.Empty(t, mine.C)
+	})
+
+	t.Run("publish should deliver the events of shared todos to the grantees", func(t *testing.T) {
+		b := NewBroker(pgx.ConnConfig{})
+		grantee := b.Subscribe(func(e Event) bool { return e.VisibleTo(3) })
+		defer b.Unsubscribe(grantee)
+
+		b.publish(Event{Type: TodoUpdated, UserID: 1, TodoID: 10, SharedWith: []int{2}})
+		b.publish(Event{Type: TodoUpdated, UserID: 1, TodoID: 11, SharedWith: []int{2, 3}})
+
+		require.Equal(t, 11, (<-grantee.C).TodoID)
+		require.Empty(t, grantee.C)
+	})
+
+	t.Run("publish to a subscriber that fell behind should close it", func(t *testing.T) {
+		b := NewBroker(pgx.ConnConfig{})
+		slow := b.Subscribe(func(Event) bool { return true })
+		fast := b.Subscribe(func(Event) bool { return true })
+
+		for i := 0; i <= SubscriptionBuffer; i++ {
+			b.publish(Event{Type: TodoUpdated, UserID: 1, TodoID: i})
+			<-fast.C
+		}
+
+		received := 0
+		for range slow.C {
+			received++
+		}
+		require.Equal(t, SubscriptionBuffer, received)
+
+		b.publish(Event{Type: TodoDeleted, UserID: 1, TodoID: 1})
+		require.Equal(t, TodoDeleted, (<-fast.C).Type)
+
+		b.Unsubscribe(slow)
+		b.Unsubscribe(fast)
+		_, open := <-fast.C
+		require.False(t, open)
+	})
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/policy"
+)
+
+// Channel is the Postgres channel todo events are notified on.
+const Channel = "todo_events"
+
+const (
+	TodoCreated = "created"
+	TodoUpdated = "updated"
+	// TodoCompleted is the update of a todo that was not completed to completed.
+	TodoCompleted = "completed"
+	TodoDeleted   = "deleted"
+)
+
+// MaxNotifiedGrantees is how many users a todo can be shared with for the event of its changes
+// to carry them. Beyond it, the grantees would push the notification payload past what Postgres
+// accepts, so the listeners resolve them instead.
+const MaxNotifiedGrantees = 200
+
+// Event is a change of a todo. Only the fields identifying the todo are notified, since a
+// notification payload must stay under 8000 bytes. FromListID is the list a todo was moved out
+// of, if any, and Todo the JSON of the todo after the change, when the hooks were given it.
+// SharedWith are the other users the todo is shared with, as notified, unless GranteesOmitted is
+// set because there were more than MaxNotifiedGrantees of them.
+type Event struct {
+	Type            string          `json:"type"`
+	UserID          int             `json:"user_id"`
+	TodoID          int             `json:"todo_id"`
+	ListID          *int            `json:"list_id,omitempty"`
+	FromListID      *int            `json:"from_list_id,omitempty"`
+	Version         int             `json:"version"`
+	SharedWith      []int           `json:"shared_with,omitempty"`
+	GranteesOmitted bool            `json:"grantees_omitted,omitempty"`
+	Todo            json.RawMessage `json:"-"`
+}
+
+// withGrantees returns the event carrying the users the todo is shared with, or flagging them
+// as omitted when there are too many to notify.
+func (e Event) withGrantees(grantees []int) Event {
+	if len(grantees) > MaxNotifiedGrantees {
+		e.SharedWith, e.GranteesOmitted = nil, true
+	} else {
+		e.SharedWith, e.GranteesOmitted = grantees, false
+	}
+	return e
+}
+
+// VisibleTo reports whether userID owns the todo of the event or it is shared with them.
+func (e Event) VisibleTo(userID int) bool {
+	if e.UserID == userID {
+		return true
+	}
+	for _, id := range e.SharedWith {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Hook is told about the changes of todos inside the transaction that makes them, so that it
+// can act only if the transaction commits.
+type Hook interface {
+	TodosChanged(tx *pgx.Tx, events ...Event) error
+}
+
+// Notifier is the Hook that publishes events with NOTIFY, which Postgres delivers to the
+// listeners of every pod when the transaction commits, and drops when it rolls back. It adds the
+// users the todo is shared with, so that their streams get the event too.
+type Notifier struct{}
+
+func (Notifier) TodosChanged(tx *pgx.Tx, events ...Event) error {
+	for _, event := range events {
+		grantees, err := policy.TodoGrantees(tx, event.TodoID, event.ListID, event.FromListID)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(event.withGrantees(grantees))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`select pg_notify($1, $2)`, Channel, string(payload))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
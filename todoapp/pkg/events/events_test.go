@@ -0,0 +1,32 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWithGrantees(t *testing.T) {
+
+	t.Run("an event should carry the grantees up to the limit", func(t *testing.T) {
+		grantees := make([]int, MaxNotifiedGrantees)
+		for i := range grantees {
+			grantees[i] = 2147483647 - i
+		}
+		listID := 2147483647
+		event := Event{Type: TodoUpdated, UserID: 2147483647, TodoID: 2147483647, ListID: &listID, FromListID: &listID, Version: 2147483647}.withGrantees(grantees)
+		require.Equal(t, grantees, event.SharedWith)
+		require.False(t, event.GranteesOmitted)
+
+		payload, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.Less(t, len(payload), 8000)
+	})
+
+	t.Run("an event with more grantees than the limit should leave them out", func(t *testing.T) {
+		grantees := make([]int, MaxNotifiedGrantees+1)
+		event := Event{Type: TodoUpdated, UserID: 1, TodoID: 10}.withGrantees(grantees)
+		require.Nil(t, event.SharedWith)
+		require.True(t, event.GranteesOmitted)
+	})
+}
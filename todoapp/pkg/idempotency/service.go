@@ -0,0 +1,145 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DefaultTTL is how long responses are kept for replay when no TTL is configured.
+const DefaultTTL = 24 * time.Hour
+
+const MaxKeyLength = 255
+
+var ErrInvalidKey = errors.New("Idempotency-Key must be 1 to 255 characters")
+
+// Record is the first request made with an idempotency key, and its response once it has one.
+type Record struct {
+	RequestHash string
+	Done        bool
+	Status      int
+	Headers     http.Header
+	Body        []byte
+}
+
+type Service interface {
+	// Claim reserves a key of a scope for a request. It returns nil when the key was free, or the
+	// record of the request that claimed it first otherwise.
+	Claim(ctx context.Context, scope string, key string, requestHash string) (*Record, error)
+	// Complete stores the response of the request that claimed a key.
+	Complete(ctx context.Context, scope string, key string, status int, headers http.Header, body []byte) error
+	// Release frees a key whose request failed, so that it can be retried.
+	Release(ctx context.Context, scope string, key string) error
+}
+
+type ServiceImpl struct {
+	conn *pgx.ConnPool
+	ttl  time.Duration
+}
+
+func NewServiceImpl(conn *pgx.ConnPool, ttl time.Duration) *ServiceImpl {
+	return &ServiceImpl{
+		conn: conn,
+		ttl:  ttl,
+	}
+}
+
+func (s ServiceImpl) Claim(ctx context.Context, scope string, key string, requestHash string) (*Record, error) {
+
+	if key == "" || len(key) > MaxKeyLength {
+		return nil, ErrInvalidKey
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`delete from idempotency_keys where scope = $1 and key = $2 and expires_at < now()`, scope, key)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `insert into idempotency_keys(scope, key, request_hash, expires_at) values ($1, $2, $3, now() + $4 * interval '1 second')
+		on conflict (scope, key) do nothing`
+	tag, err := tx.Exec(query, scope, key, requestHash, int64(s.ttl.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, tx.Commit()
+	}
+
+	var record Record
+	var status *int
+	var headers *string
+	query = `select request_hash, status, headers, body from idempotency_keys where scope = $1 and key = $2`
+	err = tx.QueryRow(query, scope, key).Scan(&record.RequestHash, &status, &headers, &record.Body)
+	if err != nil {
+		return nil, err
+	}
+	if status != nil {
+		record.Done = true
+		record.Status = *status
+	}
+	if headers != nil {
+		err = json.Unmarshal([]byte(*headers), &record.Headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &record, tx.Commit()
+}
+
+func (s ServiceImpl) Complete(ctx context.Context, scope string, key string, status int, headers http.Header, body []byte) error {
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `update idempotency_keys set status = $1, headers = $2, body = $3 where scope = $4 and key = $5`
+	_, err = s.conn.Exec(query, status, string(encoded), body, scope, key)
+	return err
+}
+
+func (s ServiceImpl) Release(ctx context.Context, scope string, key string) error {
+	_, err := s.conn.Exec(`delete from idempotency_keys where scope = $1 and key = $2 and status is null`, scope, key)
+	return err
+}
+
+// PurgeExpired deletes the keys whose TTL is over, and returns how many were deleted.
+func (s ServiceImpl) PurgeExpired(ctx context.Context) (int64, error) {
+	tag, err := s.conn.Exec(`delete from idempotency_keys where expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RunPurger purges expired keys every interval until ctx is done.
+func RunPurger(ctx context.Context, s *ServiceImpl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeExpired(ctx)
+		if err != nil {
+			log.Println("error purging idempotency keys: ", err.Error())
+		} else if purged > 0 {
+			log.Printf("purged %v expired idempotency keys", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type ArchiveListHttpHandler struct {
+	Service Service
+}
+
+func NewArchiveListHttpHandler(s Service) *ArchiveListHttpHandler {
+	return &ArchiveListHttpHandler{Service: s}
+}
+
+func (h ArchiveListHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	listID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.Service.Archive(r.Context(), &ArchiveListCommand{UserID: userID, ListID: listID})
+	if errors.Is(err, ErrListNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseListDTO(list))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
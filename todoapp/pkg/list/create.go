@@ -0,0 +1,73 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+)
+
+type CreateListHttpHandler struct {
+	Service Service
+}
+
+func NewCreateListHttpHandler(s Service) *CreateListHttpHandler {
+	return &CreateListHttpHandler{Service: s}
+}
+
+type CreateListRequestDTO struct {
+	Name string `json:"name"`
+}
+
+func (h CreateListHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := CreateListRequestDTO{}
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.Unmarshal(bytes, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := CreateListCommand{
+		UserID: userID,
+		Name:   dto.Name,
+	}
+
+	list, err := h.Service.Create(r.Context(), &cmd)
+	if errors.Is(err, ErrInvalidName) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrNameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(newResponseListDTO(list))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,75 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type DeleteListHttpHandler struct {
+	Service Service
+}
+
+func NewDeleteListHttpHandler(s Service) *DeleteListHttpHandler {
+	return &DeleteListHttpHandler{Service: s}
+}
+
+// ServeHTTP deletes a list. The todos query parameter chooses between moving its todos,
+// to the list given by move_to or out of any list, and deleting them.
+func (h DeleteListHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	listID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := DeleteListCommand{
+		UserID: userID,
+		ListID: listID,
+		Todos:  r.URL.Query().Get("todos"),
+	}
+	if moveTo := r.URL.Query().Get("move_to"); moveTo != "" {
+		target, err := strconv.Atoi(moveTo)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("move_to must be a list id"))
+			return
+		}
+		cmd.MoveTo = &target
+	}
+
+	list, err := h.Service.Delete(r.Context(), &cmd)
+	if errors.Is(err, ErrListNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidTodos) || errors.Is(err, ErrInvalidMoveTarget) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseListDTO(list))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
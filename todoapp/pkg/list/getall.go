@@ -0,0 +1,78 @@
+package list
+
+import (
+	"encoding/json"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"time"
+)
+
+type GetAllListsHttpHandler struct {
+	Service Service
+}
+
+func NewGetAllListsHttpHandler(s Service) *GetAllListsHttpHandler {
+	return &GetAllListsHttpHandler{Service: s}
+}
+
+type GetAllResponseDTO struct {
+	Lists []ResponseListDTO `json:"lists"`
+}
+
+type ResponseListDTO struct {
+	ListID     int        `json:"list_id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	TodoCount  int        `json:"todo_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func newResponseListDTO(list *List) ResponseListDTO {
+	return ResponseListDTO{
+		ListID:     list.ListID,
+		Name:       list.Name,
+		Role:       list.Role,
+		Archived:   list.ArchivedAt != nil,
+		ArchivedAt: list.ArchivedAt,
+		TodoCount:  list.TodoCount,
+		CreatedAt:  list.CreatedAt,
+		UpdatedAt:  list.UpdatedAt,
+	}
+}
+
+func (h GetAllListsHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := GetAllListsCommand{
+		UserID:          userID,
+		IncludeArchived: r.URL.Query().Get("archived") == "true",
+	}
+
+	lists, err := h.Service.GetAll(r.Context(), &cmd)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]ResponseListDTO, 0, len(lists))
+	for _, list := range lists {
+		res = append(res, newResponseListDTO(list))
+	}
+
+	bytes, err := json.Marshal(GetAllResponseDTO{Lists: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
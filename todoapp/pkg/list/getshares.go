@@ -0,0 +1,64 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+	"strconv"
+)
+
+type GetSharesHttpHandler struct {
+	Service ShareService
+}
+
+func NewGetSharesHttpHandler(s ShareService) *GetSharesHttpHandler {
+	return &GetSharesHttpHandler{Service: s}
+}
+
+func (h GetSharesHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	listID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	shares, err := h.Service.GetShares(r.Context(), &GetSharesCommand{UserID: userID, ListID: listID})
+	if errors.Is(err, ErrListNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]policy.ResponseShareDTO, 0, len(shares))
+	for _, share := range shares {
+		res = append(res, policy.NewResponseShareDTO(share))
+	}
+
+	bytes, err := json.Marshal(policy.GetSharesResponseDTO{Shares: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
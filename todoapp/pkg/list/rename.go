@@ -0,0 +1,87 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type RenameListHttpHandler struct {
+	Service Service
+}
+
+func NewRenameListHttpHandler(s Service) *RenameListHttpHandler {
+	return &RenameListHttpHandler{Service: s}
+}
+
+type RenameListRequestDTO struct {
+	Name string `json:"name"`
+}
+
+func (h RenameListHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := RenameListRequestDTO{}
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.Unmarshal(bytes, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	listID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := RenameListCommand{
+		UserID: userID,
+		ListID: listID,
+		Name:   dto.Name,
+	}
+
+	list, err := h.Service.Rename(r.Context(), &cmd)
+	if errors.Is(err, ErrListNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidName) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrNameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(newResponseListDTO(list))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
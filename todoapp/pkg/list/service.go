@@ -0,0 +1,339 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/policy"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const MaxNameLength = 100
+
+const (
+	// TodosMove moves the todos of a deleted list to another list, or out of any list.
+	TodosMove = "move"
+	// TodosDelete moves the todos of a deleted list to the trash.
+	TodosDelete = "delete"
+)
+
+var (
+	ErrListNotFound      = errors.New("list not found")
+	ErrListArchived      = errors.New("list is archived")
+	ErrInvalidName       = errors.New("list names must be 1 to 100 characters")
+	ErrNameTaken         = errors.New("a list with this name already exists")
+	ErrInvalidTodos      = errors.New("todos must be move or delete")
+	ErrInvalidMoveTarget = errors.New("move_to must be another active list of yours")
+)
+
+// List is a list of todos. Role is the role of the user reading it, since lists shared with
+// them are listed too.
+type List struct {
+	ListID     int
+	UserID     int
+	Role       string
+	Name       string
+	ArchivedAt *time.Time
+	TodoCount  int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type Service interface {
+	GetAll(ctx context.Context, cmd *GetAllListsCommand) ([]*List, error)
+	Create(ctx context.Context, cmd *CreateListCommand) (*List, error)
+	Rename(ctx context.Context, cmd *RenameListCommand) (*List, error)
+	Archive(ctx context.Context, cmd *ArchiveListCommand) (*List, error)
+	Unarchive(ctx context.Context, cmd *UnarchiveListCommand) (*List, error)
+	Delete(ctx context.Context, cmd *DeleteListCommand) (*List, error)
+}
+
+// GetAllListsCommand lists the lists of the user along with those shared with them.
+type GetAllListsCommand struct {
+	UserID          int
+	IncludeArchived bool
+}
+
+type CreateListCommand struct {
+	UserID int
+	Name   string
+}
+
+type RenameListCommand struct {
+	UserID int
+	ListID int
+	Name   string
+}
+
+type ArchiveListCommand struct {
+	UserID int
+	ListID int
+}
+
+type UnarchiveListCommand struct {
+	UserID int
+	ListID int
+}
+
+// DeleteListCommand deletes a list. Its todos are deleted with it or moved depending on
+// Todos, MoveTo being the destination list or nil to leave them without list.
+type DeleteListCommand struct {
+	UserID int
+	ListID int
+	Todos  string
+	MoveTo *int
+}
+
+// CheckWritable returns the owner of a list the user can add todos to: theirs, or one shared
+// with them as editor. It returns ErrListNotFound unless the list exists and the user can see it,
+// policy.ErrForbidden if they can only view it, and ErrListArchived if it can't receive todos.
+func CheckWritable(tx *pgx.Tx, userID int, listID int) (int, error) {
+	var ownerID int
+	var archivedAt *time.Time
+	err := tx.QueryRow(`select user_id, archived_at from lists where list_id = $1`, listID).Scan(&ownerID, &archivedAt)
+	if err == pgx.ErrNoRows {
+		return 0, ErrListNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	role, err := policy.ListRole(tx, userID, ownerID, listID)
+	if err != nil {
+		return 0, err
+	}
+	err = policy.Check(role, policy.PermissionEdit, ErrListNotFound)
+	if err != nil {
+		return 0, err
+	}
+	if archivedAt != nil {
+		return 0, ErrListArchived
+	}
+	return ownerID, nil
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// listColumns is the column list every query returning a List selects, in the order scanList expects.
+const listColumns = `list_id, user_id, name, archived_at,
+	(select count(*) from todos t where t.list_id = lists.list_id and t.deleted_at is null),
+	created_at, updated_at`
+
+// scanList scans the listColumns of a row, followed by any extra columns the query selected.
+// Lists are read by their owner unless the query selects another role.
+func scanList(row scanner, extra ...interface{}) (*List, error) {
+	list := List{Role: policy.RoleOwner}
+	dest := []interface{}{&list.ListID, &list.UserID, &list.Name, &list.ArchivedAt, &list.TodoCount, &list.CreatedAt, &list.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err == pgx.ErrNoRows {
+		return nil, ErrListNotFound
+	}
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23505" {
+		return nil, ErrNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type ServiceImpl struct {
+	conn  *pgx.ConnPool
+	hooks []events.Hook
+}
+
+func NewServiceImpl(conn *pgx.ConnPool) *ServiceImpl {
+	return &ServiceImpl{
+		conn: conn,
+	}
+}
+
+func (s ServiceImpl) GetAll(ctx context.Context, cmd *GetAllListsCommand) ([]*List, error) {
+
+	query := `select ` + listColumns + `,
+		case when user_id = $1 then 'owner' else (select role from list_shares s where s.list_id = lists.list_id and s.user_id = $1) end
+		from lists where ` + policy.VisibleLists("$1") + ` and ($2 or archived_at is null) order by name, list_id`
+
+	rows, err := s.conn.Query(query, cmd.UserID, cmd.IncludeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := make([]*List, 0)
+	for rows.Next() {
+		var role string
+		list, err := scanList(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		list.Role = role
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (s ServiceImpl) Create(ctx context.Context, cmd *CreateListCommand) (*List, error) {
+
+	name, err := normalizeName(cmd.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `insert into lists(user_id, name) values ($1, $2) returning ` + listColumns
+	return scanList(s.conn.QueryRow(query, cmd.UserID, name))
+}
+
+func (s ServiceImpl) Rename(ctx context.Context, cmd *RenameListCommand) (*List, error) {
+
+	name, err := normalizeName(cmd.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `update lists set name = $1, updated_at = $2 where list_id = $3 and user_id = $4 returning ` + listColumns
+	return scanList(s.conn.QueryRow(query, name, time.Now(), cmd.ListID, cmd.UserID))
+}
+
+func (s ServiceImpl) Archive(ctx context.Context, cmd *ArchiveListCommand) (*List, error) {
+
+	query := `update lists set archived_at = coalesce(archived_at, $1), updated_at = $1 where list_id = $2 and user_id = $3 returning ` + listColumns
+	return scanList(s.conn.QueryRow(query, time.Now(), cmd.ListID, cmd.UserID))
+}
+
+func (s ServiceImpl) Unarchive(ctx context.Context, cmd *UnarchiveListCommand) (*List, error) {
+
+	query := `update lists set archived_at = null, updated_at = $1 where list_id = $2 and user_id = $3 returning ` + listColumns
+	return scanList(s.conn.QueryRow(query, time.Now(), cmd.ListID, cmd.UserID))
+}
+
+func (s ServiceImpl) Delete(ctx context.Context, cmd *DeleteListCommand) (*List, error) {
+
+	action := cmd.Todos
+	if action == "" {
+		action = TodosMove
+	}
+	if action != TodosMove && action != TodosDelete {
+		return nil, ErrInvalidTodos
+	}
+	if action == TodosMove && cmd.MoveTo != nil && *cmd.MoveTo == cmd.ListID {
+		return nil, ErrInvalidMoveTarget
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	list, err := scanList(tx.QueryRow(`select `+listColumns+` from lists where list_id = $1 and user_id = $2 for update`, cmd.ListID, cmd.UserID))
+	if err != nil {
+		return nil, err
+	}
+	todoIDs, err := listTodoIDs(tx, cmd.ListID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case TodosDelete:
+		_, err = tx.Exec(`insert into todo_revisions(todo_id, user_id, action, old_title, old_content)
+			select todo_id, $1, 'delete', title, content from todos where list_id = $2 and deleted_at is null`, cmd.UserID, cmd.ListID)
+		if err != nil {
+			return nil, err
+		}
+		query := `update todos set version = version + 1, deleted_at = $1, updated_at = $1 where list_id = $2 and deleted_at is null
+			returning user_id, todo_id, list_id, version`
+		err = s.todosChanged(tx, events.TodoDeleted, nil, query, time.Now(), cmd.ListID)
+	case TodosMove:
+		if cmd.MoveTo != nil {
+			// The todos stay the user's, so they can only move to a list of theirs.
+			ownerID, err := CheckWritable(tx, cmd.UserID, *cmd.MoveTo)
+			if errors.Is(err, ErrListNotFound) || errors.Is(err, ErrListArchived) || errors.Is(err, policy.ErrForbidden) ||
+				(err == nil && ownerID != cmd.UserID) {
+				return nil, ErrInvalidMoveTarget
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		query := `update todos set version = version + 1, list_id = $1, updated_at = $2 where list_id = $3 and deleted_at is null
+			returning user_id, todo_id, list_id, version`
+		err = s.todosChanged(tx, events.TodoUpdated, &cmd.ListID, query, cmd.MoveTo, time.Now(), cmd.ListID)
+		if err != nil {
+			return nil, err
+		}
+		// Trashed todos follow along so they are restored into the new list, but nobody sees
+		// them change.
+		_, err = tx.Exec(`update todos set list_id = $1 where list_id = $2 and deleted_at is not null`, cmd.MoveTo, cmd.ListID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Whether they were moved or trashed, the todos stop being shared through the list.
+	err = revokeAll(tx, cmd.ListID, todoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`delete from lists where list_id = $1`, cmd.ListID)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, tx.Commit()
+}
+
+// AddHook registers a hook told about the todos changed by the deletion of a list.
+func (s *ServiceImpl) AddHook(hook events.Hook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// todosChanged runs an update of todos returning their user_id, todo_id, list_id and version,
+// and tells the hooks about each of them, as moved out of fromListID when it is set.
+func (s ServiceImpl) todosChanged(tx *pgx.Tx, eventType string, fromListID *int, query string, args ...interface{}) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	changed := make([]events.Event, 0)
+	for rows.Next() {
+		event := events.Event{Type: eventType, FromListID: fromListID}
+		err = rows.Scan(&event.UserID, &event.TodoID, &event.ListID, &event.Version)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, event)
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	rows.Close()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	for _, hook := range s.hooks {
+		err = hook.TodosChanged(tx, changed...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
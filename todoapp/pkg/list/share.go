@@ -0,0 +1,190 @@
+package list
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/policy"
+	"time"
+)
+
+// ShareService manages who a list is shared with, which only its owner can do. Sharing a list
+// shares every todo in it.
+type ShareService interface {
+	GetShares(ctx context.Context, cmd *GetSharesCommand) ([]*policy.Share, error)
+	Share(ctx context.Context, cmd *ShareListCommand) (*policy.Share, error)
+	Unshare(ctx context.Context, cmd *UnshareListCommand) (*policy.Share, error)
+}
+
+type GetSharesCommand struct {
+	UserID int
+	ListID int
+}
+
+// ShareListCommand grants Role to the user of Email, or changes the role they have.
+type ShareListCommand struct {
+	UserID int
+	ListID int
+	Email  string
+	Role   string
+}
+
+// UnshareListCommand revokes the share of a list with ShareUserID. Besides the owner, users
+// can revoke their own share.
+type UnshareListCommand struct {
+	UserID      int
+	ListID      int
+	ShareUserID int
+}
+
+// lockList reads a list, checks the user has permission p on it, and locks it for the rest of
+// the transaction.
+func lockList(tx *pgx.Tx, userID int, listID int, p policy.Permission) (*List, error) {
+	list, err := scanList(tx.QueryRow(`select `+listColumns+` from lists where list_id = $1 for update`, listID))
+	if err != nil {
+		return nil, err
+	}
+	role, err := policy.ListRole(tx, userID, list.UserID, listID)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Check(role, p, ErrListNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// forgetTodos leaves tombstones of the todos of a list for a user who lost access to it.
+func forgetTodos(tx *pgx.Tx, listID int, userID int) error {
+	query := `select todo_id, $2::bigint as user_id from todos where list_id = $1`
+	return policy.ForgetTodos(tx, time.Now(), query, listID, userID)
+}
+
+// listTodoIDs returns the ids of the todos of a list, trashed ones included.
+func listTodoIDs(tx *pgx.Tx, listID int) ([]int64, error) {
+	rows, err := tx.Query(`select todo_id from todos where list_id = $1`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// revokeAll revokes every share of a list being deleted, leaving tombstones of the todos it had.
+func revokeAll(tx *pgx.Tx, listID int, todoIDs []int64) error {
+	rows, err := tx.Query(`delete from list_shares where list_id = $1 returning user_id`, listID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	revoked := make([]int, 0)
+	for rows.Next() {
+		var userID int
+		err = rows.Scan(&userID)
+		if err != nil {
+			return err
+		}
+		revoked = append(revoked, userID)
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	rows.Close()
+
+	query := `select todo_id, $2::bigint as user_id from todos where todo_id = any($1)`
+	for _, userID := range revoked {
+		err = policy.ForgetTodos(tx, time.Now(), query, todoIDs, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTx runs fn in a transaction, which is committed only if fn succeeds.
+func (s ServiceImpl) withTx(fn func(tx *pgx.Tx) error) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s ServiceImpl) GetShares(ctx context.Context, cmd *GetSharesCommand) ([]*policy.Share, error) {
+
+	var shares []*policy.Share
+	err := s.withTx(func(tx *pgx.Tx) error {
+		_, err := lockList(tx, cmd.UserID, cmd.ListID, policy.PermissionOwn)
+		if err != nil {
+			return err
+		}
+		shares, err = policy.ListShares.Get(tx, cmd.ListID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s ServiceImpl) Share(ctx context.Context, cmd *ShareListCommand) (*policy.Share, error) {
+
+	var share *policy.Share
+	err := s.withTx(func(tx *pgx.Tx) error {
+		list, err := lockList(tx, cmd.UserID, cmd.ListID, policy.PermissionOwn)
+		if err != nil {
+			return err
+		}
+		share, err = policy.ListShares.Grant(tx, cmd.ListID, list.UserID, cmd.Email, cmd.Role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (s ServiceImpl) Unshare(ctx context.Context, cmd *UnshareListCommand) (*policy.Share, error) {
+
+	permission := policy.PermissionOwn
+	if cmd.ShareUserID == cmd.UserID {
+		permission = policy.PermissionView
+	}
+
+	var share *policy.Share
+	err := s.withTx(func(tx *pgx.Tx) error {
+		_, err := lockList(tx, cmd.UserID, cmd.ListID, permission)
+		if err != nil {
+			return err
+		}
+		share, err = policy.ListShares.Revoke(tx, cmd.ListID, cmd.ShareUserID)
+		if err != nil {
+			return err
+		}
+		return forgetTodos(tx, cmd.ListID, cmd.ShareUserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
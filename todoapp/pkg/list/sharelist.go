@@ -0,0 +1,86 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+	"strconv"
+)
+
+type ShareListHttpHandler struct {
+	Service ShareService
+}
+
+func NewShareListHttpHandler(s ShareService) *ShareListHttpHandler {
+	return &ShareListHttpHandler{Service: s}
+}
+
+// ServeHTTP shares a list with the user of the email, or changes their role when it already is.
+func (h ShareListHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := policy.ShareRequestDTO{}
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.Unmarshal(bytes, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	listID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := ShareListCommand{
+		UserID: userID,
+		ListID: listID,
+		Email:  dto.Email,
+		Role:   dto.Role,
+	}
+
+	share, err := h.Service.Share(r.Context(), &cmd)
+	if errors.Is(err, ErrListNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrInvalidRole) || errors.Is(err, policy.ErrUserNotFound) || errors.Is(err, policy.ErrShareWithOwner) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(policy.NewResponseShareDTO(share))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,72 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+	"strconv"
+)
+
+type UnshareListHttpHandler struct {
+	Service ShareService
+}
+
+func NewUnshareListHttpHandler(s ShareService) *UnshareListHttpHandler {
+	return &UnshareListHttpHandler{Service: s}
+}
+
+// ServeHTTP revokes the share of a list with the user of the {userID} path parameter, either
+// by the owner of the list or by that user.
+func (h UnshareListHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	listID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	shareUserID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := UnshareListCommand{
+		UserID:      userID,
+		ListID:      listID,
+		ShareUserID: shareUserID,
+	}
+
+	share, err := h.Service.Unshare(r.Context(), &cmd)
+	if errors.Is(err, ErrListNotFound) || errors.Is(err, policy.ErrShareNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(policy.NewResponseShareDTO(share))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+
+	t.Run("send should append messages that read file returns in order", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "mail.jsonl")
+		mailer := NewFileMailer(path)
+
+		messages, err := ReadFile(path)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+
+		first := Message{To: "email1", Subject: "first", Body: "line 1\nline 2"}
+		second := Message{To: "email2", Subject: "second", Body: "body"}
+		require.NoError(t, mailer.Send(context.Background(), first))
+		require.NoError(t, mailer.Send(context.Background(), second))
+
+		messages, err = ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, []Message{first, second}, messages)
+	})
+}
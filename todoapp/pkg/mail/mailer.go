@@ -0,0 +1,17 @@
+package mail
+
+import (
+	"context"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer sends emails to users.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
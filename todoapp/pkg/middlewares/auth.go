@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"errors"
 	"kuberneteslab/todoapp/pkg/user"
 	"net/http"
 	"strconv"
@@ -12,30 +13,28 @@ const (
 	HeaderKeyUserName = "userName"
 )
 
-func AuthMiddleware(auth *user.AuthService, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+var (
+	errTokenMissing   = errors.New("auth token not provided")
+	errTokenFormat    = errors.New("auth token bad format")
+	errTokenNotBearer = errors.New("auth token not bearer")
+)
 
-		header := r.Header.Get("Authorization")
-		if len(header) == 0 {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("auth token not provided"))
-			return
-		}
+// TokenVerifier verifies access tokens, including that they haven't been revoked.
+type TokenVerifier interface {
+	VerifyToken(token string) (*user.Payload, error)
+}
 
-		fields := strings.Fields(header)
-		if len(fields) != 2 {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("auth token bad format"))
-			return
-		}
+func AuthMiddleware(auth TokenVerifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		if fields[0] != "Bearer" {
+		token, err := BearerToken(r)
+		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("auth token not bearer"))
+			w.Write([]byte(err.Error()))
 			return
 		}
 
-		payload, err := auth.VerifyToken(fields[1])
+		payload, err := auth.VerifyToken(token)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("auth token couldn't be verified"))
@@ -45,6 +44,33 @@ func AuthMiddleware(auth *user.AuthService, next http.Handler) http.Handler {
 		r.Header.Set(HeaderKeyUserID, strconv.Itoa(payload.UserID))
 		r.Header.Set(HeaderKeyUserName, payload.Username)
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(user.NewContext(r.Context(), payload)))
 	})
 }
+
+// BearerToken returns the token of the Authorization header of the request.
+func BearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) == 0 {
+		return "", errTokenMissing
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return "", errTokenFormat
+	}
+
+	if fields[0] != "Bearer" {
+		return "", errTokenNotBearer
+	}
+	return fields[1], nil
+}
+
+// UserIDFromRequest returns the id of the user authenticated by AuthMiddleware.
+func UserIDFromRequest(r *http.Request) (int, error) {
+	userID, err := strconv.Atoi(r.Header.Get(HeaderKeyUserID))
+	if err != nil {
+		return 0, errors.New("request is not authenticated")
+	}
+	return userID, nil
+}
@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Deprecated marks the responses of a route kept only for backwards compatibility,
+// pointing clients to the route that replaces it.
+func Deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,157 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kuberneteslab/todoapp/pkg/idempotency"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// Idempotent replays the first response of an authenticated route to retries sent with the same
+// Idempotency-Key, so that a retried create doesn't create twice. Keys are scoped by user, so it
+// must be wrapped by AuthMiddleware.
+func Idempotent(svc idempotency.Service, next http.Handler) http.Handler {
+	return idempotent(svc, func(r *http.Request, body []byte) (string, error) {
+		userID, err := UserIDFromRequest(r)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("user:%d", userID), nil
+	}, next)
+}
+
+// PublicIdempotent is Idempotent for routes anyone can call. Keys are scoped by the string field
+// of the JSON body that identifies the caller, such as the email of a new user, trimmed, lower
+// cased and hashed, so that unrelated clients sending the same key don't share its response.
+// Requests without the field are passed through, for the route to reject them.
+func PublicIdempotent(svc idempotency.Service, field string, next http.Handler) http.Handler {
+	return idempotent(svc, func(r *http.Request, body []byte) (string, error) {
+		var fields map[string]interface{}
+		json.Unmarshal(body, &fields)
+		value, ok := fields[field].(string)
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return "", nil
+		}
+		sum := sha256.Sum256([]byte(value))
+		return fmt.Sprintf("public:%s:%s", field, hex.EncodeToString(sum[:])), nil
+	}, next)
+}
+
+// idempotent scopes keys with scopeOf, which is given the body of the request. Requests it
+// returns no scope for are passed through.
+func idempotent(svc idempotency.Service, scopeOf func(r *http.Request, body []byte) (string, error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope, err := scopeOf(r, body)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if scope == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		record, err := svc.Claim(r.Context(), scope, key, requestHash)
+		if errors.Is(err, idempotency.ErrInvalidKey) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if record != nil {
+			if record.RequestHash != requestHash {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte("Idempotency-Key was already used with another request"))
+				return
+			}
+			if !record.Done {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte("a request with this Idempotency-Key is still in progress"))
+				return
+			}
+			for name, values := range record.Headers {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.Status)
+			w.Write(record.Body)
+			return
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				svc.Release(r.Context(), scope, key)
+				panic(p)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Server errors are not replayed, so that the request can be retried once the cause is gone.
+		if rec.status >= http.StatusInternalServerError {
+			err = svc.Release(r.Context(), scope, key)
+		} else {
+			err = svc.Complete(r.Context(), scope, key, rec.status, w.Header().Clone(), rec.body.Bytes())
+		}
+		if err != nil {
+			log.Println("error storing idempotent response: ", err.Error())
+		}
+	})
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
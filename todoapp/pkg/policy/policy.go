@@ -0,0 +1,311 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx"
+	"time"
+)
+
+// Roles a user can have on a todo or a list. The owner has every permission, and shares grant
+// one of the others. A share of a list grants its role on every todo in the list.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Permission is what an action on a todo or a list requires.
+type Permission int
+
+const (
+	// PermissionView reads a todo, its checklist and its history, or the todos of a list.
+	PermissionView Permission = iota
+	// PermissionEdit changes the fields, the completion and the checklist of a todo.
+	PermissionEdit
+	// PermissionOwn deletes, moves and shares a todo, and manages a list.
+	PermissionOwn
+)
+
+var (
+	ErrForbidden      = errors.New("you don't have permission to do this")
+	ErrInvalidRole    = errors.New("role must be viewer or editor")
+	ErrUserNotFound   = errors.New("no user has this email")
+	ErrShareWithOwner = errors.New("the owner already has access")
+	ErrShareNotFound  = errors.New("share not found")
+)
+
+// Allows reports whether role has permission p. No role has no permission.
+func Allows(role string, p Permission) bool {
+	switch role {
+	case RoleOwner:
+		return true
+	case RoleEditor:
+		return p <= PermissionEdit
+	case RoleViewer:
+		return p == PermissionView
+	default:
+		return false
+	}
+}
+
+// Check returns nil if role has permission p. Without any role it returns notFound, so that
+// what isn't shared with a user stays hidden from them, and ErrForbidden otherwise.
+func Check(role string, p Permission, notFound error) error {
+	if role == "" {
+		return notFound
+	}
+	if !Allows(role, p) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Querier runs a query returning one row, in a transaction or not.
+type Querier interface {
+	QueryRow(sql string, args ...interface{}) *pgx.Row
+}
+
+// RowsQuerier runs a query returning rows, in a transaction or not.
+type RowsQuerier interface {
+	Query(sql string, args ...interface{}) (*pgx.Rows, error)
+}
+
+// TodoRole returns the role of userID on a todo owned by ownerID in listID, which may be nil:
+// owner, the highest role shared with them on the todo or its list, or empty.
+func TodoRole(q Querier, userID int, ownerID int, todoID int, listID *int) (string, error) {
+	if userID == ownerID {
+		return RoleOwner, nil
+	}
+	query := `select coalesce(max(case role when 'editor' then 2 else 1 end), 0) from (
+		select role from todo_shares where todo_id = $1 and user_id = $3
+		union all
+		select role from list_shares where list_id = $2 and user_id = $3) shares`
+	var level int
+	err := q.QueryRow(query, todoID, listID, userID).Scan(&level)
+	if err != nil {
+		return "", err
+	}
+	return [...]string{"", RoleViewer, RoleEditor}[level], nil
+}
+
+// ListRole returns the role of userID on a list owned by ownerID: owner, the role shared with
+// them, or empty.
+func ListRole(q Querier, userID int, ownerID int, listID int) (string, error) {
+	if userID == ownerID {
+		return RoleOwner, nil
+	}
+	var role string
+	err := q.QueryRow(`select role from list_shares where list_id = $1 and user_id = $2`, listID, userID).Scan(&role)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// TodoGrantees returns the users a todo in listID, which may be nil, is shared with, directly or
+// through the list. When fromListID is set, the users the list it was moved out of is shared with
+// are included, since they have just lost it.
+func TodoGrantees(q RowsQuerier, todoID int, listID *int, fromListID *int) ([]int, error) {
+	query := `select user_id from todo_shares where todo_id = $1
+		union select user_id from list_shares where list_id = $2 or list_id = $3
+		order by user_id`
+	rows, err := q.Query(query, todoID, listID, fromListID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grantees := make([]int, 0)
+	for rows.Next() {
+		var userID int
+		err = rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+		grantees = append(grantees, userID)
+	}
+	return grantees, rows.Err()
+}
+
+// ForgetTodos leaves a tombstone at deletedAt of each todo_id and user_id the query selects,
+// unless the user can still view the todo, so that the change feed of a user who lost access to
+// a todo tells them it is gone. The query takes args, and runs once access is revoked.
+func ForgetTodos(tx *pgx.Tx, deletedAt time.Time, query string, args ...interface{}) error {
+	insert := fmt.Sprintf(`insert into todo_tombstones(todo_id, user_id, deleted_at)
+		select lost.todo_id, lost.user_id, $%[2]d::timestamp from (%[1]s) lost
+		where not exists (select 1 from todos where todos.todo_id = lost.todo_id and %[3]s)
+		on conflict (todo_id, user_id) do update set deleted_at = excluded.deleted_at`, query, len(args)+1, VisibleTodos("lost.user_id"))
+	_, err := tx.Exec(insert, append(args, deletedAt)...)
+	return err
+}
+
+// RevealTodos records that each todo_id and user_id the query selects became visible at
+// grantedAt, so that the change feed of a user who was given access to a todo returns it even
+// though the todo itself didn't change. The query takes args.
+func RevealTodos(tx *pgx.Tx, grantedAt time.Time, query string, args ...interface{}) error {
+	insert := fmt.Sprintf(`insert into todo_grants(todo_id, user_id, granted_at)
+		select granted.todo_id, granted.user_id, $%[2]d::timestamp from (%[1]s) granted
+		on conflict (todo_id, user_id) do update set granted_at = excluded.granted_at`, query, len(args)+1)
+	_, err := tx.Exec(insert, append(args, grantedAt)...)
+	return err
+}
+
+// VisibleTodos is the condition on the todos table of the todos the user given by the
+// placeholder can view: theirs, and those shared with them directly or through their list.
+func VisibleTodos(user string) string {
+	return fmt.Sprintf(`(user_id = %[1]s or todo_id in (select todo_id from todo_shares where user_id = %[1]s)
+		or list_id in (select list_id from list_shares where user_id = %[1]s))`, user)
+}
+
+// VisibleTodoSources are disjoint conditions on the todos table whose union is VisibleTodos:
+// the todos of the user, those shared with them directly, and those shared with them through
+// their list only. Querying each apart lets the first use the indexes on user_id.
+func VisibleTodoSources(user string) []string {
+	return []string{
+		fmt.Sprintf(`user_id = %[1]s`, user),
+		fmt.Sprintf(`user_id <> %[1]s and todo_id in (select todo_id from todo_shares where user_id = %[1]s)`, user),
+		fmt.Sprintf(`user_id <> %[1]s and list_id in (select list_id from list_shares where user_id = %[1]s)
+		and todo_id not in (select todo_id from todo_shares where user_id = %[1]s)`, user),
+	}
+}
+
+// VisibleLists is the condition on the lists table of the lists the user given by the
+// placeholder can view: theirs, and those shared with them.
+func VisibleLists(user string) string {
+	return fmt.Sprintf(`(user_id = %[1]s or list_id in (select list_id from list_shares where user_id = %[1]s))`, user)
+}
+
+// Share grants Role to a user other than the owner.
+type Share struct {
+	UserID    int
+	UserName  string
+	Email     string
+	Role      string
+	CreatedAt time.Time
+}
+
+// Shares are the shares of one kind of resource, stored in table and keyed by column, and todos
+// selects the todos a share of the resource given by $1 gives access to. Callers check the user
+// is allowed to manage them.
+type Shares struct {
+	table  string
+	column string
+	todos  string
+}
+
+var (
+	TodoShares = Shares{table: "todo_shares", column: "todo_id", todos: `select $1::bigint as todo_id`}
+	ListShares = Shares{table: "list_shares", column: "list_id", todos: `select todo_id from todos where list_id = $1`}
+)
+
+func scanShare(row interface{ Scan(...interface{}) error }) (*Share, error) {
+	var share Share
+	err := row.Scan(&share.UserID, &share.UserName, &share.Email, &share.Role, &share.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// Get lists the shares of a resource, oldest first.
+func (s Shares) Get(tx *pgx.Tx, resourceID int) ([]*Share, error) {
+	query := fmt.Sprintf(`select s.user_id, u.username, u.email, s.role, s.created_at from %s s join users u on u.user_id = s.user_id
+		where s.%s = $1 order by s.created_at, s.user_id`, s.table, s.column)
+	rows, err := tx.Query(query, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]*Share, 0)
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// Grant shares a resource of ownerID with the user of email, or changes their role if it
+// already is, and records that its todos became visible to them.
+func (s Shares) Grant(tx *pgx.Tx, resourceID int, ownerID int, email string, role string) (*Share, error) {
+	if role != RoleViewer && role != RoleEditor {
+		return nil, ErrInvalidRole
+	}
+
+	var userID int
+	err := tx.QueryRow(`select user_id from users where email = $1`, email).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID == ownerID {
+		return nil, ErrShareWithOwner
+	}
+
+	query := fmt.Sprintf(`with granted as (
+			insert into %[1]s(%[2]s, user_id, role) values ($1, $2, $3)
+			on conflict (%[2]s, user_id) do update set role = excluded.role
+			returning user_id, role, created_at)
+		select g.user_id, u.username, u.email, g.role, g.created_at from granted g join users u on u.user_id = g.user_id`, s.table, s.column)
+	share, err := scanShare(tx.QueryRow(query, resourceID, userID, role))
+	if err != nil {
+		return nil, err
+	}
+
+	todos := `select todos.todo_id, $2::bigint as user_id from (` + s.todos + `) todos`
+	err = RevealTodos(tx, time.Now(), todos, resourceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// Revoke removes the share of a resource with userID.
+func (s Shares) Revoke(tx *pgx.Tx, resourceID int, userID int) (*Share, error) {
+	query := fmt.Sprintf(`with revoked as (
+			delete from %s where %s = $1 and user_id = $2 returning user_id, role, created_at)
+		select r.user_id, u.username, u.email, r.role, r.created_at from revoked r join users u on u.user_id = r.user_id`, s.table, s.column)
+	return scanShare(tx.QueryRow(query, resourceID, userID))
+}
+
+// ResponseShareDTO is a share, as the share endpoints of todos and lists answer it.
+type ResponseShareDTO struct {
+	UserID    int       `json:"user_id"`
+	UserName  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewResponseShareDTO(share *Share) ResponseShareDTO {
+	return ResponseShareDTO{
+		UserID:    share.UserID,
+		UserName:  share.UserName,
+		Email:     share.Email,
+		Role:      share.Role,
+		CreatedAt: share.CreatedAt,
+	}
+}
+
+// GetSharesResponseDTO lists the shares of a todo or a list.
+type GetSharesResponseDTO struct {
+	Shares []ResponseShareDTO `json:"shares"`
+}
+
+// ShareRequestDTO shares a todo or a list with the user of Email, as viewer or editor.
+type ShareRequestDTO struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
@@ -0,0 +1,573 @@
+// Package rrule parses and expands the subset of RFC 5545 recurrence rules todos use:
+// FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY,
+// BYMONTH and WKST. Occurrences are computed in the wall clock of a location, so that a
+// todo due every day at 9:00 stays at 9:00 across DST transitions.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Recurrences are expanded in the zone of their owner, which must be available even on
+	// images without a zoneinfo database.
+	_ "time/tzdata"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+var (
+	ErrInvalidRule     = errors.New("invalid recurrence rule")
+	ErrUnsupportedPart = errors.New("unsupported recurrence rule part")
+)
+
+// maxEmptyPeriods bounds how many periods in a row may produce no occurrence before the
+// expansion gives up, so that rules such as FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30 end.
+const maxEmptyPeriods = 5000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Day is a BYDAY entry. N is the ordinal of the weekday within the month or year, negative
+// when counted from the end, or 0 for every such weekday.
+type Day struct {
+	Weekday time.Weekday
+	N       int
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []Day
+	ByMonthDay []int
+	ByMonth    []int
+	WeekStart  time.Weekday
+
+	// untilLocal is set when UNTIL has no Z suffix, so it is a wall clock time in the zone
+	// the rule is expanded in. untilDate is set when UNTIL is a date, which includes the whole day.
+	untilLocal bool
+	untilDate  bool
+}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10". A leading
+// "RRULE:" is accepted.
+func Parse(s string) (*Rule, error) {
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		value = strings.ToUpper(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = f
+			default:
+				return nil, fmt.Errorf("%w: FREQ=%s", ErrUnsupportedPart, value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalidRule)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive number", ErrInvalidRule)
+			}
+		case "UNTIL":
+			err = rule.parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseDays(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(value, -31, 31)
+		case "BYMONTH":
+			rule.ByMonth, err = parseInts(value, 1, 12)
+		case "WKST":
+			day, ok := weekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("%w: WKST=%s", ErrInvalidRule, value)
+			}
+			rule.WeekStart = day
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPart, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := rule.validate()
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *Rule) parseUntil(value string) error {
+	layouts := []struct {
+		layout string
+		local  bool
+		date   bool
+	}{
+		{"20060102T150405Z", false, false},
+		{"20060102T150405", true, false},
+		{"20060102", true, true},
+	}
+	for _, l := range layouts {
+		t, err := time.Parse(l.layout, value)
+		if err == nil {
+			r.Until = &t
+			r.untilLocal = l.local
+			r.untilDate = l.date
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: UNTIL=%s", ErrInvalidRule, value)
+}
+
+func parseDays(value string) ([]Day, error) {
+	var days []Day
+	for _, v := range strings.Split(value, ",") {
+		if len(v) < 2 {
+			return nil, fmt.Errorf("%w: BYDAY=%s", ErrInvalidRule, value)
+		}
+		weekday, ok := weekdays[v[len(v)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%w: BYDAY=%s", ErrInvalidRule, value)
+		}
+		n := 0
+		if ordinal := v[:len(v)-2]; ordinal != "" {
+			var err error
+			n, err = strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("%w: BYDAY=%s", ErrInvalidRule, value)
+			}
+		}
+		days = append(days, Day{Weekday: weekday, N: n})
+	}
+	return days, nil
+}
+
+func parseInts(value string, min, max int) ([]int, error) {
+	var ints []int
+	for _, v := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("%w: %s is out of range", ErrInvalidRule, v)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Freq == "" {
+		return fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if r.Count > 0 && r.Until != nil {
+		return fmt.Errorf("%w: COUNT and UNTIL can't be combined", ErrInvalidRule)
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return fmt.Errorf("%w: BYMONTHDAY can't be used with FREQ=WEEKLY", ErrInvalidRule)
+	}
+	if r.Freq == Daily || r.Freq == Weekly {
+		for _, day := range r.ByDay {
+			if day.N != 0 {
+				return fmt.Errorf("%w: BYDAY ordinals need FREQ=MONTHLY or FREQ=YEARLY", ErrInvalidRule)
+			}
+		}
+	}
+	return nil
+}
+
+// String formats the rule back into its RRULE value, with its parts in a fixed order.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		switch {
+		case r.untilDate:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		case r.untilLocal:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		default:
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day.Weekday]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(ints []int) string {
+	s := make([]string, len(ints))
+	for i, n := range ints {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// Nth returns the occurrence at index n of the rule started at dtstart, dtstart itself
+// being the occurrence at index 0. It returns false when the rule ends before that.
+func (r *Rule) Nth(dtstart time.Time, n int) (time.Time, bool) {
+	it := r.Iterator(dtstart)
+	var t time.Time
+	for i := 0; i <= n; i++ {
+		var ok bool
+		t, ok = it.Next()
+		if !ok {
+			return time.Time{}, false
+		}
+	}
+	return t, true
+}
+
+// All returns up to max occurrences of the rule started at dtstart.
+func (r *Rule) All(dtstart time.Time, max int) []time.Time {
+	it := r.Iterator(dtstart)
+	var all []time.Time
+	for len(all) < max {
+		t, ok := it.Next()
+		if !ok {
+			break
+		}
+		all = append(all, t)
+	}
+	return all
+}
+
+// Iterator expands the rule from dtstart, in the location of dtstart. As RFC 5545 requires,
+// dtstart is always the first occurrence and counts towards COUNT.
+func (r *Rule) Iterator(dtstart time.Time) *Iterator {
+	it := &Iterator{rule: r, start: dtstart}
+	if r.Until != nil {
+		until := *r.Until
+		if r.untilLocal {
+			until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, dtstart.Location())
+		}
+		if r.untilDate {
+			until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		it.until = &until
+	}
+	return it
+}
+
+type Iterator struct {
+	rule    *Rule
+	start   time.Time
+	until   *time.Time
+	period  int
+	pending []time.Time
+	emitted int
+	done    bool
+}
+
+// Next returns the next occurrence, or false once the rule has ended.
+func (it *Iterator) Next() (time.Time, bool) {
+	if it.done || (it.rule.Count > 0 && it.emitted >= it.rule.Count) {
+		it.done = true
+		return time.Time{}, false
+	}
+
+	var t time.Time
+	if it.emitted == 0 {
+		t = it.start
+	} else {
+		empty := 0
+		for len(it.pending) == 0 {
+			if empty >= maxEmptyPeriods {
+				it.done = true
+				return time.Time{}, false
+			}
+			it.pending = it.nextPeriod()
+			if len(it.pending) == 0 {
+				empty++
+			}
+		}
+		t = it.pending[0]
+		it.pending = it.pending[1:]
+	}
+
+	if it.until != nil && t.After(*it.until) {
+		it.done = true
+		return time.Time{}, false
+	}
+	it.emitted++
+	return t, true
+}
+
+// nextPeriod returns the occurrences after dtstart falling in the next day, week, month or
+// year of the rule, in order.
+func (it *Iterator) nextPeriod() []time.Time {
+	r := it.rule
+	s := it.start
+	step := it.period * r.Interval
+	it.period++
+
+	var dates []date
+	switch r.Freq {
+	case Daily:
+		d := dateOf(s).addDays(step)
+		if r.matchesMonth(d) && r.matchesMonthDay(d) && r.matchesWeekday(d) {
+			dates = []date{d}
+		}
+	case Weekly:
+		first := dateOf(s).addDays(-int((s.Weekday() - r.WeekStart + 7) % 7)).addDays(7 * step)
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []Day{{Weekday: s.Weekday()}}
+		}
+		for i := 0; i < 7; i++ {
+			d := first.addDays(i)
+			if r.matchesMonth(d) && containsWeekday(byDay, d.weekday()) {
+				dates = append(dates, d)
+			}
+		}
+	case Monthly:
+		y, m := s.Year(), int(s.Month())-1+step
+		y, m = y+m/12, m%12+1
+		if r.matchesMonth(date{y, time.Month(m), 1}) {
+			dates = r.monthDates(y, time.Month(m), s.Day())
+		}
+	case Yearly:
+		y := s.Year() + step
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, m := range sortedInts(r.ByMonth) {
+				dates = append(dates, r.monthDates(y, time.Month(m), s.Day())...)
+			}
+		case len(r.ByDay) > 0 && len(r.ByMonthDay) == 0:
+			dates = expandDays(date{y, time.January, 1}, date{y + 1, time.January, 1}, r.ByDay)
+		default:
+			dates = r.monthDates(y, s.Month(), s.Day())
+		}
+	}
+
+	var times []time.Time
+	for _, d := range dates {
+		t := wallClock(d, s)
+		if t.After(s) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// wallClock returns the time of day of start on day d, in the location of start. A time of day
+// skipped by a DST transition is read with the offset before the transition, as RFC 5545
+// requires, which moves it forward by the length of the gap.
+func wallClock(d date, start time.Time) time.Time {
+	t := time.Date(d.year, d.month, d.day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	if t.Hour() == start.Hour() && t.Minute() == start.Minute() {
+		return t
+	}
+	_, before := t.Add(-6 * time.Hour).Zone()
+	u := time.Date(d.year, d.month, d.day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+	return u.Add(-time.Duration(before) * time.Second).In(start.Location())
+}
+
+// monthDates returns the days of a month matching BYMONTHDAY and BYDAY, or the day of
+// dtstart when the rule has neither. Days a month doesn't have, such as the 31st of April,
+// are skipped rather than moved.
+func (r *Rule) monthDates(year int, month time.Month, startDay int) []date {
+	first := date{year, month, 1}
+	next := first.addMonths(1)
+	length := first.daysUntil(next)
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if startDay > length {
+			return nil
+		}
+		return []date{{year, month, startDay}}
+	}
+
+	var candidates []date
+	if len(r.ByDay) > 0 {
+		candidates = expandDays(first, next, r.ByDay)
+	} else {
+		for i := 0; i < length; i++ {
+			candidates = append(candidates, first.addDays(i))
+		}
+	}
+
+	var dates []date
+	for _, d := range candidates {
+		if r.matchesMonthDay(d) {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+// expandDays returns the days in [from, to) matching any of days, where ordinals count the
+// weekday within that range.
+func expandDays(from date, to date, days []Day) []date {
+	length := from.daysUntil(to)
+	selected := make([]bool, length)
+
+	for _, day := range days {
+		var matches []int
+		for i := 0; i < length; i++ {
+			if from.addDays(i).weekday() == day.Weekday {
+				matches = append(matches, i)
+			}
+		}
+		switch {
+		case day.N == 0:
+			for _, i := range matches {
+				selected[i] = true
+			}
+		case day.N > 0 && day.N <= len(matches):
+			selected[matches[day.N-1]] = true
+		case day.N < 0 && -day.N <= len(matches):
+			selected[matches[len(matches)+day.N]] = true
+		}
+	}
+
+	var dates []date
+	for i, ok := range selected {
+		if ok {
+			dates = append(dates, from.addDays(i))
+		}
+	}
+	return dates
+}
+
+func (r *Rule) matchesMonth(d date) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(d date) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := date{d.year, d.month, 1}.daysUntil(date{d.year, d.month, 1}.addMonths(1))
+	for _, md := range r.ByMonthDay {
+		if md == d.day || (md < 0 && length+md+1 == d.day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(d date) bool {
+	return len(r.ByDay) == 0 || containsWeekday(r.ByDay, d.weekday())
+}
+
+func containsWeekday(days []Day, weekday time.Weekday) bool {
+	for _, day := range days {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedInts(ints []int) []int {
+	sorted := append([]int(nil), ints...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+// date is a calendar day, free of any location, so that stepping through days isn't
+// affected by DST transitions.
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) date {
+	y, m, d := t.Date()
+	return date{y, m, d}
+}
+
+func (d date) time() time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.UTC)
+}
+
+func (d date) addDays(n int) date {
+	return dateOf(d.time().AddDate(0, 0, n))
+}
+
+func (d date) addMonths(n int) date {
+	return dateOf(time.Date(d.year, d.month+time.Month(n), 1, 0, 0, 0, 0, time.UTC))
+}
+
+func (d date) daysUntil(other date) int {
+	return int(other.time().Sub(d.time()).Hours() / 24)
+}
+
+func (d date) weekday() time.Weekday {
+	return d.time().Weekday()
+}
@@ -0,0 +1,210 @@
+package rrule
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func mustParse(t *testing.T, s string) *Rule {
+	rule, err := Parse(s)
+	require.NoError(t, err)
+	return rule
+}
+
+func formatAll(times []time.Time) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format(time.RFC3339)
+	}
+	return formatted
+}
+
+func TestParse(t *testing.T) {
+
+	t.Run("parse a rule should round trip", func(t *testing.T) {
+		for _, s := range []string{
+			"FREQ=DAILY",
+			"FREQ=WEEKLY;INTERVAL=2;COUNT=10;BYDAY=MO,WE,FR",
+			"FREQ=MONTHLY;UNTIL=20261231T235959Z;BYDAY=-1FR",
+			"FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=1",
+			"FREQ=WEEKLY;UNTIL=20260601;BYDAY=TU;WKST=SU",
+		} {
+			require.Equal(t, s, mustParse(t, s).String())
+		}
+	})
+
+	t.Run("parse a rule should accept the RRULE prefix and lowercase", func(t *testing.T) {
+		rule := mustParse(t, "RRULE:freq=weekly;byday=mo")
+		require.Equal(t, Weekly, rule.Freq)
+		require.Equal(t, []Day{{Weekday: time.Monday}}, rule.ByDay)
+	})
+
+	t.Run("parse an invalid rule should fail", func(t *testing.T) {
+		for _, s := range []string{
+			"",
+			"INTERVAL=2",
+			"FREQ=DAILY;INTERVAL=0",
+			"FREQ=DAILY;COUNT=3;UNTIL=20260101",
+			"FREQ=DAILY;FREQ=WEEKLY",
+			"FREQ=WEEKLY;BYDAY=1MO",
+			"FREQ=WEEKLY;BYMONTHDAY=1",
+			"FREQ=MONTHLY;BYMONTHDAY=32",
+			"FREQ=MONTHLY;BYDAY=XX",
+			"FREQ=DAILY;UNTIL=tomorrow",
+		} {
+			_, err := Parse(s)
+			require.ErrorIs(t, err, ErrInvalidRule, s)
+		}
+	})
+
+	t.Run("parse a rule with unsupported parts should fail", func(t *testing.T) {
+		for _, s := range []string{"FREQ=HOURLY", "FREQ=DAILY;BYHOUR=9", "FREQ=MONTHLY;BYSETPOS=-1"} {
+			_, err := Parse(s)
+			require.ErrorIs(t, err, ErrUnsupportedPart, s)
+		}
+	})
+}
+
+func TestExpand(t *testing.T) {
+
+	ny := mustLocation(t, "America/New_York")
+
+	t.Run("daily should keep the wall clock across DST transitions", func(t *testing.T) {
+		start := time.Date(2026, time.March, 7, 9, 0, 0, 0, ny)
+		got := mustParse(t, "FREQ=DAILY;COUNT=3").All(start, 10)
+
+		require.Equal(t, []string{
+			"2026-03-07T09:00:00-05:00",
+			"2026-03-08T09:00:00-04:00",
+			"2026-03-09T09:00:00-04:00",
+		}, formatAll(got))
+
+		start = time.Date(2026, time.October, 31, 9, 0, 0, 0, ny)
+		got = mustParse(t, "FREQ=DAILY;COUNT=3").All(start, 10)
+
+		require.Equal(t, []string{
+			"2026-10-31T09:00:00-04:00",
+			"2026-11-01T09:00:00-05:00",
+			"2026-11-02T09:00:00-05:00",
+		}, formatAll(got))
+	})
+
+	t.Run("daily at a time skipped by DST should move past the gap", func(t *testing.T) {
+		start := time.Date(2026, time.March, 7, 2, 30, 0, 0, ny)
+		got := mustParse(t, "FREQ=DAILY;COUNT=3").All(start, 10)
+
+		require.Equal(t, []string{
+			"2026-03-07T02:30:00-05:00",
+			"2026-03-08T03:30:00-04:00",
+			"2026-03-09T02:30:00-04:00",
+		}, formatAll(got))
+	})
+
+	t.Run("weekly across DST should keep the wall clock", func(t *testing.T) {
+		start := time.Date(2026, time.October, 26, 18, 0, 0, 0, ny)
+		got := mustParse(t, "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=4").All(start, 10)
+
+		require.Equal(t, []string{
+			"2026-10-26T18:00:00-04:00",
+			"2026-10-29T18:00:00-04:00",
+			"2026-11-02T18:00:00-05:00",
+			"2026-11-05T18:00:00-05:00",
+		}, formatAll(got))
+	})
+
+	t.Run("weekly with interval should skip weeks from the start week", func(t *testing.T) {
+		start := time.Date(2026, time.January, 7, 8, 0, 0, 0, time.UTC)
+		got := mustParse(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE").All(start, 4)
+
+		require.Equal(t, []string{
+			"2026-01-07T08:00:00Z",
+			"2026-01-19T08:00:00Z",
+			"2026-01-21T08:00:00Z",
+			"2026-02-02T08:00:00Z",
+		}, formatAll(got))
+	})
+
+	t.Run("monthly on the 31st should skip shorter months", func(t *testing.T) {
+		start := time.Date(2026, time.January, 31, 12, 0, 0, 0, time.UTC)
+		got := mustParse(t, "FREQ=MONTHLY").All(start, 3)
+
+		require.Equal(t, []string{
+			"2026-01-31T12:00:00Z",
+			"2026-03-31T12:00:00Z",
+			"2026-05-31T12:00:00Z",
+		}, formatAll(got))
+	})
+
+	t.Run("monthly by day should honour ordinals", func(t *testing.T) {
+		start := time.Date(2026, time.January, 30, 17, 0, 0, 0, time.UTC)
+		got := mustParse(t, "FREQ=MONTHLY;BYDAY=-1FR").All(start, 3)
+
+		require.Equal(t, []string{
+			"2026-01-30T17:00:00Z",
+			"2026-02-27T17:00:00Z",
+			"2026-03-27T17:00:00Z",
+		}, formatAll(got))
+
+		got = mustParse(t, "FREQ=MONTHLY;BYMONTHDAY=-1").All(start, 3)
+
+		require.Equal(t, []string{
+			"2026-01-30T17:00:00Z",
+			"2026-01-31T17:00:00Z",
+			"2026-02-28T17:00:00Z",
+		}, formatAll(got))
+	})
+
+	t.Run("yearly should skip years without the date", func(t *testing.T) {
+		start := time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)
+		got := mustParse(t, "FREQ=YEARLY").All(start, 2)
+
+		require.Equal(t, []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}, formatAll(got))
+	})
+
+	t.Run("count should include the start", func(t *testing.T) {
+		start := time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC)
+		rule := mustParse(t, "FREQ=DAILY;COUNT=2")
+
+		require.Len(t, rule.All(start, 10), 2)
+
+		_, ok := rule.Nth(start, 1)
+		require.True(t, ok)
+		_, ok = rule.Nth(start, 2)
+		require.False(t, ok)
+	})
+
+	t.Run("until should be inclusive", func(t *testing.T) {
+		start := time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC)
+
+		got := mustParse(t, "FREQ=DAILY;UNTIL=20260603T090000Z").All(start, 10)
+		require.Len(t, got, 3)
+
+		got = mustParse(t, "FREQ=DAILY;UNTIL=20260603T085959Z").All(start, 10)
+		require.Len(t, got, 2)
+
+		got = mustParse(t, "FREQ=DAILY;UNTIL=20260603").All(start, 10)
+		require.Len(t, got, 3)
+	})
+
+	t.Run("until without Z should be read in the zone of the start", func(t *testing.T) {
+		start := time.Date(2026, time.June, 1, 21, 0, 0, 0, ny)
+
+		got := mustParse(t, "FREQ=DAILY;UNTIL=20260603T210000").All(start, 10)
+		require.Len(t, got, 3)
+		require.Equal(t, "2026-06-03T21:00:00-04:00", got[2].Format(time.RFC3339))
+	})
+
+	t.Run("a rule without occurrences should end", func(t *testing.T) {
+		start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+		got := mustParse(t, "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30").All(start, 10)
+
+		require.Len(t, got, 1)
+	})
+}
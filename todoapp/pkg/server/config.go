@@ -1,12 +1,46 @@
 package server
 
 type Config struct {
-	Environment      string `json:"environment"`
-	DatabaseHost     string `json:"database_host"`
-	DatabasePort     int    `json:"database_port"`
-	DatabaseName     string `json:"database_name"`
-	DatabaseUser     string `json:"database_user"`
-	DatabasePassword string `json:"database_password"`
-	AuthKey          string `json:"auth_key"`
-	Port             string `json:"port"`
+	Environment                 string          `json:"environment"`
+	DatabaseHost                string          `json:"database_host"`
+	DatabasePort                int             `json:"database_port"`
+	DatabaseName                string          `json:"database_name"`
+	DatabaseUser                string          `json:"database_user"`
+	DatabasePassword            string          `json:"database_password"`
+	AuthMode                    string          `json:"auth_mode"`
+	AuthKey                     string          `json:"auth_key"`
+	AuthKeys                    []AuthKeyConfig `json:"auth_keys"`
+	AuthKeyGrace                string          `json:"auth_key_grace"`
+	Port                        string          `json:"port"`
+	TrashRetention              string          `json:"trash_retention"`
+	IdempotencyTTL              string          `json:"idempotency_ttl"`
+	AccessTokenTTL              string          `json:"access_token_ttl"`
+	RefreshTokenTTL             string          `json:"refresh_token_ttl"`
+	Mail                        MailConfig      `json:"mail"`
+	WebhookAllowPrivateNetworks bool            `json:"webhook_allow_private_networks"`
+	PasswordResetsPerEmail      int             `json:"password_resets_per_email"`
+	PasswordResetsPerIP         int             `json:"password_resets_per_ip"`
+}
+
+// AuthKeyConfig is a key of the keyring that replaces auth_key when set. In the public auth_mode
+// it is the seed of an Ed25519 key, and should then be 32 random characters. The key whose active_from
+// (RFC 3339, or always when left out) passed last signs new tokens, and the keys it replaced
+// still verify tokens for auth_key_grace after that. Keys are retired by removing them.
+type AuthKeyConfig struct {
+	ID         string `json:"id"`
+	Key        string `json:"key"`
+	ActiveFrom string `json:"active_from"`
+}
+
+// MailConfig chooses how emails are sent. The smtp driver sends them through an SMTP server, the
+// file driver appends them to file, and the memory driver, used when no driver is set, keeps them
+// in memory without sending them.
+type MailConfig struct {
+	Driver       string `json:"driver"`
+	From         string `json:"from"`
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"smtp_password"`
+	File         string `json:"file"`
 }
@@ -1,15 +1,23 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/idempotency"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/mail"
 	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/tag"
 	"kuberneteslab/todoapp/pkg/todo"
 	"kuberneteslab/todoapp/pkg/user"
+	"kuberneteslab/todoapp/pkg/webhook"
 	"log"
 	"net/http"
 	"os"
@@ -51,34 +59,244 @@ func Start(configPath string) {
 		log.Fatal("cannot connect to database: ", err.Error())
 	}
 
+	retention := todo.DefaultTrashRetention
+	if config.TrashRetention != "" {
+		retention, err = time.ParseDuration(config.TrashRetention)
+		if err != nil || retention <= 0 {
+			log.Fatal("invalid trash_retention: ", config.TrashRetention)
+		}
+	}
+
+	idempotencyTTL := idempotency.DefaultTTL
+	if config.IdempotencyTTL != "" {
+		idempotencyTTL, err = time.ParseDuration(config.IdempotencyTTL)
+		if err != nil || idempotencyTTL <= 0 {
+			log.Fatal("invalid idempotency_ttl: ", config.IdempotencyTTL)
+		}
+	}
+
+	accessTokenTTL := user.DefaultAccessTokenTTL
+	if config.AccessTokenTTL != "" {
+		accessTokenTTL, err = time.ParseDuration(config.AccessTokenTTL)
+		if err != nil || accessTokenTTL <= 0 {
+			log.Fatal("invalid access_token_ttl: ", config.AccessTokenTTL)
+		}
+	}
+
+	refreshTokenTTL := user.DefaultRefreshTokenTTL
+	if config.RefreshTokenTTL != "" {
+		refreshTokenTTL, err = time.ParseDuration(config.RefreshTokenTTL)
+		if err != nil || refreshTokenTTL <= accessTokenTTL {
+			log.Fatal("invalid refresh_token_ttl: ", config.RefreshTokenTTL)
+		}
+	}
+
+	resetLimits := user.DefaultResetLimits
+	if config.PasswordResetsPerEmail < 0 || config.PasswordResetsPerIP < 0 {
+		log.Fatal("invalid password_resets_per_email or password_resets_per_ip, they must not be negative")
+	}
+	if config.PasswordResetsPerEmail != 0 {
+		resetLimits.PerEmail = config.PasswordResetsPerEmail
+	}
+	if config.PasswordResetsPerIP != 0 {
+		resetLimits.PerIP = config.PasswordResetsPerIP
+	}
+
 	r := chi.NewRouter()
 
-	authSvc, err := user.NewAuthService(config.AuthKey)
+	keyGrace := user.DefaultKeyGrace
+	if config.AuthKeyGrace != "" {
+		keyGrace, err = time.ParseDuration(config.AuthKeyGrace)
+		if err != nil || keyGrace < accessTokenTTL {
+			log.Fatal("invalid auth_key_grace, it must be at least access_token_ttl: ", config.AuthKeyGrace)
+		}
+	}
+
+	keys, err := authKeys(config)
+	if err != nil {
+		log.Fatal("invalid auth_keys: ", err.Error())
+	}
+	keyring, err := user.NewKeyring(keys, keyGrace)
 	if err != nil {
 		log.Fatal("error creating auth service", err.Error())
 	}
-	us := user.NewServiceImpl(conn, authSvc)
+	authMode := user.ModeLocal
+	if config.AuthMode != "" {
+		authMode = config.AuthMode
+	}
+	authSvc, err := user.NewKeyringAuthService(keyring, authMode)
+	if err != nil {
+		log.Fatal("invalid auth_mode: ", config.AuthMode)
+	}
+	mailer, err := newMailer(config.Mail)
+	if err != nil {
+		log.Fatal("invalid mail config: ", err.Error())
+	}
+
+	us := user.NewServiceImpl(conn, authSvc, mailer, accessTokenTTL, refreshTokenTTL, resetLimits)
 	ts := todo.NewServiceImpl(conn)
+	tgs := tag.NewServiceImpl(conn)
+	ls := list.NewServiceImpl(conn)
+
+	ids := idempotency.NewServiceImpl(conn, idempotencyTTL)
+	whs := webhook.NewServiceImpl(conn, webhook.DefaultBackoff, config.WebhookAllowPrivateNetworks)
+
+	// Changes are notified through Postgres so that the event streams of every pod see them.
+	broker := events.NewBroker(pc.ConnConfig)
+	ts.AddHook(events.Notifier{})
+	ls.AddHook(events.Notifier{})
+	ts.AddHook(whs)
+	ls.AddHook(whs)
+
+	go todo.RunTrashPurger(context.Background(), ts, retention, time.Hour)
+	go idempotency.RunPurger(context.Background(), ids, time.Hour)
+	go user.RunSessionPurger(context.Background(), us, time.Hour)
+	go user.RunResetMailer(context.Background(), us, time.Second)
+	go broker.Run(context.Background())
+	go webhook.RunDispatcher(context.Background(), whs, 2*time.Second)
 
 	createUser := user.NewCreateUserHttpHandler(us)
 	loginUser := user.NewLoginUserHttpHandler(us)
+	refreshToken := user.NewRefreshTokenHttpHandler(us)
+	logoutUser := user.NewLogoutUserHttpHandler(us)
+	publicKeys := user.NewPublicKeysHttpHandler(authSvc)
+	getUser := user.NewGetUserHttpHandler(us)
+	updateUser := user.NewUpdateUserHttpHandler(us)
+	deleteUser := user.NewDeleteUserHttpHandler(us)
+	changePassword := user.NewChangePasswordHttpHandler(us)
+	forgotPassword := user.NewForgotPasswordHttpHandler(us)
+	resetPassword := user.NewResetPasswordHttpHandler(us)
 
 	createTodo := todo.NewCreateTodoHttpHandler(ts)
 	deleteTodo := todo.NewDeleteTodoHttpHandler(ts)
 	updateTodo := todo.NewUpdateTodoHttpHandler(ts)
 	getAllTodo := todo.NewGetAllTodoHttpHandler(ts)
+	getTodo := todo.NewGetTodoHttpHandler(ts)
+	searchTodo := todo.NewSearchTodosHttpHandler(ts)
+	getTrash := todo.NewGetTrashHttpHandler(ts)
+	restoreTodo := todo.NewRestoreTodoHttpHandler(ts)
+	purgeTodo := todo.NewPurgeTodoHttpHandler(ts)
+	getHistory := todo.NewGetHistoryHttpHandler(ts)
+	revertTodo := todo.NewRevertTodoHttpHandler(ts)
+	batchTodos := todo.NewBatchTodosHttpHandler(ts)
+	streamEvents := todo.NewStreamEventsHttpHandler(ts, broker)
+	liveTodos := todo.NewLiveTodosHttpHandler(ts, ts, broker, us)
+	getChanges := todo.NewGetChangesHttpHandler(ts)
+	pushChanges := todo.NewPushChangesHttpHandler(ts)
+
+	getItems := todo.NewGetItemsHttpHandler(ts)
+	addItem := todo.NewAddItemHttpHandler(ts)
+	updateItem := todo.NewUpdateItemHttpHandler(ts)
+	toggleItem := todo.NewToggleItemHttpHandler(ts)
+	reorderItems := todo.NewReorderItemsHttpHandler(ts)
+	deleteItem := todo.NewDeleteItemHttpHandler(ts)
+
+	createTag := tag.NewCreateTagHttpHandler(tgs)
+	getAllTag := tag.NewGetAllTagsHttpHandler(tgs)
+	renameTag := tag.NewRenameTagHttpHandler(tgs)
+	deleteTag := tag.NewDeleteTagHttpHandler(tgs)
+
+	createList := list.NewCreateListHttpHandler(ls)
+	getAllList := list.NewGetAllListsHttpHandler(ls)
+	renameList := list.NewRenameListHttpHandler(ls)
+	archiveList := list.NewArchiveListHttpHandler(ls)
+	unarchiveList := list.NewUnarchiveListHttpHandler(ls)
+	deleteList := list.NewDeleteListHttpHandler(ls)
+	completeTodo := todo.NewCompleteTodoHttpHandler(ts)
+	reopenTodo := todo.NewReopenTodoHttpHandler(ts)
+	getTodoShares := todo.NewGetSharesHttpHandler(ts)
+	shareTodo := todo.NewShareTodoHttpHandler(ts)
+	unshareTodo := todo.NewUnshareTodoHttpHandler(ts)
+	getComments := todo.NewGetCommentsHttpHandler(ts)
+	addComment := todo.NewAddCommentHttpHandler(ts)
+	updateComment := todo.NewUpdateCommentHttpHandler(ts)
+	deleteComment := todo.NewDeleteCommentHttpHandler(ts)
+	getListShares := list.NewGetSharesHttpHandler(ls)
+	shareList := list.NewShareListHttpHandler(ls)
+	unshareList := list.NewUnshareListHttpHandler(ls)
+
+	createWebhook := webhook.NewCreateWebhookHttpHandler(whs)
+	getAllWebhook := webhook.NewGetAllWebhooksHttpHandler(whs)
+	deleteWebhook := webhook.NewDeleteWebhookHttpHandler(whs)
+	getDeliveries := webhook.NewGetDeliveriesHttpHandler(whs)
+	redeliver := webhook.NewRedeliverHttpHandler(whs)
 
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
 	r.Get("/", Hello)
 	r.Get("/ping", Ping)
+	r.Get("/.well-known/paseto-keys", publicKeys.ServeHTTP)
 
-	r.Post("/user", createUser.ServeHTTP)
+	r.Post("/user", middlewares.PublicIdempotent(ids, "email", createUser).ServeHTTP)
 	r.Post("/user/login", loginUser.ServeHTTP)
-	r.Post("/todo", middlewares.AuthMiddleware(authSvc, createTodo).ServeHTTP)
-	r.Delete("/todo", middlewares.AuthMiddleware(authSvc, deleteTodo).ServeHTTP)
-	r.Get("/todo", middlewares.AuthMiddleware(authSvc, getAllTodo).ServeHTTP)
-	r.Patch("/todo", middlewares.AuthMiddleware(authSvc, updateTodo).ServeHTTP)
+	r.Post("/user/token/refresh", refreshToken.ServeHTTP)
+	r.Post("/user/logout", middlewares.AuthMiddleware(us, logoutUser).ServeHTTP)
+	r.Post("/user/password/forgot", forgotPassword.ServeHTTP)
+	r.Post("/user/password/reset", resetPassword.ServeHTTP)
+	r.Get("/users/me", middlewares.AuthMiddleware(us, getUser).ServeHTTP)
+	r.Patch("/users/me", middlewares.AuthMiddleware(us, updateUser).ServeHTTP)
+	r.Delete("/users/me", middlewares.AuthMiddleware(us, deleteUser).ServeHTTP)
+	r.Post("/users/me/password", middlewares.AuthMiddleware(us, changePassword).ServeHTTP)
+	r.Post("/todos", middlewares.AuthMiddleware(us, middlewares.Idempotent(ids, createTodo)).ServeHTTP)
+	r.Post("/todos/batch", middlewares.AuthMiddleware(us, batchTodos).ServeHTTP)
+	r.Get("/todos/events", middlewares.AuthMiddleware(us, streamEvents).ServeHTTP)
+	r.Get("/todos/live", liveTodos.ServeHTTP)
+	r.Get("/todos/search", middlewares.AuthMiddleware(us, searchTodo).ServeHTTP)
+	r.Get("/todos/trash", middlewares.AuthMiddleware(us, getTrash).ServeHTTP)
+	r.Delete("/todos/trash/{id}", middlewares.AuthMiddleware(us, purgeTodo).ServeHTTP)
+	r.Get("/todos/{id}", middlewares.AuthMiddleware(us, getTodo).ServeHTTP)
+	r.Patch("/todos/{id}", middlewares.AuthMiddleware(us, updateTodo).ServeHTTP)
+	r.Delete("/todos/{id}", middlewares.AuthMiddleware(us, deleteTodo).ServeHTTP)
+	r.Post("/todos/{id}/complete", middlewares.AuthMiddleware(us, completeTodo).ServeHTTP)
+	r.Post("/todos/{id}/reopen", middlewares.AuthMiddleware(us, reopenTodo).ServeHTTP)
+	r.Post("/todos/{id}/restore", middlewares.AuthMiddleware(us, restoreTodo).ServeHTTP)
+	r.Get("/todos/{id}/history", middlewares.AuthMiddleware(us, getHistory).ServeHTTP)
+	r.Post("/todos/{id}/revert", middlewares.AuthMiddleware(us, revertTodo).ServeHTTP)
+	r.Get("/todos/{id}/items", middlewares.AuthMiddleware(us, getItems).ServeHTTP)
+	r.Post("/todos/{id}/items", middlewares.AuthMiddleware(us, addItem).ServeHTTP)
+	r.Put("/todos/{id}/items/order", middlewares.AuthMiddleware(us, reorderItems).ServeHTTP)
+	r.Patch("/todos/{id}/items/{itemID}", middlewares.AuthMiddleware(us, updateItem).ServeHTTP)
+	r.Post("/todos/{id}/items/{itemID}/toggle", middlewares.AuthMiddleware(us, toggleItem).ServeHTTP)
+	r.Delete("/todos/{id}/items/{itemID}", middlewares.AuthMiddleware(us, deleteItem).ServeHTTP)
+	r.Get("/todos/{id}/shares", middlewares.AuthMiddleware(us, getTodoShares).ServeHTTP)
+	r.Post("/todos/{id}/shares", middlewares.AuthMiddleware(us, shareTodo).ServeHTTP)
+	r.Delete("/todos/{id}/shares/{userID}", middlewares.AuthMiddleware(us, unshareTodo).ServeHTTP)
+	r.Get("/todos/{id}/comments", middlewares.AuthMiddleware(us, getComments).ServeHTTP)
+	r.Post("/todos/{id}/comments", middlewares.AuthMiddleware(us, addComment).ServeHTTP)
+	r.Patch("/todos/{id}/comments/{commentID}", middlewares.AuthMiddleware(us, updateComment).ServeHTTP)
+	r.Delete("/todos/{id}/comments/{commentID}", middlewares.AuthMiddleware(us, deleteComment).ServeHTTP)
+	r.Get("/users/me/todos", middlewares.AuthMiddleware(us, getAllTodo).ServeHTTP)
+	r.Get("/sync", middlewares.AuthMiddleware(us, getChanges).ServeHTTP)
+	r.Post("/sync", middlewares.AuthMiddleware(us, pushChanges).ServeHTTP)
+
+	r.Post("/tags", middlewares.AuthMiddleware(us, createTag).ServeHTTP)
+	r.Get("/tags", middlewares.AuthMiddleware(us, getAllTag).ServeHTTP)
+	r.Patch("/tags/{id}", middlewares.AuthMiddleware(us, renameTag).ServeHTTP)
+	r.Delete("/tags/{id}", middlewares.AuthMiddleware(us, deleteTag).ServeHTTP)
+
+	r.Post("/lists", middlewares.AuthMiddleware(us, createList).ServeHTTP)
+	r.Get("/lists", middlewares.AuthMiddleware(us, getAllList).ServeHTTP)
+	r.Patch("/lists/{id}", middlewares.AuthMiddleware(us, renameList).ServeHTTP)
+	r.Post("/lists/{id}/archive", middlewares.AuthMiddleware(us, archiveList).ServeHTTP)
+	r.Post("/lists/{id}/unarchive", middlewares.AuthMiddleware(us, unarchiveList).ServeHTTP)
+	r.Delete("/lists/{id}", middlewares.AuthMiddleware(us, deleteList).ServeHTTP)
+	r.Get("/lists/{id}/shares", middlewares.AuthMiddleware(us, getListShares).ServeHTTP)
+	r.Post("/lists/{id}/shares", middlewares.AuthMiddleware(us, shareList).ServeHTTP)
+	r.Delete("/lists/{id}/shares/{userID}", middlewares.AuthMiddleware(us, unshareList).ServeHTTP)
+
+	r.Post("/webhooks", middlewares.AuthMiddleware(us, createWebhook).ServeHTTP)
+	r.Get("/webhooks", middlewares.AuthMiddleware(us, getAllWebhook).ServeHTTP)
+	r.Delete("/webhooks/{id}", middlewares.AuthMiddleware(us, deleteWebhook).ServeHTTP)
+	r.Get("/webhooks/{id}/deliveries", middlewares.AuthMiddleware(us, getDeliveries).ServeHTTP)
+	r.Post("/webhooks/{id}/deliveries/{deliveryID}/redeliver", middlewares.AuthMiddleware(us, redeliver).ServeHTTP)
+
+	// Deprecated aliases taking the todo and user ids from the body or query string.
+	r.Post("/todo", middlewares.Deprecated("/todos", middlewares.AuthMiddleware(us, middlewares.Idempotent(ids, createTodo))).ServeHTTP)
+	r.Delete("/todo", middlewares.Deprecated("/todos/{id}", middlewares.AuthMiddleware(us, deleteTodo)).ServeHTTP)
+	r.Get("/todo", middlewares.Deprecated("/users/me/todos", middlewares.AuthMiddleware(us, getAllTodo)).ServeHTTP)
+	r.Patch("/todo", middlewares.Deprecated("/todos/{id}", middlewares.AuthMiddleware(us, updateTodo)).ServeHTTP)
+	r.Post("/todo/complete", middlewares.Deprecated("/todos/{id}/complete", middlewares.AuthMiddleware(us, completeTodo)).ServeHTTP)
+	r.Post("/todo/reopen", middlewares.Deprecated("/todos/{id}/reopen", middlewares.AuthMiddleware(us, reopenTodo)).ServeHTTP)
 
 	log.Println("lets listen")
 	err = http.ListenAndServe(config.Port, r)
@@ -87,6 +305,52 @@ func Start(configPath string) {
 	}
 }
 
+// authKeys returns the keys of auth_keys, or the single key of auth_key when there are none.
+func authKeys(config Config) ([]user.Key, error) {
+	if len(config.AuthKeys) == 0 {
+		return []user.Key{{ID: user.DefaultKeyID, Secret: []byte(config.AuthKey)}}, nil
+	}
+
+	keys := make([]user.Key, 0, len(config.AuthKeys))
+	for _, k := range config.AuthKeys {
+		key := user.Key{ID: k.ID, Secret: []byte(k.Key)}
+		if k.ActiveFrom != "" {
+			activeFrom, err := time.Parse(time.RFC3339, k.ActiveFrom)
+			if err != nil {
+				return nil, fmt.Errorf("active_from of key %v must be an RFC 3339 time", k.ID)
+			}
+			key.ActiveFrom = activeFrom
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// newMailer returns the mailer of the configured driver.
+func newMailer(config MailConfig) (mail.Mailer, error) {
+	switch config.Driver {
+	case "smtp":
+		if config.SMTPHost == "" || config.From == "" {
+			return nil, errors.New("the smtp driver needs smtp_host and from")
+		}
+		port := config.SMTPPort
+		if port == 0 {
+			port = 587
+		}
+		return mail.NewSMTPMailer(config.SMTPHost, port, config.SMTPUsername, config.SMTPPassword, config.From), nil
+	case "file":
+		if config.File == "" {
+			return nil, errors.New("the file driver needs file")
+		}
+		return mail.NewFileMailer(config.File), nil
+	case "", "memory":
+		log.Println("emails are kept in memory and not sent")
+		return mail.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown driver %v", config.Driver)
+	}
+}
+
 func Hello(w http.ResponseWriter, r *http.Request) {
 	name, _ := os.Hostname()
 	template := "Hello Kubernetes. Time: %v. Pod: %v.\n"
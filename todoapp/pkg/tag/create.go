@@ -0,0 +1,73 @@
+package tag
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+)
+
+type CreateTagHttpHandler struct {
+	Service Service
+}
+
+func NewCreateTagHttpHandler(s Service) *CreateTagHttpHandler {
+	return &CreateTagHttpHandler{Service: s}
+}
+
+type CreateTagRequestDTO struct {
+	Name string `json:"name"`
+}
+
+func (h CreateTagHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := CreateTagRequestDTO{}
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.Unmarshal(bytes, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := CreateTagCommand{
+		UserID: userID,
+		Name:   dto.Name,
+	}
+
+	tag, err := h.Service.Create(r.Context(), &cmd)
+	if errors.Is(err, ErrInvalidName) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrNameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(newResponseTagDTO(tag))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
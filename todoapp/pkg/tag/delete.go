@@ -0,0 +1,53 @@
+package tag
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type DeleteTagHttpHandler struct {
+	Service Service
+}
+
+func NewDeleteTagHttpHandler(s Service) *DeleteTagHttpHandler {
+	return &DeleteTagHttpHandler{Service: s}
+}
+
+func (h DeleteTagHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tagID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.Service.Delete(r.Context(), &DeleteTagCommand{UserID: userID, TagID: tagID})
+	if errors.Is(err, ErrTagNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseTagDTO(tag))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,62 @@
+package tag
+
+import (
+	"encoding/json"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+)
+
+type GetAllTagsHttpHandler struct {
+	Service Service
+}
+
+func NewGetAllTagsHttpHandler(s Service) *GetAllTagsHttpHandler {
+	return &GetAllTagsHttpHandler{Service: s}
+}
+
+type GetAllResponseDTO struct {
+	Tags []ResponseTagDTO `json:"tags"`
+}
+
+type ResponseTagDTO struct {
+	TagID     int    `json:"tag_id"`
+	Name      string `json:"name"`
+	TodoCount int    `json:"todo_count"`
+}
+
+func newResponseTagDTO(tag *Tag) ResponseTagDTO {
+	return ResponseTagDTO{
+		TagID:     tag.TagID,
+		Name:      tag.Name,
+		TodoCount: tag.TodoCount,
+	}
+}
+
+func (h GetAllTagsHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.Service.GetAll(r.Context(), &GetAllTagsCommand{UserID: userID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]ResponseTagDTO, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, newResponseTagDTO(tag))
+	}
+
+	bytes, err := json.Marshal(GetAllResponseDTO{Tags: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,87 @@
+package tag
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type RenameTagHttpHandler struct {
+	Service Service
+}
+
+func NewRenameTagHttpHandler(s Service) *RenameTagHttpHandler {
+	return &RenameTagHttpHandler{Service: s}
+}
+
+type RenameTagRequestDTO struct {
+	Name string `json:"name"`
+}
+
+func (h RenameTagHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := RenameTagRequestDTO{}
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.Unmarshal(bytes, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tagID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := RenameTagCommand{
+		UserID: userID,
+		TagID:  tagID,
+		Name:   dto.Name,
+	}
+
+	tag, err := h.Service.Rename(r.Context(), &cmd)
+	if errors.Is(err, ErrTagNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidName) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrNameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(newResponseTagDTO(tag))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
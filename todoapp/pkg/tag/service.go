@@ -0,0 +1,164 @@
+package tag
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"strings"
+	"unicode/utf8"
+)
+
+const MaxNameLength = 50
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrInvalidName = errors.New("tag names must be 1 to 50 characters without commas")
+	ErrNameTaken   = errors.New("a tag with this name already exists")
+)
+
+type Tag struct {
+	TagID     int
+	UserID    int
+	Name      string
+	TodoCount int
+}
+
+type Service interface {
+	GetAll(ctx context.Context, cmd *GetAllTagsCommand) ([]*Tag, error)
+	Create(ctx context.Context, cmd *CreateTagCommand) (*Tag, error)
+	Rename(ctx context.Context, cmd *RenameTagCommand) (*Tag, error)
+	Delete(ctx context.Context, cmd *DeleteTagCommand) (*Tag, error)
+}
+
+type GetAllTagsCommand struct {
+	UserID int
+}
+
+type CreateTagCommand struct {
+	UserID int
+	Name   string
+}
+
+type RenameTagCommand struct {
+	UserID int
+	TagID  int
+	Name   string
+}
+
+type DeleteTagCommand struct {
+	UserID int
+	TagID  int
+}
+
+// NormalizeName returns the canonical form tag names are stored with, so that "Backend"
+// and " backend " are the same tag. Commas are rejected since tag filters are comma separated.
+func NormalizeName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength || strings.Contains(name, ",") {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// NormalizeNames normalizes a list of tag names, dropping duplicates.
+func NormalizeNames(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		n, err := NormalizeName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	return normalized, nil
+}
+
+// tagColumns is the column list every query returning a Tag selects, in the order scanTag expects.
+const tagColumns = `tag_id, user_id, name,
+	(select count(*) from todo_tags tt join todos t on t.todo_id = tt.todo_id where tt.tag_id = tags.tag_id and t.deleted_at is null)`
+
+func scanTag(row *pgx.Row) (*Tag, error) {
+	var tag Tag
+	err := row.Scan(&tag.TagID, &tag.UserID, &tag.Name, &tag.TodoCount)
+	if err == pgx.ErrNoRows {
+		return nil, ErrTagNotFound
+	}
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23505" {
+		return nil, ErrNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+type ServiceImpl struct {
+	conn *pgx.ConnPool
+}
+
+func NewServiceImpl(conn *pgx.ConnPool) *ServiceImpl {
+	return &ServiceImpl{
+		conn: conn,
+	}
+}
+
+func (s ServiceImpl) GetAll(ctx context.Context, cmd *GetAllTagsCommand) ([]*Tag, error) {
+
+	query := `select tg.tag_id, tg.user_id, tg.name, count(t.todo_id)
+		from tags tg left join todo_tags tt on tt.tag_id = tg.tag_id
+		left join todos t on t.todo_id = tt.todo_id and t.deleted_at is null
+		where tg.user_id = $1
+		group by tg.tag_id
+		order by tg.name`
+
+	rows, err := s.conn.Query(query, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]*Tag, 0)
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag.TagID, &tag.UserID, &tag.Name, &tag.TodoCount)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s ServiceImpl) Create(ctx context.Context, cmd *CreateTagCommand) (*Tag, error) {
+
+	name, err := NormalizeName(cmd.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `insert into tags(user_id, name) values ($1, $2) returning ` + tagColumns
+	return scanTag(s.conn.QueryRow(query, cmd.UserID, name))
+}
+
+func (s ServiceImpl) Rename(ctx context.Context, cmd *RenameTagCommand) (*Tag, error) {
+
+	name, err := NormalizeName(cmd.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `update tags set name = $1 where tag_id = $2 and user_id = $3 returning ` + tagColumns
+	return scanTag(s.conn.QueryRow(query, name, cmd.TagID, cmd.UserID))
+}
+
+func (s ServiceImpl) Delete(ctx context.Context, cmd *DeleteTagCommand) (*Tag, error) {
+
+	query := `delete from tags where tag_id = $1 and user_id = $2 returning ` + tagColumns
+	return scanTag(s.conn.QueryRow(query, cmd.TagID, cmd.UserID))
+}
@@ -0,0 +1,67 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type AddCommentHttpHandler struct {
+	Service CommentService
+}
+
+func NewAddCommentHttpHandler(s CommentService) *AddCommentHttpHandler {
+	return &AddCommentHttpHandler{Service: s}
+}
+
+type CommentRequestDTO struct {
+	Body string `json:"body"`
+}
+
+func (h AddCommentHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := CommentRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	comment, err := h.Service.AddComment(r.Context(), &AddCommentCommand{UserID: userID, TodoID: todoID, Body: dto.Body})
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidComment) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseCommentDTO(comment))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,79 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type AddItemHttpHandler struct {
+	Service ChecklistService
+}
+
+func NewAddItemHttpHandler(s ChecklistService) *AddItemHttpHandler {
+	return &AddItemHttpHandler{Service: s}
+}
+
+type AddItemRequestDTO struct {
+	Title string `json:"title"`
+}
+
+func (h AddItemHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := AddItemRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := AddItemCommand{
+		UserID: userID,
+		TodoID: todoID,
+		Title:  dto.Title,
+	}
+
+	item, err := h.Service.AddItem(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrEmptyItemTitle) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseItemDTO(item))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,135 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+)
+
+const (
+	// BatchAtomic applies all the operations of a batch or none of them.
+	BatchAtomic = "atomic"
+	// BatchBestEffort applies the operations of a batch that succeed, and skips those that fail.
+	BatchBestEffort = "best_effort"
+
+	MaxBatchOperations = 100
+)
+
+var (
+	ErrInvalidBatchMode = errors.New("mode must be atomic or best_effort")
+	ErrBatchSize        = errors.New("a batch must have between 1 and 100 operations")
+	ErrBatchAborted     = errors.New("not applied because another operation of the batch failed")
+)
+
+type BatchService interface {
+	Batch(ctx context.Context, cmd *BatchCommand) (*BatchResult, error)
+}
+
+// BatchCommand applies operations to the todos of a user in one transaction. The user of the
+// command of each operation is replaced by UserID.
+type BatchCommand struct {
+	UserID     int
+	Mode       string
+	Operations []BatchOperation
+}
+
+// BatchOperation is one operation of a batch. Exactly one of its commands is set.
+type BatchOperation struct {
+	Create   *CreateTodoCommand
+	Update   *UpdateTodoCommand
+	Delete   *DeleteTodoCommand
+	Complete *CompleteTodoCommand
+	Reopen   *ReopenTodoCommand
+}
+
+// BatchResult holds the outcome of each operation, in the order of the command. When an atomic
+// batch fails, nothing is committed, and every operation but the failed one has ErrBatchAborted.
+type BatchResult struct {
+	Committed  bool
+	Operations []OperationResult
+}
+
+type OperationResult struct {
+	Todo *Todo
+	Err  error
+}
+
+func (s ServiceImpl) run(tx *pgx.Tx, op BatchOperation, userID int) (*Todo, error) {
+	switch {
+	case op.Create != nil:
+		op.Create.UserID = userID
+		return s.create(tx, op.Create)
+	case op.Update != nil:
+		op.Update.UserID = userID
+		return s.update(tx, op.Update)
+	case op.Delete != nil:
+		op.Delete.UserID = userID
+		return s.remove(tx, op.Delete)
+	case op.Complete != nil:
+		op.Complete.UserID = userID
+		return s.complete(tx, op.Complete)
+	case op.Reopen != nil:
+		op.Reopen.UserID = userID
+		return s.reopen(tx, op.Reopen)
+	default:
+		return nil, errors.New("batch operation without a command")
+	}
+}
+
+// Batch runs the operations in order, so an operation sees the changes of the ones before it.
+// In best effort mode each operation runs in a savepoint, which is rolled back when it fails.
+func (s ServiceImpl) Batch(ctx context.Context, cmd *BatchCommand) (*BatchResult, error) {
+
+	if cmd.Mode != BatchAtomic && cmd.Mode != BatchBestEffort {
+		return nil, ErrInvalidBatchMode
+	}
+	if len(cmd.Operations) == 0 || len(cmd.Operations) > MaxBatchOperations {
+		return nil, ErrBatchSize
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &BatchResult{Operations: make([]OperationResult, len(cmd.Operations))}
+	for i, op := range cmd.Operations {
+		if cmd.Mode == BatchAtomic {
+			todo, err := s.run(tx, op, cmd.UserID)
+			if err != nil {
+				for j := range result.Operations {
+					result.Operations[j] = OperationResult{Err: ErrBatchAborted}
+				}
+				result.Operations[i].Err = err
+				return result, nil
+			}
+			result.Operations[i].Todo = todo
+			continue
+		}
+
+		_, err = tx.Exec(`savepoint batch_operation`)
+		if err != nil {
+			return nil, err
+		}
+		todo, err := s.run(tx, op, cmd.UserID)
+		if err != nil {
+			result.Operations[i].Err = err
+			_, err = tx.Exec(`rollback to savepoint batch_operation`)
+		} else {
+			result.Operations[i].Todo = todo
+			_, err = tx.Exec(`release savepoint batch_operation`)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	result.Committed = true
+
+	return result, nil
+}
@@ -0,0 +1,204 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/policy"
+	"kuberneteslab/todoapp/pkg/tag"
+	"net/http"
+)
+
+type BatchTodosHttpHandler struct {
+	Service BatchService
+}
+
+func NewBatchTodosHttpHandler(s BatchService) *BatchTodosHttpHandler {
+	return &BatchTodosHttpHandler{Service: s}
+}
+
+// BatchTodosRequestDTO is a list of operations on todos. Mode is atomic, the default, or best_effort.
+type BatchTodosRequestDTO struct {
+	Mode       string              `json:"mode"`
+	Operations []BatchOperationDTO `json:"operations"`
+}
+
+// BatchOperationDTO is one operation of a batch: create, update, delete, complete or reopen.
+// Todo is the new todo of a create, and the merge patch of an update. Version, when set, is
+// checked by updates and deletes like an If-Match header.
+type BatchOperationDTO struct {
+	Op      string          `json:"op"`
+	TodoID  int             `json:"todo_id,omitempty"`
+	Version *int            `json:"version,omitempty"`
+	Todo    json.RawMessage `json:"todo,omitempty"`
+}
+
+type BatchTodosResponseDTO struct {
+	Committed bool                      `json:"committed"`
+	Results   []BatchOperationResultDTO `json:"results"`
+}
+
+// BatchOperationResultDTO is the outcome of an operation, with the status the single operation
+// endpoint would have answered.
+type BatchOperationResultDTO struct {
+	Op     string           `json:"op"`
+	Status int              `json:"status"`
+	Error  string           `json:"error,omitempty"`
+	Todo   *ResponseTodoDTO `json:"todo,omitempty"`
+}
+
+func newBatchOperation(dto BatchOperationDTO) (BatchOperation, error) {
+
+	if dto.Op != "create" && dto.TodoID == 0 {
+		return BatchOperation{}, errMissingTodoID
+	}
+
+	switch dto.Op {
+	case "create":
+		todo := CreateTodoRequestDTO{}
+		err := json.Unmarshal(dto.Todo, &todo)
+		if err != nil {
+			return BatchOperation{}, errors.New("todo must be an object")
+		}
+		return BatchOperation{Create: &CreateTodoCommand{
+			ListID:       todo.ListID,
+			AssigneeID:   todo.AssigneeID,
+			Title:        todo.Title,
+			Content:      todo.Content,
+			DueAt:        todo.DueAt,
+			Priority:     todo.Priority,
+			Tags:         todo.Tags,
+			Recurrence:   todo.Recurrence,
+			RecurrenceTZ: todo.RecurrenceTZ,
+		}}, nil
+	case "update":
+		fields := map[string]json.RawMessage{}
+		err := json.Unmarshal(dto.Todo, &fields)
+		if err != nil {
+			return BatchOperation{}, invalidPatch("a merge patch must be an object")
+		}
+		cmd := &UpdateTodoCommand{TodoID: dto.TodoID, IfMatch: atVersion(dto.Version)}
+		err = applyFields(cmd, fields)
+		if err != nil {
+			return BatchOperation{}, err
+		}
+		return BatchOperation{Update: cmd}, nil
+	case "delete":
+		return BatchOperation{Delete: &DeleteTodoCommand{TodoID: dto.TodoID, IfMatch: atVersion(dto.Version)}}, nil
+	case "complete":
+		return BatchOperation{Complete: &CompleteTodoCommand{TodoID: dto.TodoID}}, nil
+	case "reopen":
+		return BatchOperation{Reopen: &ReopenTodoCommand{TodoID: dto.TodoID}}, nil
+	default:
+		return BatchOperation{}, fmt.Errorf("unknown op %q", dto.Op)
+	}
+}
+
+// atVersion returns the versions an operation checked against version accepts.
+func atVersion(version *int) Versions {
+	if version == nil {
+		return nil
+	}
+	return Versions{*version}
+}
+
+// operationStatus maps the error of an operation to the status of its result.
+func operationStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrBatchAborted):
+		return http.StatusFailedDependency
+	case errors.Is(err, ErrTodoNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, policy.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrInvalidPriority) || errors.Is(err, tag.ErrInvalidName) || errors.Is(err, errInvalidPatch) || errors.Is(err, ErrInvalidAssignee) ||
+		errors.Is(err, list.ErrListNotFound) || errors.Is(err, list.ErrListArchived) || isRecurrenceError(err):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ServeHTTP answers 200 when the batch was committed, even if some best effort operations
+// failed. When an atomic batch is rolled back, it answers with the status of the failed operation.
+func (h BatchTodosHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := BatchTodosRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := BatchCommand{
+		UserID:     userID,
+		Mode:       dto.Mode,
+		Operations: make([]BatchOperation, len(dto.Operations)),
+	}
+	if cmd.Mode == "" {
+		cmd.Mode = BatchAtomic
+	}
+	for i, op := range dto.Operations {
+		cmd.Operations[i], err = newBatchOperation(op)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("operation %d: %v", i, err)))
+			return
+		}
+	}
+
+	result, err := h.Service.Batch(r.Context(), &cmd)
+	if errors.Is(err, ErrInvalidBatchMode) || errors.Is(err, ErrBatchSize) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	responseDTO := BatchTodosResponseDTO{
+		Committed: result.Committed,
+		Results:   make([]BatchOperationResultDTO, len(result.Operations)),
+	}
+	for i, op := range result.Operations {
+		res := BatchOperationResultDTO{
+			Op:     dto.Operations[i].Op,
+			Status: operationStatus(op.Err),
+		}
+		if op.Todo != nil {
+			todo := newResponseTodoDTO(op.Todo)
+			res.Todo = &todo
+		}
+		if op.Err != nil && res.Status != http.StatusInternalServerError {
+			res.Error = op.Err.Error()
+		}
+		if !result.Committed && res.Status != http.StatusFailedDependency {
+			status = res.Status
+		}
+		responseDTO.Results[i] = res
+	}
+
+	bytes, err := json.Marshal(responseDTO)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
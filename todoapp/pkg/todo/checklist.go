@@ -0,0 +1,321 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/policy"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrItemNotFound     = errors.New("checklist item not found")
+	ErrEmptyItemTitle   = errors.New("checklist item title must not be empty")
+	ErrInvalidItemOrder = errors.New("item_ids must list every checklist item of the todo exactly once")
+)
+
+// Item is one step of the checklist of a todo. Items are listed by ascending Position.
+type Item struct {
+	ItemID   int
+	TodoID   int
+	Title    string
+	Done     bool
+	Position int
+}
+
+type ChecklistService interface {
+	GetItems(ctx context.Context, cmd *GetItemsCommand) ([]*Item, error)
+	AddItem(ctx context.Context, cmd *AddItemCommand) (*Item, error)
+	UpdateItem(ctx context.Context, cmd *UpdateItemCommand) (*Item, error)
+	ToggleItem(ctx context.Context, cmd *ToggleItemCommand) (*Item, error)
+	ReorderItems(ctx context.Context, cmd *ReorderItemsCommand) ([]*Item, error)
+	DeleteItem(ctx context.Context, cmd *DeleteItemCommand) (*Item, error)
+}
+
+type GetItemsCommand struct {
+	UserID int
+	TodoID int
+}
+
+type AddItemCommand struct {
+	UserID int
+	TodoID int
+	Title  string
+}
+
+// UpdateItemCommand changes the fields of an item that are not nil.
+type UpdateItemCommand struct {
+	UserID int
+	TodoID int
+	ItemID int
+	Title  *string
+	Done   *bool
+}
+
+type ToggleItemCommand struct {
+	UserID int
+	TodoID int
+	ItemID int
+}
+
+// ReorderItemsCommand moves the items of a todo to the order of ItemIDs.
+type ReorderItemsCommand struct {
+	UserID  int
+	TodoID  int
+	ItemIDs []int
+}
+
+type DeleteItemCommand struct {
+	UserID int
+	TodoID int
+	ItemID int
+}
+
+const itemColumns = `item_id, todo_id, title, done, position`
+
+func scanItem(row scanner) (*Item, error) {
+	var item Item
+	err := row.Scan(&item.ItemID, &item.TodoID, &item.Title, &item.Done, &item.Position)
+	if err == pgx.ErrNoRows {
+		return nil, ErrItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// lockEditableTodo checks the user can edit the todo and locks it for the rest of the transaction,
+// so concurrent checklist changes of the same todo are serialized. It also bumps the todo
+// version and updated_at, since its checklist is part of it.
+func lockEditableTodo(tx *pgx.Tx, userID int, todoID int) error {
+	_, err := lockTodo(tx, userID, todoID, policy.PermissionEdit)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`update todos set version = version + 1, updated_at = $1 where todo_id = $2`, time.Now(), todoID)
+	return err
+}
+
+// checklistChanged tells the hooks the todo changed once its checklist was, so that they see its
+// new checklist progress.
+func (s ServiceImpl) checklistChanged(tx *pgx.Tx, todoID int) error {
+	todo, err := fetch(tx, todoID)
+	if err != nil {
+		return err
+	}
+	return s.changed(tx, events.TodoUpdated, todo)
+}
+
+func getItems(tx *pgx.Tx, todoID int) ([]*Item, error) {
+	rows, err := tx.Query(`select `+itemColumns+` from checklist_items where todo_id = $1 order by position, item_id`, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*Item, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s ServiceImpl) GetItems(ctx context.Context, cmd *GetItemsCommand) ([]*Item, error) {
+
+	var items []*Item
+	err := s.withTx(func(tx *pgx.Tx) error {
+		todo, err := accessTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionView)
+		if err != nil {
+			return err
+		}
+
+		items, err = getItems(tx, todo.TodoID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s ServiceImpl) AddItem(ctx context.Context, cmd *AddItemCommand) (*Item, error) {
+
+	title := strings.TrimSpace(cmd.Title)
+	if title == "" {
+		return nil, ErrEmptyItemTitle
+	}
+
+	var item *Item
+	err := s.withTx(func(tx *pgx.Tx) error {
+		err := lockEditableTodo(tx, cmd.UserID, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		query := `insert into checklist_items(todo_id, title, position)
+			values ($1, $2, (select coalesce(max(position), 0) + 1 from checklist_items where todo_id = $1))
+			returning ` + itemColumns
+		item, err = scanItem(tx.QueryRow(query, cmd.TodoID, title))
+		if err != nil {
+			return err
+		}
+		return s.checklistChanged(tx, cmd.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (s ServiceImpl) UpdateItem(ctx context.Context, cmd *UpdateItemCommand) (*Item, error) {
+
+	var title *string
+	if cmd.Title != nil {
+		t := strings.TrimSpace(*cmd.Title)
+		if t == "" {
+			return nil, ErrEmptyItemTitle
+		}
+		title = &t
+	}
+
+	var item *Item
+	err := s.withTx(func(tx *pgx.Tx) error {
+		err := lockEditableTodo(tx, cmd.UserID, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		query := `update checklist_items set title = coalesce($1, title), done = coalesce($2, done), updated_at = $3
+			where item_id = $4 and todo_id = $5 returning ` + itemColumns
+		item, err = scanItem(tx.QueryRow(query, title, cmd.Done, time.Now(), cmd.ItemID, cmd.TodoID))
+		if err != nil {
+			return err
+		}
+		return s.checklistChanged(tx, cmd.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (s ServiceImpl) ToggleItem(ctx context.Context, cmd *ToggleItemCommand) (*Item, error) {
+
+	var item *Item
+	err := s.withTx(func(tx *pgx.Tx) error {
+		err := lockEditableTodo(tx, cmd.UserID, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		query := `update checklist_items set done = not done, updated_at = $1 where item_id = $2 and todo_id = $3 returning ` + itemColumns
+		item, err = scanItem(tx.QueryRow(query, time.Now(), cmd.ItemID, cmd.TodoID))
+		if err != nil {
+			return err
+		}
+		return s.checklistChanged(tx, cmd.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (s ServiceImpl) ReorderItems(ctx context.Context, cmd *ReorderItemsCommand) ([]*Item, error) {
+
+	var items []*Item
+	err := s.withTx(func(tx *pgx.Tx) error {
+		err := lockEditableTodo(tx, cmd.UserID, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		current, err := getItems(tx, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+		if !sameItems(current, cmd.ItemIDs) {
+			return ErrInvalidItemOrder
+		}
+
+		query := `update checklist_items set position = array_position($1::bigint[], item_id), updated_at = $2 where todo_id = $3`
+		_, err = tx.Exec(query, int64s(cmd.ItemIDs), time.Now(), cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		items, err = getItems(tx, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+		return s.checklistChanged(tx, cmd.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s ServiceImpl) DeleteItem(ctx context.Context, cmd *DeleteItemCommand) (*Item, error) {
+
+	var item *Item
+	err := s.withTx(func(tx *pgx.Tx) error {
+		err := lockEditableTodo(tx, cmd.UserID, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		query := `delete from checklist_items where item_id = $1 and todo_id = $2 returning ` + itemColumns
+		item, err = scanItem(tx.QueryRow(query, cmd.ItemID, cmd.TodoID))
+		if err != nil {
+			return err
+		}
+		return s.checklistChanged(tx, cmd.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func sameItems(items []*Item, itemIDs []int) bool {
+	if len(items) != len(itemIDs) {
+		return false
+	}
+	current := make([]int, 0, len(items))
+	for _, item := range items {
+		current = append(current, item.ItemID)
+	}
+	requested := append([]int(nil), itemIDs...)
+	sort.Ints(current)
+	sort.Ints(requested)
+	for i := range current {
+		if current[i] != requested[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// int64s converts ids for bigint[] query arguments, which pgx can't encode from []int.
+func int64s(ids []int) []int64 {
+	converted := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		converted = append(converted, int64(id))
+	}
+	return converted
+}
@@ -0,0 +1,223 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/policy"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const MaxCommentLength = 10000
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrInvalidComment  = errors.New("comments must be 1 to 10000 characters")
+)
+
+// Comment is a message of a user about a todo. EditedAt is set once its body has been changed.
+type Comment struct {
+	CommentID int
+	TodoID    int
+	UserID    int
+	UserName  string
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	EditedAt  *time.Time
+}
+
+// CommentService manages the comments of todos, which any user who can view a todo can read
+// and write. Comments are edited by their author, and deleted by their author or the owner of the todo.
+type CommentService interface {
+	GetComments(ctx context.Context, cmd *GetCommentsCommand) ([]*Comment, error)
+	AddComment(ctx context.Context, cmd *AddCommentCommand) (*Comment, error)
+	UpdateComment(ctx context.Context, cmd *UpdateCommentCommand) (*Comment, error)
+	DeleteComment(ctx context.Context, cmd *DeleteCommentCommand) (*Comment, error)
+}
+
+type GetCommentsCommand struct {
+	UserID int
+	TodoID int
+}
+
+type AddCommentCommand struct {
+	UserID int
+	TodoID int
+	Body   string
+}
+
+type UpdateCommentCommand struct {
+	UserID    int
+	TodoID    int
+	CommentID int
+	Body      string
+}
+
+type DeleteCommentCommand struct {
+	UserID    int
+	TodoID    int
+	CommentID int
+}
+
+// commentColumns is the column list every query returning a Comment selects from a comment c
+// joined with its author u, in the order scanComment expects.
+const commentColumns = `c.comment_id, c.todo_id, c.user_id, u.username, c.body, c.created_at, c.updated_at, c.edited_at`
+
+func scanComment(row scanner) (*Comment, error) {
+	var comment Comment
+	err := row.Scan(&comment.CommentID, &comment.TodoID, &comment.UserID, &comment.UserName, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt, &comment.EditedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func normalizeComment(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxCommentLength {
+		return "", ErrInvalidComment
+	}
+	return body, nil
+}
+
+// accessTodo reads a todo that isn't in the trash and checks the user has permission p on it,
+// without locking it.
+func accessTodo(q policy.Querier, userID int, todoID int, p policy.Permission) (*Todo, error) {
+	todo, err := scanTodo(q.QueryRow(`select `+todoColumns+` from todos where todo_id = $1 and deleted_at is null`, todoID))
+	if err != nil {
+		return nil, err
+	}
+	err = authorize(q, userID, todo, p)
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// lockComment reads a comment of a todo and locks it for the rest of the transaction.
+func lockComment(tx *pgx.Tx, todoID int, commentID int) (*Comment, error) {
+	query := `select ` + commentColumns + ` from todo_comments c join users u on u.user_id = c.user_id
+		where c.comment_id = $1 and c.todo_id = $2 for update of c`
+	return scanComment(tx.QueryRow(query, commentID, todoID))
+}
+
+// GetComments lists the comments of a todo, oldest first.
+func (s ServiceImpl) GetComments(ctx context.Context, cmd *GetCommentsCommand) ([]*Comment, error) {
+
+	_, err := accessTodo(s.conn, cmd.UserID, cmd.TodoID, policy.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + commentColumns + ` from todo_comments c join users u on u.user_id = c.user_id
+		where c.todo_id = $1 order by c.comment_id`
+	rows, err := s.conn.Query(query, cmd.TodoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]*Comment, 0)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+func (s ServiceImpl) AddComment(ctx context.Context, cmd *AddCommentCommand) (*Comment, error) {
+
+	body, err := normalizeComment(cmd.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var comment *Comment
+	err = s.withTx(func(tx *pgx.Tx) error {
+		_, err := accessTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionView)
+		if err != nil {
+			return err
+		}
+
+		query := `with c as (insert into todo_comments(todo_id, user_id, body) values ($1, $2, $3) returning *)
+			select ` + commentColumns + ` from c join users u on u.user_id = c.user_id`
+		comment, err = scanComment(tx.QueryRow(query, cmd.TodoID, cmd.UserID, body))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (s ServiceImpl) UpdateComment(ctx context.Context, cmd *UpdateCommentCommand) (*Comment, error) {
+
+	body, err := normalizeComment(cmd.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var comment *Comment
+	err = s.withTx(func(tx *pgx.Tx) error {
+		_, err := accessTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionView)
+		if err != nil {
+			return err
+		}
+		current, err := lockComment(tx, cmd.TodoID, cmd.CommentID)
+		if err != nil {
+			return err
+		}
+		if current.UserID != cmd.UserID {
+			return policy.ErrForbidden
+		}
+
+		query := `with c as (update todo_comments set body = $1, updated_at = $2,
+				edited_at = case when body = $1 then edited_at else $2 end
+				where comment_id = $3 returning *)
+			select ` + commentColumns + ` from c join users u on u.user_id = c.user_id`
+		comment, err = scanComment(tx.QueryRow(query, body, time.Now(), cmd.CommentID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (s ServiceImpl) DeleteComment(ctx context.Context, cmd *DeleteCommentCommand) (*Comment, error) {
+
+	var comment *Comment
+	err := s.withTx(func(tx *pgx.Tx) error {
+		todo, err := accessTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionView)
+		if err != nil {
+			return err
+		}
+		comment, err = lockComment(tx, cmd.TodoID, cmd.CommentID)
+		if err != nil {
+			return err
+		}
+		if comment.UserID != cmd.UserID && todo.UserID != cmd.UserID {
+			return policy.ErrForbidden
+		}
+
+		_, err = tx.Exec(`delete from todo_comments where comment_id = $1`, cmd.CommentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
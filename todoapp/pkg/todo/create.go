@@ -2,11 +2,12 @@ package todo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/policy"
 	"net/http"
-	"strconv"
+	"time"
 )
 
 type CreateTodoHttpHandler struct {
@@ -18,15 +19,32 @@ func NewCreateTodoHttpHandler(s Service) *CreateTodoHttpHandler {
 }
 
 type CreateTodoRequestDTO struct {
-	UserID  int    `json:"user_id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	UserID       int        `json:"user_id"`
+	ListID       *int       `json:"list_id,omitempty"`
+	AssigneeID   *int       `json:"assignee_id,omitempty"`
+	Title        string     `json:"title"`
+	Content      string     `json:"content"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Priority     int        `json:"priority"`
+	Tags         []string   `json:"tags"`
+	Recurrence   string     `json:"recurrence"`
+	RecurrenceTZ string     `json:"recurrence_tz"`
 }
 
 type CreateTodoResponseDTO struct {
-	TodoID  int    `json:"todo_id"`
-	Name    string `json:"title"`
-	Content string `json:"content"`
+	TodoID       int        `json:"todo_id"`
+	ListID       *int       `json:"list_id,omitempty"`
+	AssigneeID   *int       `json:"assignee_id,omitempty"`
+	Name         string     `json:"title"`
+	Content      string     `json:"content"`
+	Completed    bool       `json:"completed"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Priority     int        `json:"priority"`
+	Tags         []string   `json:"tags"`
+	Version      int        `json:"version"`
+	Recurrence   *string    `json:"recurrence,omitempty"`
+	RecurrenceTZ *string    `json:"recurrence_tz,omitempty"`
 }
 
 func (h CreateTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -44,22 +62,32 @@ func (h CreateTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 
-	tokenUserID := r.Header.Get(middlewares.HeaderKeyUserID)
-	userID := strconv.Itoa(dto.UserID)
-
-	if tokenUserID != userID {
+	userID, err := requestUserID(r, dto.UserID)
+	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte("can't create todos for another user"))
 		return
 	}
 
 	cmd := CreateTodoCommand{
-		UserID:  dto.UserID,
-		Title:   dto.Title,
-		Content: dto.Content,
+		UserID:       userID,
+		ListID:       dto.ListID,
+		AssigneeID:   dto.AssigneeID,
+		Title:        dto.Title,
+		Content:      dto.Content,
+		DueAt:        dto.DueAt,
+		Priority:     dto.Priority,
+		Tags:         dto.Tags,
+		Recurrence:   dto.Recurrence,
+		RecurrenceTZ: dto.RecurrenceTZ,
 	}
 
 	todo, err := h.Service.Create(r.Context(), &cmd)
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(fmt.Sprintf("error todo service create %v", err.Error())))
@@ -67,15 +95,26 @@ func (h CreateTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	responseDTO := CreateTodoResponseDTO{
-		TodoID:  todo.TodoID,
-		Name:    todo.Title,
-		Content: todo.Content,
+		TodoID:       todo.TodoID,
+		ListID:       todo.ListID,
+		AssigneeID:   todo.AssigneeID,
+		Name:         todo.Title,
+		Content:      todo.Content,
+		Completed:    todo.Completed,
+		CompletedAt:  todo.CompletedAt,
+		DueAt:        todo.DueAt,
+		Priority:     todo.Priority,
+		Tags:         todo.Tags,
+		Version:      todo.Version,
+		Recurrence:   todo.RecurrenceRule,
+		RecurrenceTZ: todo.RecurrenceTZ,
 	}
 	bytes, err = json.Marshal(responseDTO)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 	}
 
+	w.Header().Set("ETag", todoETag(todo))
 	w.WriteHeader(http.StatusOK)
 	w.Write(bytes)
 }
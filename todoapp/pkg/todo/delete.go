@@ -2,10 +2,9 @@ package todo
 
 import (
 	"encoding/json"
-	"io"
-	"kuberneteslab/todoapp/pkg/middlewares"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
 	"net/http"
-	"strconv"
 )
 
 type DeleteTodoHttpHandler struct {
@@ -31,33 +30,54 @@ type DeleteTodoResponseDTO struct {
 func (h DeleteTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	dto := DeleteTodoResquestDTO{}
-	bytes, err := io.ReadAll(r.Body)
+	err := decodeBody(r, &dto)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	err = json.Unmarshal(bytes, &dto)
+
+	userID, err := requestUserID(r, dto.UserID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("can't delete todos for another user"))
 		return
 	}
-	defer r.Body.Close()
 
-	tokenUserID := r.Header.Get(middlewares.HeaderKeyUserID)
-	userID := strconv.Itoa(dto.UserID)
+	todoID, err := requestTodoID(r, dto.TodoID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
 
-	if tokenUserID != userID {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("can't delete todos for another user"))
+	ifMatch, err := requestIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, err)
 		return
 	}
 
 	cmd := DeleteTodoCommand{
-		TodoID: dto.TodoID,
-		UserID: dto.UserID,
+		TodoID:  todoID,
+		UserID:  userID,
+		IfMatch: ifMatch,
 	}
 
 	todo, err := h.Service.Delete(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(err.Error()))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -70,7 +90,7 @@ func (h DeleteTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		Content: todo.Content,
 	}
 
-	bytes, err = json.Marshal(responseDTO)
+	bytes, err := json.Marshal(responseDTO)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -0,0 +1,64 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type DeleteCommentHttpHandler struct {
+	Service CommentService
+}
+
+func NewDeleteCommentHttpHandler(s CommentService) *DeleteCommentHttpHandler {
+	return &DeleteCommentHttpHandler{Service: s}
+}
+
+func (h DeleteCommentHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	commentID, err := requestCommentID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	comment, err := h.Service.DeleteComment(r.Context(), &DeleteCommentCommand{UserID: userID, TodoID: todoID, CommentID: commentID})
+	if errors.Is(err, ErrTodoNotFound) || errors.Is(err, ErrCommentNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseCommentDTO(comment))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
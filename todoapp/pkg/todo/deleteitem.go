@@ -0,0 +1,64 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type DeleteItemHttpHandler struct {
+	Service ChecklistService
+}
+
+func NewDeleteItemHttpHandler(s ChecklistService) *DeleteItemHttpHandler {
+	return &DeleteItemHttpHandler{Service: s}
+}
+
+func (h DeleteItemHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	itemID, err := requestItemID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	item, err := h.Service.DeleteItem(r.Context(), &DeleteItemCommand{UserID: userID, TodoID: todoID, ItemID: itemID})
+	if errors.Is(err, ErrTodoNotFound) || errors.Is(err, ErrItemNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseItemDTO(item))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
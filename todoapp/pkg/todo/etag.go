@@ -0,0 +1,94 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMissingIfMatch = errors.New("If-Match header with the ETag of the todo is required")
+	errInvalidIfMatch = errors.New("If-Match header does not match the ETag of the todo")
+)
+
+// todoETag is the strong ETag of a todo, which is its version.
+func todoETag(todo *Todo) string {
+	return fmt.Sprintf(`"%d"`, todo.Version)
+}
+
+// pageETag is a weak ETag of a page of todos, which changes whenever one of them does or the
+// page starts or ends with another todo.
+func pageETag(page *TodoPage) string {
+	h := fnv.New64a()
+	for _, todo := range page.Todos {
+		fmt.Fprintf(h, "%d:%d,", todo.TodoID, todo.Version)
+	}
+	h.Write([]byte(page.NextCursor))
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// requestIfMatch returns the versions the If-Match header of the request expects, a list of
+// ETags any of which will do, or nil when any version will do. Routes with the todo id in the
+// path require the header, while the deprecated /todo routes may leave it out.
+func requestIfMatch(r *http.Request) (Versions, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if chi.URLParam(r, "id") != "" {
+			return nil, errMissingIfMatch
+		}
+		return nil, nil
+	}
+
+	versions := Versions{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, nil
+		}
+		// If-Match uses the strong comparison, so weak or malformed ETags never match.
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, errInvalidIfMatch
+	}
+	return versions, nil
+}
+
+// notModified reports whether the If-None-Match header of the request matches etag, so the
+// copy the client has is still current.
+func notModified(r *http.Request, etag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIfMatchError writes the status of an If-Match header that can't be honoured.
+func writeIfMatchError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errMissingIfMatch) {
+		w.WriteHeader(http.StatusPreconditionRequired)
+	} else {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}
+	w.Write([]byte(err.Error()))
+}
@@ -0,0 +1,59 @@
+package todo
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIfMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		versions Versions
+		err      error
+	}{
+		{`"3"`, Versions{3}, nil},
+		{`"3", "4"`, Versions{3, 4}, nil},
+		{`"3","4"`, Versions{3, 4}, nil},
+		{`*`, nil, nil},
+		{`"3", *`, nil, nil},
+		{`W/"3", "4"`, Versions{4}, nil},
+		{`W/"3"`, nil, errInvalidIfMatch},
+		{`3`, nil, errInvalidIfMatch},
+		{`"three"`, nil, errInvalidIfMatch},
+		{``, nil, errMissingIfMatch},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			r := httptest.NewRequest("PATCH", "/todos/1", nil)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			if test.header != "" {
+				r.Header.Set("If-Match", test.header)
+			}
+
+			versions, err := requestIfMatch(r)
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.versions, versions)
+		})
+	}
+
+	t.Run("the /todo routes should not require the header", func(t *testing.T) {
+		versions, err := requestIfMatch(httptest.NewRequest("PUT", "/todo", nil))
+		require.NoError(t, err)
+		require.Nil(t, versions)
+	})
+}
+
+func TestVersionsAllow(t *testing.T) {
+	require.True(t, Versions(nil).Allow(3))
+	require.True(t, Versions{2, 3}.Allow(3))
+	require.False(t, Versions{2, 4}.Allow(3))
+}
@@ -0,0 +1,63 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type GetTodoHttpHandler struct {
+	Service Service
+}
+
+func NewGetTodoHttpHandler(s Service) *GetTodoHttpHandler {
+	return &GetTodoHttpHandler{Service: s}
+}
+
+func (h GetTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := GetTodoCommand{
+		UserID: userID,
+		TodoID: todoID,
+	}
+
+	todo, err := h.Service.Get(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	etag := todoETag(todo)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseTodoDTO(todo))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
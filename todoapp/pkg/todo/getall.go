@@ -2,9 +2,13 @@ package todo
 
 import (
 	"encoding/json"
-	"kuberneteslab/todoapp/pkg/middlewares"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type GetAllTodoHttpHandler struct {
@@ -16,60 +20,181 @@ func NewGetAllTodoHttpHandler(s Service) *GetAllTodoHttpHandler {
 }
 
 type GetAllResponseDTO struct {
-	Todos []ResponseTodoDTO `json:"todos"`
+	Todos      []ResponseTodoDTO `json:"todos"`
+	NextCursor string            `json:"next_cursor,omitempty"`
 }
 
 type ResponseTodoDTO struct {
-	TodoID  int    `json:"todo_id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	TodoID       int        `json:"todo_id"`
+	ListID       *int       `json:"list_id,omitempty"`
+	AssigneeID   *int       `json:"assignee_id,omitempty"`
+	Title        string     `json:"title"`
+	Content      string     `json:"content"`
+	Completed    bool       `json:"completed"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Priority     int        `json:"priority"`
+	Tags         []string   `json:"tags"`
+	Progress     int        `json:"progress"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	Version      int        `json:"version"`
+	Recurrence   *string    `json:"recurrence,omitempty"`
+	RecurrenceTZ *string    `json:"recurrence_tz,omitempty"`
+	NextTodoID   *int       `json:"next_todo_id,omitempty"`
+}
+
+func newResponseTodoDTO(todo *Todo) ResponseTodoDTO {
+	return ResponseTodoDTO{
+		TodoID:       todo.TodoID,
+		ListID:       todo.ListID,
+		AssigneeID:   todo.AssigneeID,
+		Title:        todo.Title,
+		Content:      todo.Content,
+		Completed:    todo.Completed,
+		CompletedAt:  todo.CompletedAt,
+		DueAt:        todo.DueAt,
+		Priority:     todo.Priority,
+		Tags:         todo.Tags,
+		Progress:     todo.Progress(),
+		CreatedAt:    todo.CreatedAt,
+		UpdatedAt:    todo.UpdatedAt,
+		DeletedAt:    todo.DeletedAt,
+		Version:      todo.Version,
+		Recurrence:   todo.RecurrenceRule,
+		RecurrenceTZ: todo.RecurrenceTZ,
+		NextTodoID:   todo.NextTodoID,
+	}
 }
 
 func (h GetAllTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	id := r.URL.Query().Get("user_id")
-	userID, err := strconv.Atoi(id)
+	claimedUserID := 0
+	if id := r.URL.Query().Get("user_id"); id != "" {
+		var err error
+		claimedUserID, err = strconv.Atoi(id)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad request"))
+			return
+		}
+	}
+
+	userID, err := requestUserID(r, claimedUserID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("bad request"))
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("can't get todos for another user"))
 		return
 	}
 
 	cmd := GetAllTodosCommand{
 		UserID: userID,
 	}
-
-	tokenUserID := r.Header.Get(middlewares.HeaderKeyUserID)
-	strUserID := strconv.Itoa(userID)
-
-	if tokenUserID != strUserID {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("can't get todos for another user"))
+	err = parseListQuery(r.URL.Query(), &cmd)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
 		return
 	}
 
-	todos, err := h.Service.GetAll(r.Context(), &cmd)
-	if err != nil {
+	page, err := h.Service.GetAll(r.Context(), &cmd)
+	if isListQueryError(err) {
 		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	res := make([]ResponseTodoDTO, 0, len(todos))
-	for _, todo := range todos {
-		res = append(res, ResponseTodoDTO{
-			TodoID:  todo.TodoID,
-			Title:   todo.Title,
-			Content: todo.Content,
-		})
+	etag := pageETag(page)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	res := make([]ResponseTodoDTO, 0, len(page.Todos))
+	for _, todo := range page.Todos {
+		res = append(res, newResponseTodoDTO(todo))
 	}
 
 	responseDTO := GetAllResponseDTO{
-		Todos: res,
+		Todos:      res,
+		NextCursor: page.NextCursor,
 	}
 	bytes, err := json.Marshal(responseDTO)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(bytes)
 }
+
+// parseListQuery reads the filter, sort and pagination query parameters of a todo listing.
+// Values are validated by the service, only their format is checked here.
+func parseListQuery(query url.Values, cmd *GetAllTodosCommand) error {
+
+	cmd.Status = query.Get("status")
+	cmd.Text = query.Get("q")
+	cmd.Sort = query.Get("sort")
+	cmd.Cursor = query.Get("cursor")
+	cmd.TagMatch = query.Get("tag_match")
+	if tags := query.Get("tags"); tags != "" {
+		cmd.Tags = strings.Split(tags, ",")
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		cmd.Desc = true
+	default:
+		return errors.New("order must be asc or desc")
+	}
+
+	switch assignee := query.Get("assignee"); assignee {
+	case "":
+	case "me":
+		cmd.AssignedToMe = true
+	default:
+		return errors.New("assignee must be me")
+	}
+
+	switch listID := query.Get("list_id"); listID {
+	case "":
+	case "none":
+		cmd.NoList = true
+	default:
+		n, err := strconv.Atoi(listID)
+		if err != nil {
+			return errors.New("list_id must be a list id or none")
+		}
+		cmd.ListID = &n
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return ErrInvalidLimit
+		}
+		cmd.Limit = n
+	}
+
+	for param, dst := range map[string]**time.Time{"due_after": &cmd.DueAfter, "due_before": &cmd.DueBefore} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("%s must be an RFC 3339 date", param)
+		}
+		*dst = &t
+	}
+
+	return nil
+}
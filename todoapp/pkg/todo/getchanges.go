@@ -0,0 +1,93 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type GetChangesHttpHandler struct {
+	Service SyncService
+}
+
+func NewGetChangesHttpHandler(s SyncService) *GetChangesHttpHandler {
+	return &GetChangesHttpHandler{Service: s}
+}
+
+// ChangeDTO is a todo that changed since the last sync. A deleted todo only has its id.
+type ChangeDTO struct {
+	TodoID    int              `json:"todo_id"`
+	Deleted   bool             `json:"deleted"`
+	ChangedAt time.Time        `json:"changed_at"`
+	Todo      *ResponseTodoDTO `json:"todo,omitempty"`
+}
+
+// GetChangesResponseDTO is a page of changes. Token is the since of the next sync, which must
+// be made right away while HasMore. A change may be returned again by the next sync, and should
+// be ignored unless its version is newer.
+type GetChangesResponseDTO struct {
+	Changes []ChangeDTO `json:"changes"`
+	Token   string      `json:"token"`
+	HasMore bool        `json:"has_more"`
+}
+
+func (h GetChangesHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := GetChangesCommand{
+		UserID: userID,
+		Since:  r.URL.Query().Get("since"),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		cmd.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("limit must be a number"))
+			return
+		}
+	}
+
+	page, err := h.Service.Changes(r.Context(), &cmd)
+	if errors.Is(err, ErrInvalidSyncToken) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := GetChangesResponseDTO{
+		Changes: make([]ChangeDTO, len(page.Changes)),
+		Token:   page.Token,
+		HasMore: page.HasMore,
+	}
+	for i, change := range page.Changes {
+		responseDTO.Changes[i] = ChangeDTO{
+			TodoID:    change.TodoID,
+			Deleted:   change.Deleted(),
+			ChangedAt: change.ChangedAt,
+		}
+		if !change.Deleted() {
+			todo := newResponseTodoDTO(change.Todo)
+			responseDTO.Changes[i].Todo = &todo
+		}
+	}
+
+	bytes, err := json.Marshal(responseDTO)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,88 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type GetCommentsHttpHandler struct {
+	Service CommentService
+}
+
+func NewGetCommentsHttpHandler(s CommentService) *GetCommentsHttpHandler {
+	return &GetCommentsHttpHandler{Service: s}
+}
+
+type GetCommentsResponseDTO struct {
+	Comments []ResponseCommentDTO `json:"comments"`
+}
+
+type ResponseCommentDTO struct {
+	CommentID int        `json:"comment_id"`
+	TodoID    int        `json:"todo_id"`
+	UserID    int        `json:"user_id"`
+	UserName  string     `json:"username"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+}
+
+func newResponseCommentDTO(comment *Comment) ResponseCommentDTO {
+	return ResponseCommentDTO{
+		CommentID: comment.CommentID,
+		TodoID:    comment.TodoID,
+		UserID:    comment.UserID,
+		UserName:  comment.UserName,
+		Body:      comment.Body,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+		EditedAt:  comment.EditedAt,
+	}
+}
+
+func newGetCommentsResponseDTO(comments []*Comment) GetCommentsResponseDTO {
+	res := make([]ResponseCommentDTO, 0, len(comments))
+	for _, comment := range comments {
+		res = append(res, newResponseCommentDTO(comment))
+	}
+	return GetCommentsResponseDTO{Comments: res}
+}
+
+func (h GetCommentsHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	comments, err := h.Service.GetComments(r.Context(), &GetCommentsCommand{UserID: userID, TodoID: todoID})
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newGetCommentsResponseDTO(comments))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
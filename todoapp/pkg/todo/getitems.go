@@ -0,0 +1,81 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type GetItemsHttpHandler struct {
+	Service ChecklistService
+}
+
+func NewGetItemsHttpHandler(s ChecklistService) *GetItemsHttpHandler {
+	return &GetItemsHttpHandler{Service: s}
+}
+
+type GetItemsResponseDTO struct {
+	Items []ResponseItemDTO `json:"items"`
+}
+
+type ResponseItemDTO struct {
+	ItemID   int    `json:"item_id"`
+	TodoID   int    `json:"todo_id"`
+	Title    string `json:"title"`
+	Done     bool   `json:"done"`
+	Position int    `json:"position"`
+}
+
+func newResponseItemDTO(item *Item) ResponseItemDTO {
+	return ResponseItemDTO{
+		ItemID:   item.ItemID,
+		TodoID:   item.TodoID,
+		Title:    item.Title,
+		Done:     item.Done,
+		Position: item.Position,
+	}
+}
+
+func newGetItemsResponseDTO(items []*Item) GetItemsResponseDTO {
+	res := make([]ResponseItemDTO, 0, len(items))
+	for _, item := range items {
+		res = append(res, newResponseItemDTO(item))
+	}
+	return GetItemsResponseDTO{Items: res}
+}
+
+func (h GetItemsHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	items, err := h.Service.GetItems(r.Context(), &GetItemsCommand{UserID: userID, TodoID: todoID})
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newGetItemsResponseDTO(items))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,62 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type GetSharesHttpHandler struct {
+	Service ShareService
+}
+
+func NewGetSharesHttpHandler(s ShareService) *GetSharesHttpHandler {
+	return &GetSharesHttpHandler{Service: s}
+}
+
+func (h GetSharesHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	shares, err := h.Service.GetShares(r.Context(), &GetSharesCommand{UserID: userID, TodoID: todoID})
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]policy.ResponseShareDTO, 0, len(shares))
+	for _, share := range shares {
+		res = append(res, policy.NewResponseShareDTO(share))
+	}
+
+	bytes, err := json.Marshal(policy.GetSharesResponseDTO{Shares: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,47 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type GetTrashHttpHandler struct {
+	Service Service
+}
+
+func NewGetTrashHttpHandler(s Service) *GetTrashHttpHandler {
+	return &GetTrashHttpHandler{Service: s}
+}
+
+type GetTrashResponseDTO struct {
+	Todos []ResponseTodoDTO `json:"todos"`
+}
+
+func (h GetTrashHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todos, err := h.Service.GetTrash(r.Context(), &GetTrashCommand{UserID: userID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := GetTrashResponseDTO{Todos: make([]ResponseTodoDTO, 0, len(todos))}
+	for _, todo := range todos {
+		responseDTO.Todos = append(responseDTO.Todos, newResponseTodoDTO(todo))
+	}
+
+	bytes, err := json.Marshal(responseDTO)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,106 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type GetHistoryHttpHandler struct {
+	Service RevisionService
+}
+
+func NewGetHistoryHttpHandler(s RevisionService) *GetHistoryHttpHandler {
+	return &GetHistoryHttpHandler{Service: s}
+}
+
+type GetHistoryResponseDTO struct {
+	Revisions []ResponseRevisionDTO `json:"revisions"`
+}
+
+// ResponseFieldChangeDTO is a field a revision changed, with its value before and after.
+type ResponseFieldChangeDTO struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+type ResponseRevisionDTO struct {
+	RevisionID  int                     `json:"revision_id"`
+	UserID      *int                    `json:"user_id"`
+	Action      string                  `json:"action"`
+	Title       *ResponseFieldChangeDTO `json:"title,omitempty"`
+	Content     *ResponseFieldChangeDTO `json:"content,omitempty"`
+	ContentDiff []string                `json:"content_diff,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+}
+
+func newResponseRevisionDTO(revision *Revision) ResponseRevisionDTO {
+	dto := ResponseRevisionDTO{
+		RevisionID: revision.RevisionID,
+		UserID:     revision.UserID,
+		Action:     revision.Action,
+		CreatedAt:  revision.CreatedAt,
+	}
+	if !sameString(revision.OldTitle, revision.NewTitle) {
+		dto.Title = &ResponseFieldChangeDTO{Old: revision.OldTitle, New: revision.NewTitle}
+	}
+	if !sameString(revision.OldContent, revision.NewContent) {
+		dto.Content = &ResponseFieldChangeDTO{Old: revision.OldContent, New: revision.NewContent}
+		dto.ContentDiff = revision.ContentDiff()
+	}
+	return dto
+}
+
+func sameString(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (h GetHistoryHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := GetHistoryCommand{
+		UserID: userID,
+		TodoID: todoID,
+	}
+
+	revisions, err := h.Service.History(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := GetHistoryResponseDTO{Revisions: make([]ResponseRevisionDTO, 0, len(revisions))}
+	for _, revision := range revisions {
+		responseDTO.Revisions = append(responseDTO.Revisions, newResponseRevisionDTO(revision))
+	}
+
+	bytes, err := json.Marshal(responseDTO)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,56 @@
+package todo
+
+import (
+	"encoding/json"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/policy"
+)
+
+// AddHook registers a hook told about every change of a todo made through the service. Hooks
+// must be added before the service is used.
+func (s *ServiceImpl) AddHook(hook events.Hook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func newEvent(eventType string, todo *Todo) events.Event {
+	event := events.Event{
+		Type:    eventType,
+		UserID:  todo.UserID,
+		TodoID:  todo.TodoID,
+		ListID:  todo.ListID,
+		Version: todo.Version,
+	}
+	event.Todo, _ = json.Marshal(newResponseTodoDTO(todo))
+	return event
+}
+
+// changed tells the hooks that todo changed in tx.
+func (s ServiceImpl) changed(tx *pgx.Tx, eventType string, todo *Todo) error {
+	return s.notify(tx, newEvent(eventType, todo))
+}
+
+// updated tells the hooks that a todo changed from old to new in tx. A todo moved out of a list
+// is forgotten by the users who could only view it through the list.
+func (s ServiceImpl) updated(tx *pgx.Tx, old *Todo, new *Todo) error {
+	event := newEvent(events.TodoUpdated, new)
+	if old.ListID != nil && (new.ListID == nil || *new.ListID != *old.ListID) {
+		event.FromListID = old.ListID
+		query := `select $1::bigint as todo_id, user_id from list_shares where list_id = $2`
+		err := policy.ForgetTodos(tx, new.UpdatedAt, query, new.TodoID, *old.ListID)
+		if err != nil {
+			return err
+		}
+	}
+	return s.notify(tx, event)
+}
+
+func (s ServiceImpl) notify(tx *pgx.Tx, event events.Event) error {
+	for _, hook := range s.hooks {
+		err := hook.TodosChanged(tx, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
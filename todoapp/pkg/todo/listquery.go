@@ -0,0 +1,208 @@
+package todo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/pgtype"
+	"kuberneteslab/todoapp/pkg/policy"
+	"kuberneteslab/todoapp/pkg/tag"
+	"strings"
+	"time"
+)
+
+// sortKeys maps every supported sort to the expression it orders by. The expressions must match
+// the ones indexed in migrations/000003_add_todo_list_indexes.up.sql for keyset pagination to use
+// them, which it can on the todos of the user, queried apart from the todos shared with them.
+var sortKeys = map[string]struct {
+	expr string
+	cast string
+}{
+	SortCreated:  {expr: "created_at", cast: "timestamp"},
+	SortUpdated:  {expr: "updated_at", cast: "timestamp"},
+	SortDue:      {expr: "coalesce(due_at, 'infinity'::timestamp)", cast: "timestamp"},
+	SortPriority: {expr: "priority", cast: "smallint"},
+}
+
+// cursor is the position after the last todo of a page. It is handed to clients base64 encoded
+// so they treat it as opaque, and it remembers the ordering it was produced for.
+type cursor struct {
+	Sort   string     `json:"s"`
+	Desc   bool       `json:"d"`
+	Time   *time.Time `json:"t,omitempty"`
+	Int    int        `json:"i,omitempty"`
+	TodoID int        `json:"id"`
+}
+
+func encodeCursor(sort string, desc bool, last *Todo) (string, error) {
+	c := cursor{Sort: sort, Desc: desc, TodoID: last.TodoID}
+	switch sort {
+	case SortCreated:
+		c.Time = &last.CreatedAt
+	case SortUpdated:
+		c.Time = &last.UpdatedAt
+	case SortDue:
+		c.Time = last.DueAt
+	case SortPriority:
+		c.Int = last.Priority
+	}
+
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func decodeCursor(encoded string) (*cursor, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(bytes, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// value returns the sort key of the cursor as a query argument. A due cursor without time points
+// into the tail of todos without due date, which sort as infinity.
+func (c *cursor) value() interface{} {
+	switch c.Sort {
+	case SortPriority:
+		return c.Int
+	case SortDue:
+		if c.Time == nil {
+			return &pgtype.Timestamp{Status: pgtype.Present, InfinityModifier: pgtype.Infinity}
+		}
+	}
+	if c.Time == nil {
+		return nil
+	}
+	return c.Time.UTC()
+}
+
+type listQuery struct {
+	sql   string
+	args  []interface{}
+	sort  string
+	desc  bool
+	limit int
+}
+
+func newListQuery(cmd *GetAllTodosCommand) (*listQuery, error) {
+
+	q := listQuery{sort: cmd.Sort, desc: cmd.Desc, limit: cmd.Limit}
+	if q.sort == "" {
+		q.sort = SortCreated
+	}
+	key, ok := sortKeys[q.sort]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+	if q.limit == 0 {
+		q.limit = DefaultPageSize
+	}
+	if q.limit < 0 || q.limit > MaxPageSize {
+		return nil, ErrInvalidLimit
+	}
+
+	arg := func(v interface{}) string {
+		q.args = append(q.args, v)
+		return fmt.Sprintf("$%d", len(q.args))
+	}
+
+	userID := arg(cmd.UserID)
+	where := []string{"deleted_at is null"}
+
+	if cmd.AssignedToMe {
+		where = append(where, "assignee_id = "+arg(cmd.UserID))
+	}
+
+	if cmd.NoList {
+		where = append(where, "list_id is null")
+	} else if cmd.ListID != nil {
+		where = append(where, "list_id = "+arg(*cmd.ListID))
+	}
+
+	switch cmd.Status {
+	case "", StatusAll:
+	case StatusOpen:
+		where = append(where, "not completed")
+	case StatusCompleted:
+		where = append(where, "completed")
+	default:
+		return nil, ErrInvalidStatus
+	}
+
+	if cmd.DueAfter != nil {
+		where = append(where, "due_at >= "+arg(cmd.DueAfter.UTC()))
+	}
+	if cmd.DueBefore != nil {
+		where = append(where, "due_at < "+arg(cmd.DueBefore.UTC()))
+	}
+	if cmd.Text != "" {
+		pattern := arg("%" + escapeLike(cmd.Text) + "%")
+		where = append(where, fmt.Sprintf("(title ilike %s or content ilike %s)", pattern, pattern))
+	}
+
+	if len(cmd.Tags) > 0 {
+		tags, err := tag.NormalizeNames(cmd.Tags)
+		if err != nil {
+			return nil, err
+		}
+		tagged := `select %s from todo_tags tt join tags tg on tg.tag_id = tt.tag_id where tt.todo_id = todos.todo_id and tg.name = any(%s::text[])`
+		switch cmd.TagMatch {
+		case "", TagMatchAll:
+			where = append(where, fmt.Sprintf("("+tagged+") = %s", "count(*)", arg(tags), arg(len(tags))))
+		case TagMatchAny:
+			where = append(where, fmt.Sprintf("exists ("+tagged+")", "1", arg(tags)))
+		default:
+			return nil, ErrInvalidTagMatch
+		}
+	}
+
+	direction, comparison := "asc", ">"
+	if q.desc {
+		direction, comparison = "desc", "<"
+	}
+
+	if cmd.Cursor != "" {
+		c, err := decodeCursor(cmd.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != q.sort || c.Desc != q.desc {
+			return nil, ErrInvalidCursor
+		}
+		value := c.value()
+		if value == nil {
+			return nil, ErrInvalidCursor
+		}
+		where = append(where, fmt.Sprintf("(%s, todo_id) %s (%s::%s, %s::bigint)", key.expr, comparison, arg(value), key.cast, arg(c.TodoID)))
+	}
+
+	// Each source of visible todos reads its own page, and the page is taken from their union,
+	// rather than from a single query whose or would keep the indexes from being used.
+	order := fmt.Sprintf("order by %s %s, todo_id %s limit %s", key.expr, direction, direction, arg(q.limit+1))
+	sources := policy.VisibleTodoSources(userID)
+	pages := make([]string, len(sources))
+	for i, source := range sources {
+		pages[i] = fmt.Sprintf(`(select todo_id from todos where %s and %s %s)`, source, strings.Join(where, " and "), order)
+	}
+	q.sql = fmt.Sprintf(`select %s from todos where todo_id in (%s) %s`, todoColumns, strings.Join(pages, " union all "), order)
+
+	return &q, nil
+}
+
+// isListQueryError reports whether err comes from an invalid query or cursor of a list of todos.
+func isListQueryError(err error) bool {
+	return errors.Is(err, ErrInvalidSort) || errors.Is(err, ErrInvalidLimit) || errors.Is(err, ErrInvalidStatus) ||
+		errors.Is(err, ErrInvalidTagMatch) || errors.Is(err, ErrInvalidCursor) || errors.Is(err, tag.ErrInvalidName)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,317 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/user"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// LiveSendBuffer is how many frames a client can fall behind before it is disconnected.
+	LiveSendBuffer = 64
+
+	liveAuthTimeout  = 10 * time.Second
+	liveWriteTimeout = 10 * time.Second
+	livePongTimeout  = 60 * time.Second
+	livePingInterval = 50 * time.Second
+	liveMaxFrameSize = 64 * 1024
+)
+
+var (
+	errLiveUnauthenticated = errors.New("the first frame must be an auth frame with a valid token")
+	errLiveUnknownType     = errors.New("type must be subscribe, unsubscribe, create, update, delete, complete or reopen")
+)
+
+// TokenVerifier verifies the tokens middlewares.AuthMiddleware accepts.
+type TokenVerifier interface {
+	VerifyToken(token string) (*user.Payload, error)
+}
+
+type LiveTodosHttpHandler struct {
+	Service Service
+	Batch   BatchService
+	Events  EventSource
+	Tokens  TokenVerifier
+}
+
+func NewLiveTodosHttpHandler(s Service, b BatchService, e EventSource, t TokenVerifier) *LiveTodosHttpHandler {
+	return &LiveTodosHttpHandler{Service: s, Batch: b, Events: e, Tokens: t}
+}
+
+// LiveRequestDTO is a frame sent by the client. Type is auth, subscribe, unsubscribe, or one of
+// the operations of a batch, whose fields it takes. Subscribing without a list id subscribes to
+// every todo the user can view. The id is sent back with the ack or error of the frame.
+type LiveRequestDTO struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Token   string          `json:"token,omitempty"`
+	ListID  *int            `json:"list_id,omitempty"`
+	TodoID  int             `json:"todo_id,omitempty"`
+	Version *int            `json:"version,omitempty"`
+	Todo    json.RawMessage `json:"todo,omitempty"`
+}
+
+// LiveResponseDTO is a frame sent by the server: the ack or error of a request, or an event of
+// the subscribed todos. Data is the todo of an ack and the data of an event, as in the event stream.
+type LiveResponseDTO struct {
+	Type   string          `json:"type"`
+	ID     string          `json:"id,omitempty"`
+	Event  string          `json:"event,omitempty"`
+	Status int             `json:"status,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+var liveUpgrader = websocket.Upgrader{
+	// Clients authenticate with a token rather than cookies, so any origin can connect.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// liveSubscriptions are the lists a connection is subscribed to.
+type liveSubscriptions struct {
+	mu     sync.Mutex
+	userID int
+	all    bool
+	lists  map[int]bool
+}
+
+func (l *liveSubscriptions) match(event events.Event) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !event.VisibleTo(l.userID) {
+		return false
+	}
+	return l.all || (event.ListID != nil && l.lists[*event.ListID]) || (event.FromListID != nil && l.lists[*event.FromListID])
+}
+
+func (l *liveSubscriptions) set(listID *int, subscribed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if listID == nil {
+		l.all = subscribed
+		return
+	}
+	if subscribed {
+		l.lists[*listID] = true
+	} else {
+		delete(l.lists, *listID)
+	}
+}
+
+// liveConn is one WebSocket connection. Only its writer goroutine writes frames; the others
+// queue them on send, and the connection is closed when the queue is full.
+type liveConn struct {
+	ws   *websocket.Conn
+	send chan LiveResponseDTO
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *liveConn) close(code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		deadline := time.Now().Add(liveWriteTimeout)
+		c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+		c.ws.Close()
+	})
+}
+
+func (c *liveConn) queue(frame LiveResponseDTO) {
+	select {
+	case c.send <- frame:
+	case <-c.done:
+	default:
+		c.close(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+func (c *liveConn) writeLoop() {
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case frame := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			err := c.ws.WriteJSON(frame)
+			if err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout))
+			if err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket. Clients that can't send an Authorization
+// header authenticate with an auth frame instead, which must then be the first frame.
+func (h LiveTodosHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID := 0
+	if r.Header.Get("Authorization") != "" {
+		token, err := middlewares.BearerToken(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		payload, err := h.Tokens.VerifyToken(token)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("auth token couldn't be verified"))
+			return
+		}
+		userID = payload.UserID
+	}
+
+	ws, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	ws.SetReadLimit(liveMaxFrameSize)
+
+	c := &liveConn{
+		ws:   ws,
+		send: make(chan LiveResponseDTO, LiveSendBuffer),
+		done: make(chan struct{}),
+	}
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	if userID == 0 {
+		userID, err = h.authenticate(c)
+		if err != nil {
+			c.close(websocket.ClosePolicyViolation, err.Error())
+			return
+		}
+	}
+
+	ws.SetReadDeadline(time.Now().Add(livePongTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(livePongTimeout))
+	})
+
+	subscriptions := &liveSubscriptions{userID: userID, lists: map[int]bool{}}
+	subscription := h.Events.Subscribe(subscriptions.match)
+	defer h.Events.Unsubscribe(subscription)
+
+	go c.writeLoop()
+	go h.pushEvents(c, userID, subscription)
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		// Any frame shows the client is alive.
+		ws.SetReadDeadline(time.Now().Add(livePongTimeout))
+
+		var req LiveRequestDTO
+		err = json.Unmarshal(message, &req)
+		if err != nil {
+			c.queue(LiveResponseDTO{Type: "error", Status: http.StatusBadRequest, Error: "frames must be JSON objects"})
+			continue
+		}
+
+		c.queue(h.handle(r.Context(), userID, subscriptions, req))
+	}
+}
+
+// authenticate reads the auth frame of a connection, and returns the id of its user.
+func (h LiveTodosHttpHandler) authenticate(c *liveConn) (int, error) {
+	c.ws.SetReadDeadline(time.Now().Add(liveAuthTimeout))
+
+	var req LiveRequestDTO
+	err := c.ws.ReadJSON(&req)
+	if err != nil || req.Type != "auth" {
+		return 0, errLiveUnauthenticated
+	}
+	payload, err := h.Tokens.VerifyToken(req.Token)
+	if err != nil {
+		return 0, errLiveUnauthenticated
+	}
+
+	c.ws.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+	err = c.ws.WriteJSON(LiveResponseDTO{Type: "ack", ID: req.ID, Status: http.StatusOK})
+	if err != nil {
+		return 0, err
+	}
+	return payload.UserID, nil
+}
+
+// handle runs the request of a frame, and returns its ack or error. Operations run one at a
+// time, in the order they were sent.
+func (h LiveTodosHttpHandler) handle(ctx context.Context, userID int, subscriptions *liveSubscriptions, req LiveRequestDTO) LiveResponseDTO {
+
+	switch req.Type {
+	case "subscribe", "unsubscribe":
+		subscriptions.set(req.ListID, req.Type == "subscribe")
+		return LiveResponseDTO{Type: "ack", ID: req.ID, Status: http.StatusOK}
+	case "create", "update", "delete", "complete", "reopen":
+	default:
+		return LiveResponseDTO{Type: "error", ID: req.ID, Status: http.StatusBadRequest, Error: errLiveUnknownType.Error()}
+	}
+
+	op, err := newBatchOperation(BatchOperationDTO{Op: req.Type, TodoID: req.TodoID, Version: req.Version, Todo: req.Todo})
+	if err != nil {
+		return LiveResponseDTO{Type: "error", ID: req.ID, Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	result, err := h.Batch.Batch(ctx, &BatchCommand{UserID: userID, Mode: BatchAtomic, Operations: []BatchOperation{op}})
+	if err == nil {
+		err = result.Operations[0].Err
+	}
+	if err != nil {
+		status := operationStatus(err)
+		message := err.Error()
+		if status == http.StatusInternalServerError {
+			message = http.StatusText(status)
+		}
+		return LiveResponseDTO{Type: "error", ID: req.ID, Status: status, Error: message}
+	}
+
+	data, err := json.Marshal(newResponseTodoDTO(result.Operations[0].Todo))
+	if err != nil {
+		return LiveResponseDTO{Type: "error", ID: req.ID, Status: http.StatusInternalServerError, Error: http.StatusText(http.StatusInternalServerError)}
+	}
+	return LiveResponseDTO{Type: "ack", ID: req.ID, Status: http.StatusOK, Data: data}
+}
+
+// pushEvents queues the events of a subscription until the connection closes. When the broker
+// drops the subscription, events may have been missed, so the connection is closed too.
+func (h LiveTodosHttpHandler) pushEvents(c *liveConn, userID int, subscription *events.Subscription) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, ok := <-subscription.C:
+			if !ok {
+				c.close(websocket.CloseTryAgainLater, "events were missed")
+				return
+			}
+			data, err := eventData(context.Background(), h.Service, userID, event)
+			if errors.Is(err, ErrTodoNotFound) {
+				continue
+			}
+			if err != nil {
+				c.close(websocket.CloseInternalServerErr, fmt.Sprintf("error reading todo %v", event.TodoID))
+				return
+			}
+			c.queue(LiveResponseDTO{Type: "event", Event: streamEventType(event.Type), Data: data})
+		}
+	}
+}
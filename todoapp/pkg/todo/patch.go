@@ -0,0 +1,400 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	contentTypeJSON       = "application/json"
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	errInvalidPatch     = errors.New("invalid patch")
+	errUnsupportedPatch = errors.New("Content-Type must be application/json, application/merge-patch+json or application/json-patch+json")
+)
+
+// patchDocumentDTO is the document a JSON Patch of a todo applies to: the fields a patch can change.
+type patchDocumentDTO struct {
+	ListID       *int       `json:"list_id"`
+	AssigneeID   *int       `json:"assignee_id"`
+	Title        string     `json:"title"`
+	Content      string     `json:"content"`
+	DueAt        *time.Time `json:"due_at"`
+	Priority     int        `json:"priority"`
+	Tags         []string   `json:"tags"`
+	Recurrence   *string    `json:"recurrence"`
+	RecurrenceTZ *string    `json:"recurrence_tz"`
+}
+
+type patchOperationDTO struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func invalidPatch(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errInvalidPatch, fmt.Sprintf(format, args...))
+}
+
+// applyFields sets on cmd the fields of a merge patch (RFC 7396). A null removes the value of a
+// field, which is what a new todo would have.
+func applyFields(cmd *UpdateTodoCommand, fields map[string]json.RawMessage) error {
+	for name, raw := range fields {
+		null := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+
+		var err error
+		switch name {
+		case "list_id":
+			cmd.ListID.Set = true
+			err = json.Unmarshal(raw, &cmd.ListID.Value)
+		case "assignee_id":
+			cmd.AssigneeID.Set = true
+			err = json.Unmarshal(raw, &cmd.AssigneeID.Value)
+		case "title":
+			if null {
+				return invalidPatch("/title can't be removed")
+			}
+			err = json.Unmarshal(raw, &cmd.Title)
+		case "content":
+			content := ""
+			cmd.Content = &content
+			if !null {
+				err = json.Unmarshal(raw, cmd.Content)
+			}
+		case "due_at":
+			cmd.DueAt.Set = true
+			err = json.Unmarshal(raw, &cmd.DueAt.Value)
+		case "priority":
+			priority := PriorityNone
+			cmd.Priority = &priority
+			if !null {
+				err = json.Unmarshal(raw, cmd.Priority)
+			}
+		case "tags":
+			cmd.Tags = []string{}
+			if !null {
+				err = json.Unmarshal(raw, &cmd.Tags)
+			}
+		case "recurrence":
+			cmd.Recurrence.Set = true
+			err = json.Unmarshal(raw, &cmd.Recurrence.Value)
+			if err == nil && cmd.Recurrence.Value != nil && *cmd.Recurrence.Value == "" {
+				cmd.Recurrence.Value = nil
+			}
+		case "recurrence_tz":
+			tz := ""
+			cmd.RecurrenceTZ = &tz
+			if !null {
+				err = json.Unmarshal(raw, cmd.RecurrenceTZ)
+			}
+		default:
+			return invalidPatch("/%s is not a field of a todo that can be changed", name)
+		}
+		if err != nil {
+			return invalidPatch("/%s has the wrong type", name)
+		}
+	}
+	return nil
+}
+
+// jsonPatchFields applies a JSON Patch (RFC 6902) to the patchable fields of a todo, and returns
+// the fields it changed as a merge patch.
+func jsonPatchFields(todo *Todo, body []byte) (map[string]json.RawMessage, error) {
+
+	var ops []patchOperationDTO
+	err := json.Unmarshal(body, &ops)
+	if err != nil {
+		return nil, invalidPatch("a JSON Patch must be an array of operations")
+	}
+
+	before, err := todoDocument(todo)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := toDocument(before)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		patched, err = applyOperation(patched, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	after, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, invalidPatch("the todo must remain an object")
+	}
+
+	fields := map[string]json.RawMessage{}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			fields[name] = json.RawMessage("null")
+		}
+	}
+	for name, value := range after {
+		if reflect.DeepEqual(before[name], value) {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = raw
+	}
+	return fields, nil
+}
+
+// todoDocument returns the patchable fields of a todo in the generic form encoding/json decodes to.
+func todoDocument(todo *Todo) (map[string]interface{}, error) {
+	document, err := toDocument(patchDocumentDTO{
+		ListID:       todo.ListID,
+		AssigneeID:   todo.AssigneeID,
+		Title:        todo.Title,
+		Content:      todo.Content,
+		DueAt:        todo.DueAt,
+		Priority:     todo.Priority,
+		Tags:         append([]string{}, todo.Tags...),
+		Recurrence:   todo.RecurrenceRule,
+		RecurrenceTZ: todo.RecurrenceTZ,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return document.(map[string]interface{}), nil
+}
+
+// toDocument copies v into the generic form encoding/json decodes to, which JSON Patch works on.
+func toDocument(v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	err = json.Unmarshal(raw, &doc)
+	return doc, err
+}
+
+func applyOperation(doc interface{}, op patchOperationDTO) (interface{}, error) {
+
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+		if len(op.Value) == 0 {
+			return nil, invalidPatch("%s needs a value", op.Op)
+		}
+		err = json.Unmarshal(op.Value, &value)
+		if err != nil {
+			return nil, invalidPatch("value of %s is not valid JSON", op.Path)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, value)
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+	case "replace":
+		doc, _, err = removeValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "test":
+		current, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, invalidPatch("test of %s failed", op.Path)
+		}
+		return doc, nil
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if op.Path == op.From {
+				return doc, nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, invalidPatch("can't move %s into itself", op.From)
+			}
+			doc, value, err = removeValue(doc, from)
+		} else {
+			value, err = getValue(doc, from)
+			if err == nil {
+				value, err = toDocument(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	default:
+		return nil, invalidPatch("unknown op %q", op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens. The whole
+// document can't be patched, so the empty pointer is rejected.
+func parsePointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, invalidPatch("invalid path %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func pointerString(tokens []string) string {
+	escaped := make([]string, len(tokens))
+	for i, token := range tokens {
+		escaped[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// arrayIndex parses the index of an array of length n. The index n itself is only valid when
+// adding, where "-" also means it.
+func arrayIndex(token string, n int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return n, nil
+	}
+	max := n - 1
+	if adding {
+		max = n
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || token[0] < '0' || token[0] > '9' || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, invalidPatch("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+func getValue(node interface{}, tokens []string) (interface{}, error) {
+	for i, token := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, invalidPatch("path %s does not exist", pointerString(tokens[:i+1]))
+			}
+			node = child
+		case []interface{}:
+			idx, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[idx]
+		default:
+			return nil, invalidPatch("path %s does not exist", pointerString(tokens[:i+1]))
+		}
+	}
+	return node, nil
+}
+
+// addValue adds value at tokens and returns the updated node, since inserting into an array
+// may reallocate it.
+func addValue(node interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			n[token] = value
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, invalidPatch("path /%s does not exist", token)
+		}
+		updated, err := addValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = updated
+		return n, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(n), len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+			return n, nil
+		}
+		n[idx], err = addValue(n[idx], rest, value)
+		return n, err
+	default:
+		return nil, invalidPatch("path /%s does not exist", token)
+	}
+}
+
+// removeValue removes the value at tokens, and returns the updated node and the removed value.
+func removeValue(node interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, invalidPatch("the whole todo can't be removed")
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, invalidPatch("path /%s does not exist", token)
+		}
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, child, nil
+		}
+		updated, removed, err := removeValue(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = updated
+		return n, removed, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(n), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[idx]
+			return append(n[:idx], n[idx+1:]...), removed, nil
+		}
+		updated, removed, err := removeValue(n[idx], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[idx] = updated
+		return n, removed, nil
+	default:
+		return nil, nil, invalidPatch("path /%s does not exist", token)
+	}
+}
@@ -0,0 +1,217 @@
+package todo
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		tokens  []string
+		valid   bool
+	}{
+		{"/title", []string{"title"}, true},
+		{"/tags/0", []string{"tags", "0"}, true},
+		{"/a~1b", []string{"a/b"}, true},
+		{"/a~0b", []string{"a~b"}, true},
+		{"/~01", []string{"~1"}, true},
+		{"/~10", []string{"/0"}, true},
+		{"/", []string{""}, true},
+		{"", nil, false},
+		{"title", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.pointer, func(t *testing.T) {
+			tokens, err := parsePointer(test.pointer)
+			if !test.valid {
+				require.ErrorIs(t, err, errInvalidPatch)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.tokens, tokens)
+			require.Equal(t, test.pointer, pointerString(tokens))
+		})
+	}
+}
+
+func TestArrayIndex(t *testing.T) {
+	tests := []struct {
+		token  string
+		adding bool
+		index  int
+		valid  bool
+	}{
+		{"0", false, 0, true},
+		{"2", false, 2, true},
+		{"3", false, 0, false},
+		{"3", true, 3, true},
+		{"4", true, 0, false},
+		{"-", true, 3, true},
+		{"-", false, 0, false},
+		{"01", false, 0, false},
+		{"00", true, 0, false},
+		{"-1", false, 0, false},
+		{"+1", false, 0, false},
+		{"a", true, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.token, func(t *testing.T) {
+			index, err := arrayIndex(test.token, 3, test.adding)
+			if !test.valid {
+				require.ErrorIs(t, err, errInvalidPatch)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.index, index)
+		})
+	}
+}
+
+func TestApplyOperation(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       patchOperationDTO
+		expected string
+		valid    bool
+	}{
+		{"add appends with the - index", patchOperationDTO{Op: "add", Path: "/tags/-", Value: json.RawMessage(`"c"`)}, `{"title":"t","tags":["a","b","c"]}`, true},
+		{"add inserts at an index", patchOperationDTO{Op: "add", Path: "/tags/0", Value: json.RawMessage(`"c"`)}, `{"title":"t","tags":["c","a","b"]}`, true},
+		{"add at an index with a leading zero fails", patchOperationDTO{Op: "add", Path: "/tags/01", Value: json.RawMessage(`"c"`)}, ``, false},
+		{"add under a missing field fails", patchOperationDTO{Op: "add", Path: "/missing/0", Value: json.RawMessage(`"c"`)}, ``, false},
+		{"add without a value fails", patchOperationDTO{Op: "add", Path: "/content"}, ``, false},
+		{"remove deletes an element", patchOperationDTO{Op: "remove", Path: "/tags/0"}, `{"title":"t","tags":["b"]}`, true},
+		{"remove with the - index fails", patchOperationDTO{Op: "remove", Path: "/tags/-"}, ``, false},
+		{"remove a missing field fails", patchOperationDTO{Op: "remove", Path: "/content"}, ``, false},
+		{"replace changes a field", patchOperationDTO{Op: "replace", Path: "/title", Value: json.RawMessage(`"u"`)}, `{"title":"u","tags":["a","b"]}`, true},
+		{"replace a missing field fails", patchOperationDTO{Op: "replace", Path: "/content", Value: json.RawMessage(`"u"`)}, ``, false},
+		{"test of an equal value passes", patchOperationDTO{Op: "test", Path: "/tags", Value: json.RawMessage(`["a","b"]`)}, `{"title":"t","tags":["a","b"]}`, true},
+		{"test of another value fails", patchOperationDTO{Op: "test", Path: "/title", Value: json.RawMessage(`"u"`)}, ``, false},
+		{"move renames a field", patchOperationDTO{Op: "move", From: "/title", Path: "/content"}, `{"content":"t","tags":["a","b"]}`, true},
+		{"move within an array", patchOperationDTO{Op: "move", From: "/tags/0", Path: "/tags/-"}, `{"title":"t","tags":["b","a"]}`, true},
+		{"move to itself changes nothing", patchOperationDTO{Op: "move", From: "/tags", Path: "/tags"}, `{"title":"t","tags":["a","b"]}`, true},
+		{"move into its own child fails", patchOperationDTO{Op: "move", From: "/tags", Path: "/tags/0"}, ``, false},
+		{"copy duplicates a value", patchOperationDTO{Op: "copy", From: "/tags/1", Path: "/tags/0"}, `{"title":"t","tags":["b","a","b"]}`, true},
+		{"copy from a missing field fails", patchOperationDTO{Op: "copy", From: "/content", Path: "/title"}, ``, false},
+		{"unknown op fails", patchOperationDTO{Op: "merge", Path: "/title"}, ``, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var doc interface{}
+			require.NoError(t, json.Unmarshal([]byte(`{"title":"t","tags":["a","b"]}`), &doc))
+
+			patched, err := applyOperation(doc, test.op)
+			if !test.valid {
+				require.ErrorIs(t, err, errInvalidPatch)
+				return
+			}
+			require.NoError(t, err)
+			raw, err := json.Marshal(patched)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, string(raw))
+		})
+	}
+
+	t.Run("copy should not alias the copied value", func(t *testing.T) {
+		var doc interface{}
+		require.NoError(t, json.Unmarshal([]byte(`{"a":["x"],"b":null}`), &doc))
+
+		doc, err := applyOperation(doc, patchOperationDTO{Op: "copy", From: "/a", Path: "/b"})
+		require.NoError(t, err)
+		doc, err = applyOperation(doc, patchOperationDTO{Op: "add", Path: "/b/0", Value: json.RawMessage(`"y"`)})
+		require.NoError(t, err)
+
+		raw, err := json.Marshal(doc)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a":["x"],"b":["y","x"]}`, string(raw))
+	})
+}
+
+func TestJSONPatchFields(t *testing.T) {
+	todo := &Todo{Title: "title", Content: "content", Priority: PriorityHigh, Tags: []string{"a"}}
+
+	tests := []struct {
+		name   string
+		patch  string
+		fields map[string]string
+		valid  bool
+	}{
+		{"no operations change nothing", `[]`, map[string]string{}, true},
+		{"replace returns the changed field", `[{"op":"replace","path":"/title","value":"new"}]`, map[string]string{"title": `"new"`}, true},
+		{"add to tags returns the whole tags", `[{"op":"add","path":"/tags/-","value":"b"}]`, map[string]string{"tags": `["a","b"]`}, true},
+		{"remove returns null", `[{"op":"remove","path":"/content"}]`, map[string]string{"content": `null`}, true},
+		{"move returns both fields", `[{"op":"move","from":"/title","path":"/content"}]`, map[string]string{"title": `null`, "content": `"title"`}, true},
+		{"replace with the same value changes nothing", `[{"op":"replace","path":"/priority","value":3}]`, map[string]string{}, true},
+		{"a failing test applies nothing", `[{"op":"replace","path":"/title","value":"new"},{"op":"test","path":"/content","value":"other"}]`, nil, false},
+		{"a patch that isn't an array fails", `{"op":"remove","path":"/title"}`, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := jsonPatchFields(todo, []byte(test.patch))
+			if !test.valid {
+				require.ErrorIs(t, err, errInvalidPatch)
+				return
+			}
+			require.NoError(t, err)
+			raw := map[string]string{}
+			for name, value := range fields {
+				raw[name] = string(value)
+			}
+			require.Equal(t, test.fields, raw)
+		})
+	}
+
+	t.Run("remove a required field should fail once applied", func(t *testing.T) {
+		fields, err := jsonPatchFields(todo, []byte(`[{"op":"remove","path":"/title"}]`))
+		require.NoError(t, err)
+		require.ErrorIs(t, applyFields(&UpdateTodoCommand{}, fields), errInvalidPatch)
+	})
+}
+
+func TestApplyFields(t *testing.T) {
+	empty, none := "", PriorityNone
+
+	tests := []struct {
+		field    string
+		expected UpdateTodoCommand
+		valid    bool
+	}{
+		{"list_id", UpdateTodoCommand{ListID: Nullable[int]{Set: true}}, true},
+		{"assignee_id", UpdateTodoCommand{AssigneeID: Nullable[int]{Set: true}}, true},
+		{"title", UpdateTodoCommand{}, false},
+		{"content", UpdateTodoCommand{Content: &empty}, true},
+		{"due_at", UpdateTodoCommand{DueAt: Nullable[time.Time]{Set: true}}, true},
+		{"priority", UpdateTodoCommand{Priority: &none}, true},
+		{"tags", UpdateTodoCommand{Tags: []string{}}, true},
+		{"recurrence", UpdateTodoCommand{Recurrence: Nullable[string]{Set: true}}, true},
+		{"recurrence_tz", UpdateTodoCommand{RecurrenceTZ: &empty}, true},
+		{"owner", UpdateTodoCommand{}, false},
+	}
+	for _, test := range tests {
+		t.Run("null "+test.field, func(t *testing.T) {
+			var cmd UpdateTodoCommand
+			err := applyFields(&cmd, map[string]json.RawMessage{test.field: json.RawMessage("null")})
+			if !test.valid {
+				require.ErrorIs(t, err, errInvalidPatch)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, cmd)
+		})
+	}
+
+	t.Run("a field of the wrong type should fail", func(t *testing.T) {
+		var cmd UpdateTodoCommand
+		err := applyFields(&cmd, map[string]json.RawMessage{"priority": json.RawMessage(`"high"`)})
+		require.ErrorIs(t, err, errInvalidPatch)
+	})
+
+	t.Run("an empty recurrence should clear it", func(t *testing.T) {
+		var cmd UpdateTodoCommand
+		err := applyFields(&cmd, map[string]json.RawMessage{"recurrence": json.RawMessage(`""`)})
+		require.NoError(t, err)
+		require.Equal(t, Nullable[string]{Set: true}, cmd.Recurrence)
+	})
+}
@@ -0,0 +1,56 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type PurgeTodoHttpHandler struct {
+	Service Service
+}
+
+func NewPurgeTodoHttpHandler(s Service) *PurgeTodoHttpHandler {
+	return &PurgeTodoHttpHandler{Service: s}
+}
+
+func (h PurgeTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := PurgeTodoCommand{
+		UserID: userID,
+		TodoID: todoID,
+	}
+
+	todo, err := h.Service.Purge(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseTodoDTO(todo))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
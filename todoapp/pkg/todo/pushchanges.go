@@ -0,0 +1,136 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type PushChangesHttpHandler struct {
+	Service SyncService
+}
+
+func NewPushChangesHttpHandler(s SyncService) *PushChangesHttpHandler {
+	return &PushChangesHttpHandler{Service: s}
+}
+
+// PushChangesRequestDTO is the changes a client made offline. Strategy is last_writer_wins,
+// the default, or field_merge.
+type PushChangesRequestDTO struct {
+	Strategy string          `json:"strategy"`
+	Changes  []PushChangeDTO `json:"changes"`
+}
+
+// PushChangeDTO changes the todo as it was at base_version, or creates one when todo_id is
+// left out. Fields is a merge patch of the todo, and base the values those fields had at
+// base_version, which field_merge needs to keep the changes of both sides.
+type PushChangeDTO struct {
+	ClientID    string                     `json:"client_id,omitempty"`
+	TodoID      int                        `json:"todo_id,omitempty"`
+	BaseVersion int                        `json:"base_version,omitempty"`
+	ChangedAt   time.Time                  `json:"changed_at"`
+	Deleted     bool                       `json:"deleted,omitempty"`
+	Fields      map[string]json.RawMessage `json:"fields,omitempty"`
+	Base        map[string]json.RawMessage `json:"base,omitempty"`
+}
+
+type PushChangesResponseDTO struct {
+	Results []PushChangeResultDTO `json:"results"`
+}
+
+// PushChangeResultDTO is the outcome of a change, with the todo as it is now on the server,
+// which the client should keep.
+type PushChangeResultDTO struct {
+	ClientID string           `json:"client_id,omitempty"`
+	TodoID   int              `json:"todo_id,omitempty"`
+	Status   int              `json:"status"`
+	Error    string           `json:"error,omitempty"`
+	Deleted  bool             `json:"deleted"`
+	Todo     *ResponseTodoDTO `json:"todo,omitempty"`
+	Conflict *SyncConflictDTO `json:"conflict,omitempty"`
+}
+
+// SyncConflictDTO tells which side was kept, client or server, for the fields changed on both.
+type SyncConflictDTO struct {
+	Fields     []string `json:"fields"`
+	Resolution string   `json:"resolution"`
+}
+
+func (h PushChangesHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := PushChangesRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := SyncCommand{
+		UserID:   userID,
+		Strategy: dto.Strategy,
+		Changes:  make([]SyncChange, len(dto.Changes)),
+	}
+	if cmd.Strategy == "" {
+		cmd.Strategy = SyncLastWriterWins
+	}
+	for i, change := range dto.Changes {
+		cmd.Changes[i] = SyncChange{
+			ClientID:    change.ClientID,
+			TodoID:      change.TodoID,
+			BaseVersion: change.BaseVersion,
+			ChangedAt:   change.ChangedAt,
+			Deleted:     change.Deleted,
+			Fields:      change.Fields,
+			Base:        change.Base,
+		}
+	}
+
+	results, err := h.Service.Sync(r.Context(), &cmd)
+	if errors.Is(err, ErrInvalidStrategy) || errors.Is(err, ErrSyncSize) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := PushChangesResponseDTO{Results: make([]PushChangeResultDTO, len(results))}
+	for i, result := range results {
+		res := PushChangeResultDTO{
+			ClientID: result.ClientID,
+			TodoID:   result.TodoID,
+			Status:   operationStatus(result.Err),
+			Deleted:  result.Err == nil && (result.Todo == nil || result.Todo.DeletedAt != nil),
+		}
+		if result.Err != nil && res.Status != http.StatusInternalServerError {
+			res.Error = result.Err.Error()
+		}
+		if result.Todo != nil && result.Todo.DeletedAt == nil {
+			todo := newResponseTodoDTO(result.Todo)
+			res.Todo = &todo
+		}
+		if result.Conflict != nil {
+			res.Conflict = &SyncConflictDTO{Fields: result.Conflict.Fields, Resolution: result.Conflict.Resolution}
+		}
+		responseDTO.Results[i] = res
+	}
+
+	bytes, err := json.Marshal(responseDTO)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,108 @@
+package todo
+
+import (
+	"errors"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/rrule"
+	"time"
+)
+
+var (
+	ErrRecurrenceWithoutDue = errors.New("a recurring todo needs a due date")
+	ErrInvalidTimezone      = errors.New("recurrence_tz must be an IANA time zone such as Europe/Madrid")
+)
+
+// recurrence is a validated recurrence rule, ready to be stored with a todo.
+type recurrence struct {
+	rule *string
+	tz   *string
+}
+
+// newRecurrence validates the recurrence of a todo due at dueAt. An empty rule means the
+// todo doesn't repeat. The rule is stored in its canonical form, and tz defaults to UTC.
+func newRecurrence(rule string, tz string, dueAt *time.Time) (*recurrence, error) {
+	if rule == "" {
+		return &recurrence{}, nil
+	}
+	if dueAt == nil {
+		return nil, ErrRecurrenceWithoutDue
+	}
+
+	parsed, err := rrule.Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+	if tz == "" {
+		tz = "UTC"
+	}
+	_, err = time.LoadLocation(tz)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+
+	canonical := parsed.String()
+	return &recurrence{rule: &canonical, tz: &tz}, nil
+}
+
+// isRecurrenceError reports whether err comes from an invalid recurrence.
+func isRecurrenceError(err error) bool {
+	return errors.Is(err, ErrRecurrenceWithoutDue) || errors.Is(err, ErrInvalidTimezone) ||
+		errors.Is(err, rrule.ErrInvalidRule) || errors.Is(err, rrule.ErrUnsupportedPart)
+}
+
+// spawnNext creates the occurrence following todo in its series, with the same list, fields,
+// tags and checklist, the checklist being undone. It returns 0 when the series has ended.
+func (s ServiceImpl) spawnNext(tx *pgx.Tx, todo *Todo) (int, error) {
+
+	rule, err := rrule.Parse(*todo.RecurrenceRule)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := time.LoadLocation(*todo.RecurrenceTZ)
+	if err != nil {
+		return 0, err
+	}
+
+	next, ok := rule.Nth(todo.recurrenceStart.In(loc), todo.RecurrenceIndex+1)
+	if !ok {
+		return 0, nil
+	}
+
+	var nextID int
+	query := `insert into todos(user_id, list_id, title, content, due_at, priority, recurrence_rule, recurrence_tz, recurrence_start, recurrence_index, assignee_id)
+		select user_id, list_id, title, content, $2, priority, recurrence_rule, recurrence_tz, recurrence_start, recurrence_index + 1, assignee_id
+		from todos where todo_id = $1
+		returning todo_id`
+	err = tx.QueryRow(query, todo.TodoID, utc(&next)).Scan(&nextID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`insert into todo_tags(todo_id, tag_id) select $2, tag_id from todo_tags where todo_id = $1`, todo.TodoID, nextID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`insert into checklist_items(todo_id, title, position) select $2, title, position from checklist_items where todo_id = $1`, todo.TodoID, nextID)
+	if err != nil {
+		return 0, err
+	}
+
+	// The next occurrence is shared like this one, so that its assignee keeps access to it.
+	_, err = tx.Exec(`insert into todo_shares(todo_id, user_id, role) select $2, user_id, role from todo_shares where todo_id = $1`, todo.TodoID, nextID)
+	if err != nil {
+		return 0, err
+	}
+
+	spawned, err := fetch(tx, nextID)
+	if err != nil {
+		return 0, err
+	}
+	err = recordRevision(tx, todo.UserID, ActionCreate, nil, spawned)
+	if err != nil {
+		return 0, err
+	}
+
+	return nextID, s.changed(tx, events.TodoCreated, spawned)
+}
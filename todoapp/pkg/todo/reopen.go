@@ -0,0 +1,76 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type ReopenTodoHttpHandler struct {
+	Service Service
+}
+
+func NewReopenTodoHttpHandler(s Service) *ReopenTodoHttpHandler {
+	return &ReopenTodoHttpHandler{Service: s}
+}
+
+type ReopenTodoRequestDTO struct {
+	UserID int `json:"user_id"`
+	TodoID int `json:"todo_id"`
+}
+
+func (h ReopenTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := ReopenTodoRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, dto.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("can't reopen todos for another user"))
+		return
+	}
+
+	todoID, err := requestTodoID(r, dto.TodoID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := ReopenTodoCommand{
+		UserID: userID,
+		TodoID: todoID,
+	}
+
+	todo, err := h.Service.Reopen(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseTodoDTO(todo))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", todoETag(todo))
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,79 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type ReorderItemsHttpHandler struct {
+	Service ChecklistService
+}
+
+func NewReorderItemsHttpHandler(s ChecklistService) *ReorderItemsHttpHandler {
+	return &ReorderItemsHttpHandler{Service: s}
+}
+
+type ReorderItemsRequestDTO struct {
+	ItemIDs []int `json:"item_ids"`
+}
+
+func (h ReorderItemsHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := ReorderItemsRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := ReorderItemsCommand{
+		UserID:  userID,
+		TodoID:  todoID,
+		ItemIDs: dto.ItemIDs,
+	}
+
+	items, err := h.Service.ReorderItems(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidItemOrder) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newGetItemsResponseDTO(items))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
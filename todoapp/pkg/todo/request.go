@@ -0,0 +1,82 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+var (
+	errForeignUser      = errors.New("user_id does not match the authenticated user")
+	errMissingTodoID    = errors.New("todo id not provided")
+	errInvalidTodoID    = errors.New("todo id must be a number")
+	errInvalidItemID    = errors.New("item id must be a number")
+	errInvalidCommentID = errors.New("comment id must be a number")
+	errUnauthenticated  = errors.New("request is not authenticated")
+)
+
+// requestUserID returns the authenticated user of the request. The deprecated /todo routes
+// still carry a user_id chosen by the client, which is only accepted when it is the same user.
+func requestUserID(r *http.Request, claimedUserID int) (int, error) {
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		return 0, errUnauthenticated
+	}
+	if claimedUserID != 0 && claimedUserID != userID {
+		return 0, errForeignUser
+	}
+	return userID, nil
+}
+
+// requestTodoID returns the todo id from the {id} path parameter, falling back to the
+// todo_id sent in the body of the deprecated /todo routes.
+func requestTodoID(r *http.Request, bodyTodoID int) (int, error) {
+	param := chi.URLParam(r, "id")
+	if param == "" {
+		if bodyTodoID == 0 {
+			return 0, errMissingTodoID
+		}
+		return bodyTodoID, nil
+	}
+	todoID, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, errInvalidTodoID
+	}
+	return todoID, nil
+}
+
+// decodeBody unmarshals an optional JSON body, since routes with the todo id in the path
+// may be called without one.
+func decodeBody(r *http.Request, dst interface{}) error {
+	defer r.Body.Close()
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes) == 0 {
+		return nil
+	}
+	return json.Unmarshal(bytes, dst)
+}
+
+// requestItemID returns the checklist item id from the {itemID} path parameter.
+func requestItemID(r *http.Request) (int, error) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "itemID"))
+	if err != nil {
+		return 0, errInvalidItemID
+	}
+	return itemID, nil
+}
+
+// requestCommentID returns the comment id from the {commentID} path parameter.
+func requestCommentID(r *http.Request) (int, error) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "commentID"))
+	if err != nil {
+		return 0, errInvalidCommentID
+	}
+	return commentID, nil
+}
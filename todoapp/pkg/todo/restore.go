@@ -0,0 +1,57 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type RestoreTodoHttpHandler struct {
+	Service Service
+}
+
+func NewRestoreTodoHttpHandler(s Service) *RestoreTodoHttpHandler {
+	return &RestoreTodoHttpHandler{Service: s}
+}
+
+func (h RestoreTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := RestoreTodoCommand{
+		UserID: userID,
+		TodoID: todoID,
+	}
+
+	todo, err := h.Service.Restore(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseTodoDTO(todo))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", todoETag(todo))
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
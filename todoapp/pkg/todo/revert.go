@@ -0,0 +1,92 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type RevertTodoHttpHandler struct {
+	Service RevisionService
+}
+
+func NewRevertTodoHttpHandler(s RevisionService) *RevertTodoHttpHandler {
+	return &RevertTodoHttpHandler{Service: s}
+}
+
+type RevertTodoRequestDTO struct {
+	RevisionID int `json:"revision_id"`
+}
+
+func (h RevertTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := RevertTodoRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	ifMatch, err := requestIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	cmd := RevertTodoCommand{
+		UserID:     userID,
+		TodoID:     todoID,
+		RevisionID: dto.RevisionID,
+		IfMatch:    ifMatch,
+	}
+
+	todo, err := h.Service.Revert(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrRevisionNotFound) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseTodoDTO(todo))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", todoETag(todo))
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,270 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/policy"
+	"strings"
+	"time"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionRevert  = "revert"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision records how the title and content of a todo changed, and who changed them. The old
+// values are nil for a create, and the new ones for a delete.
+type Revision struct {
+	RevisionID int
+	TodoID     int
+	UserID     *int
+	Action     string
+	OldTitle   *string
+	NewTitle   *string
+	OldContent *string
+	NewContent *string
+	CreatedAt  time.Time
+}
+
+// ContentDiff is a line diff of the old and new content, each line prefixed with "+ " when it
+// was added, "- " when it was removed or "  " when it was kept.
+func (r *Revision) ContentDiff() []string {
+	return diffLines(lines(r.OldContent), lines(r.NewContent))
+}
+
+type RevisionService interface {
+	History(ctx context.Context, cmd *GetHistoryCommand) ([]*Revision, error)
+	Revert(ctx context.Context, cmd *RevertTodoCommand) (*Todo, error)
+}
+
+type GetHistoryCommand struct {
+	UserID int
+	TodoID int
+}
+
+// RevertTodoCommand brings the title and content of a todo back to what they were right after
+// a revision. For a delete revision, that is what they were when the todo was deleted. It fails
+// with ErrVersionMismatch unless the todo is at one of the versions of IfMatch, when set.
+type RevertTodoCommand struct {
+	UserID     int
+	TodoID     int
+	RevisionID int
+	IfMatch    Versions
+}
+
+const revisionColumns = `revision_id, todo_id, user_id, action, old_title, new_title, old_content, new_content, created_at`
+
+func scanRevision(row scanner) (*Revision, error) {
+	var r Revision
+	err := row.Scan(&r.RevisionID, &r.TodoID, &r.UserID, &r.Action, &r.OldTitle, &r.NewTitle, &r.OldContent, &r.NewContent, &r.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// recordRevision stores a revision of a todo going from old to new, either of which may be nil.
+func recordRevision(tx *pgx.Tx, userID int, action string, old *Todo, new *Todo) error {
+	var todoID int
+	var oldTitle, newTitle, oldContent, newContent *string
+	if old != nil {
+		todoID, oldTitle, oldContent = old.TodoID, &old.Title, &old.Content
+	}
+	if new != nil {
+		todoID, newTitle, newContent = new.TodoID, &new.Title, &new.Content
+	}
+
+	query := `insert into todo_revisions(todo_id, user_id, action, old_title, new_title, old_content, new_content) values ($1,$2,$3,$4,$5,$6,$7)`
+	_, err := tx.Exec(query, todoID, userID, action, oldTitle, newTitle, oldContent, newContent)
+	return err
+}
+
+// lockTodo reads a todo that isn't in the trash, checks the user has permission p on it, and
+// locks it for the rest of the transaction.
+func lockTodo(tx *pgx.Tx, userID int, todoID int, p policy.Permission) (*Todo, error) {
+	query := `select ` + todoColumns + ` from todos where todo_id = $1 and deleted_at is null for update`
+	todo, err := scanTodo(tx.QueryRow(query, todoID))
+	if err != nil {
+		return nil, err
+	}
+	err = authorize(tx, userID, todo, p)
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// History lists the revisions of a todo, newest first. The history of a todo in the trash can
+// still be read.
+func (s ServiceImpl) History(ctx context.Context, cmd *GetHistoryCommand) ([]*Revision, error) {
+
+	todo, err := scanTodo(s.conn.QueryRow(`select `+todoColumns+` from todos where todo_id = $1`, cmd.TodoID))
+	if err != nil {
+		return nil, err
+	}
+	err = authorize(s.conn, cmd.UserID, todo, policy.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query(`select `+revisionColumns+` from todo_revisions where todo_id = $1 order by revision_id desc`, todo.TodoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*Revision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s ServiceImpl) Revert(ctx context.Context, cmd *RevertTodoCommand) (*Todo, error) {
+
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) error {
+		current, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionEdit)
+		if err != nil {
+			return err
+		}
+		if !cmd.IfMatch.Allow(current.Version) {
+			return ErrVersionMismatch
+		}
+
+		query := `select ` + revisionColumns + ` from todo_revisions where revision_id = $1 and todo_id = $2`
+		revision, err := scanRevision(tx.QueryRow(query, cmd.RevisionID, cmd.TodoID))
+		if err != nil {
+			return err
+		}
+
+		title, content := revision.NewTitle, revision.NewContent
+		if revision.Action == ActionDelete {
+			title, content = revision.OldTitle, revision.OldContent
+		}
+
+		_, err = tx.Exec(`update todos set version = version + 1, title = $1, content = $2, updated_at = $3 where todo_id = $4`, title, content, time.Now(), cmd.TodoID)
+		if err != nil {
+			return err
+		}
+
+		todo, err = fetch(tx, cmd.TodoID)
+		if err != nil {
+			return err
+		}
+		err = recordRevision(tx, cmd.UserID, ActionRevert, current, todo)
+		if err != nil {
+			return err
+		}
+		return s.changed(tx, events.TodoUpdated, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+func lines(s *string) []string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return strings.Split(*s, "\n")
+}
+
+// maxDiffCells bounds the table diffLines builds for the lines that differ. Content changed past it
+// is shown as entirely removed and added instead.
+const maxDiffCells = 1 << 20
+
+// diffLines computes a line diff from the longest common subsequence of a and b. The lines both
+// start and end with are kept without being compared again.
+func diffLines(a []string, b []string) []string {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]string, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		diff = append(diff, "  "+line)
+	}
+	diff = append(diff, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		diff = append(diff, "  "+line)
+	}
+	return diff
+}
+
+// diffMiddle diffs the lines between the common prefix and suffix, which it gives up on when
+// there are too many of them to compare each with each.
+func diffMiddle(a []string, b []string) []string {
+	diff := make([]string, 0, len(a)+len(b))
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			diff = append(diff, "- "+line)
+		}
+		for _, line := range b {
+			diff = append(diff, "+ "+line)
+		}
+		return diff
+	}
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+	return diff
+}
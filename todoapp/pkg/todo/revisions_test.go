@@ -0,0 +1,50 @@
+package todo
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        []string
+		b        []string
+		expected []string
+	}{
+		{"both empty", nil, nil, []string{}},
+		{"created", nil, []string{"a", "b"}, []string{"+ a", "+ b"}},
+		{"deleted", []string{"a", "b"}, nil, []string{"- a", "- b"}},
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, []string{"  a", "  b"}},
+		{"line changed", []string{"a", "b"}, []string{"a", "c"}, []string{"  a", "- b", "+ c"}},
+		{"line inserted", []string{"a", "c"}, []string{"a", "b", "c"}, []string{"  a", "+ b", "  c"}},
+		{"line removed", []string{"a", "b", "c"}, []string{"a", "c"}, []string{"  a", "- b", "  c"}},
+		{"lines reordered", []string{"a", "b", "c"}, []string{"c", "a", "b"}, []string{"+ c", "  a", "  b", "- c"}},
+		{"repeated lines", []string{"a", "a"}, []string{"a", "a", "a"}, []string{"  a", "  a", "+ a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, diffLines(test.a, test.b))
+		})
+	}
+
+	t.Run("too many changed lines should be removed and added whole", func(t *testing.T) {
+		a, b := make([]string, 2000), make([]string, 2000)
+		for i := range a {
+			a[i], b[i] = fmt.Sprint("a", i), fmt.Sprint("b", i)
+		}
+		a[0], b[0] = "same", "same"
+		b[2] = "a1"
+
+		diff := diffLines(a, b)
+		require.Equal(t, 4000-1, len(diff))
+		require.Equal(t, "  same", diff[0])
+		require.Equal(t, "- a1", diff[1])
+		require.Equal(t, "+ a1", diff[2001])
+		for _, line := range diff[1:] {
+			require.False(t, strings.HasPrefix(line, "  "))
+		}
+	})
+}
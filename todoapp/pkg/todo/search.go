@@ -0,0 +1,81 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type SearchTodosHttpHandler struct {
+	Service Service
+}
+
+func NewSearchTodosHttpHandler(s Service) *SearchTodosHttpHandler {
+	return &SearchTodosHttpHandler{Service: s}
+}
+
+type SearchResponseDTO struct {
+	Results []SearchResultDTO `json:"results"`
+}
+
+// SearchResultDTO is a todo matching a search. The highlights are HTML, with the title and
+// content escaped and their matches wrapped in <mark> tags.
+type SearchResultDTO struct {
+	ResponseTodoDTO
+	Rank             float64 `json:"rank"`
+	TitleHighlight   string  `json:"title_highlight"`
+	ContentHighlight string  `json:"content_highlight"`
+}
+
+func (h SearchTodosHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := SearchTodosCommand{
+		UserID: userID,
+		Query:  r.URL.Query().Get("q"),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		cmd.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(ErrInvalidSearchLimit.Error()))
+			return
+		}
+	}
+
+	results, err := h.Service.Search(r.Context(), &cmd)
+	if errors.Is(err, ErrEmptySearch) || errors.Is(err, ErrInvalidSearchLimit) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]SearchResultDTO, 0, len(results))
+	for _, result := range results {
+		res = append(res, SearchResultDTO{
+			ResponseTodoDTO:  newResponseTodoDTO(result.Todo),
+			Rank:             result.Rank,
+			TitleHighlight:   result.TitleHighlight,
+			ContentHighlight: result.ContentHighlight,
+		})
+	}
+
+	bytes, err := json.Marshal(SearchResponseDTO{Results: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
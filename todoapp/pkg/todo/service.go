@@ -4,26 +4,141 @@ import (
 	"context"
 	"errors"
 	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/policy"
+	"kuberneteslab/todoapp/pkg/tag"
 	"time"
 )
 
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+const (
+	StatusAll       = "all"
+	StatusOpen      = "open"
+	StatusCompleted = "completed"
+)
+
+const (
+	SortCreated  = "created"
+	SortUpdated  = "updated"
+	SortDue      = "due"
+	SortPriority = "priority"
+)
+
+const (
+	TagMatchAll = "all"
+	TagMatchAny = "any"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+var (
+	ErrTodoNotFound       = errors.New("todo not found")
+	ErrInvalidPriority    = errors.New("priority must be between 0 and 3")
+	ErrInvalidStatus      = errors.New("status must be one of all, open or completed")
+	ErrInvalidSort        = errors.New("sort must be one of created, updated, due or priority")
+	ErrInvalidLimit       = errors.New("limit must be between 1 and 200")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrInvalidTagMatch    = errors.New("tag_match must be all or any")
+	ErrEmptySearch        = errors.New("search query must not be empty")
+	ErrInvalidSearchLimit = errors.New("limit must be between 1 and 100")
+	ErrVersionMismatch    = errors.New("todo has been changed since it was read")
+	ErrInvalidAssignee    = errors.New("todos can only be assigned to users who can access them")
+)
+
 type Todo struct {
-	TodoID  int
-	UserID  int
-	Title   string
-	Content string
+	TodoID int
+	UserID int
+	ListID *int
+	// AssigneeID is the user the todo is assigned to, who has access to it.
+	AssigneeID  *int
+	Title       string
+	Content     string
+	Completed   bool
+	CompletedAt *time.Time
+	DueAt       *time.Time
+	Priority    int
+	Tags        []string
+	ItemsTotal  int
+	ItemsDone   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+	// Version is incremented by every change of the todo.
+	Version int
+
+	// RecurrenceRule is the RRULE the todo repeats with, expanded in RecurrenceTZ from the due
+	// date of the first todo of the series. RecurrenceIndex is the position of the todo in its
+	// series, and NextTodoID the occurrence spawned when it was completed.
+	RecurrenceRule  *string
+	RecurrenceTZ    *string
+	RecurrenceIndex int
+	NextTodoID      *int
+	recurrenceStart *time.Time
+}
+
+// Progress is the percentage of checklist items done. A todo without checklist is either
+// not started or fully done.
+func (t *Todo) Progress() int {
+	if t.ItemsTotal == 0 {
+		if t.Completed {
+			return 100
+		}
+		return 0
+	}
+	return t.ItemsDone * 100 / t.ItemsTotal
+}
+
+// TodoPage is one page of a todo listing. NextCursor is empty on the last page.
+type TodoPage struct {
+	Todos      []*Todo
+	NextCursor string
 }
 
 type Service interface {
-	GetAll(ctx context.Context, cmd *GetAllTodosCommand) ([]*Todo, error)
+	GetAll(ctx context.Context, cmd *GetAllTodosCommand) (*TodoPage, error)
 	Create(ctx context.Context, cmd *CreateTodoCommand) (*Todo, error)
 	Update(ctx context.Context, cmd *UpdateTodoCommand) (*Todo, error)
 	Get(ctx context.Context, cmd *GetTodoCommand) (*Todo, error)
 	Delete(ctx context.Context, cmd *DeleteTodoCommand) (*Todo, error)
+	Complete(ctx context.Context, cmd *CompleteTodoCommand) (*Todo, error)
+	Reopen(ctx context.Context, cmd *ReopenTodoCommand) (*Todo, error)
+	Search(ctx context.Context, cmd *SearchTodosCommand) ([]*SearchResult, error)
+	GetTrash(ctx context.Context, cmd *GetTrashCommand) ([]*Todo, error)
+	Restore(ctx context.Context, cmd *RestoreTodoCommand) (*Todo, error)
+	Purge(ctx context.Context, cmd *PurgeTodoCommand) (*Todo, error)
 }
 
+// GetAllTodosCommand lists the todos of the user along with those shared with them.
 type GetAllTodosCommand struct {
-	UserID int
+	UserID       int
+	ListID       *int
+	NoList       bool
+	AssignedToMe bool
+	Status       string
+	DueAfter     *time.Time
+	DueBefore    *time.Time
+	Text         string
+	Tags         []string
+	TagMatch     string
+	Sort         string
+	Desc         bool
+	Limit        int
+	Cursor       string
 }
 
 type GetTodoCommand struct {
@@ -32,24 +147,232 @@ type GetTodoCommand struct {
 }
 
 type CreateTodoCommand struct {
-	UserID  int
-	Title   string
-	Content string
+	UserID       int
+	ListID       *int
+	AssigneeID   *int
+	Title        string
+	Content      string
+	DueAt        *time.Time
+	Priority     int
+	Tags         []string
+	Recurrence   string
+	RecurrenceTZ string
+}
+
+// Nullable is the change of a field that can be null. The field is left untouched unless Set,
+// and cleared when Set with a nil Value.
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+// Versions are the versions a conditional change expects a todo at, any of which will do. Nil
+// Versions allow every version.
+type Versions []int
+
+// Allow reports whether a todo at version can be changed.
+func (v Versions) Allow(version int) bool {
+	if v == nil {
+		return true
+	}
+	for _, allowed := range v {
+		if allowed == version {
+			return true
+		}
+	}
+	return false
 }
 
+// UpdateTodoCommand changes the fields of a todo that are set and leaves the others untouched.
+// Tags are replaced unless nil. A todo keeps its place in its recurrence series unless its rule
+// or time zone change, which start a new series at its due date. When IfMatch is set, the
+// update fails with ErrVersionMismatch unless the todo is still at one of its versions.
 type UpdateTodoCommand struct {
+	UserID       int
+	TodoID       int
+	IfMatch      Versions
+	ListID       Nullable[int]
+	AssigneeID   Nullable[int]
+	Title        *string
+	Content      *string
+	DueAt        Nullable[time.Time]
+	Priority     *int
+	Tags         []string
+	Recurrence   Nullable[string]
+	RecurrenceTZ *string
+}
+
+// todoFields are the fields of a todo an update writes.
+type todoFields struct {
+	listID     *int
+	assigneeID *int
+	title      string
+	content    string
+	dueAt      *time.Time
+	priority   int
+	rule       string
+	tz         string
+}
+
+// apply returns the fields of current with the changes of the command.
+func (cmd *UpdateTodoCommand) apply(current *Todo) todoFields {
+	f := todoFields{
+		listID:     current.ListID,
+		assigneeID: current.AssigneeID,
+		title:      current.Title,
+		content:    current.Content,
+		dueAt:      current.DueAt,
+		priority:   current.Priority,
+	}
+	if current.RecurrenceRule != nil {
+		f.rule, f.tz = *current.RecurrenceRule, *current.RecurrenceTZ
+	}
+
+	if cmd.ListID.Set {
+		f.listID = cmd.ListID.Value
+	}
+	if cmd.AssigneeID.Set {
+		f.assigneeID = cmd.AssigneeID.Value
+	}
+	if cmd.Title != nil {
+		f.title = *cmd.Title
+	}
+	if cmd.Content != nil {
+		f.content = *cmd.Content
+	}
+	if cmd.DueAt.Set {
+		f.dueAt = cmd.DueAt.Value
+	}
+	if cmd.Priority != nil {
+		f.priority = *cmd.Priority
+	}
+	if cmd.Recurrence.Set {
+		f.rule = ""
+		if cmd.Recurrence.Value != nil {
+			f.rule = *cmd.Recurrence.Value
+		}
+	}
+	if cmd.RecurrenceTZ != nil {
+		f.tz = *cmd.RecurrenceTZ
+	}
+	return f
+}
+
+// DeleteTodoCommand fails with ErrVersionMismatch unless the todo is at one of the versions of
+// IfMatch, when set.
+type DeleteTodoCommand struct {
 	UserID  int
 	TodoID  int
-	title   string
-	content string
+	IfMatch Versions
 }
-type DeleteTodoCommand struct {
+
+type CompleteTodoCommand struct {
+	UserID int
+	TodoID int
+}
+
+type ReopenTodoCommand struct {
 	UserID int
 	TodoID int
 }
 
+type SearchTodosCommand struct {
+	UserID int
+	Query  string
+	Limit  int
+}
+
+// SearchResult is a todo matching a search. The highlights are HTML: the title and content are
+// escaped, and the parts of them that matched wrapped in <mark> tags.
+type SearchResult struct {
+	Todo             *Todo
+	Rank             float64
+	TitleHighlight   string
+	ContentHighlight string
+}
+
+// todoColumns is the column list every query returning a Todo selects, in the order scanTodo expects.
+const todoColumns = `todo_id, user_id, list_id, title, content, completed, completed_at, due_at, priority,
+	array(select tg.name from todo_tags tt join tags tg on tg.tag_id = tt.tag_id where tt.todo_id = todos.todo_id order by tg.name),
+	(select count(*) from checklist_items ci where ci.todo_id = todos.todo_id),
+	(select count(*) from checklist_items ci where ci.todo_id = todos.todo_id and ci.done),
+	created_at, updated_at, deleted_at, version, recurrence_rule, recurrence_tz, recurrence_index, recurrence_next_id, recurrence_start, assignee_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo scans the todoColumns of a row, followed by any extra columns the query selected.
+func scanTodo(row scanner, extra ...interface{}) (*Todo, error) {
+	var todo Todo
+	dest := []interface{}{&todo.TodoID, &todo.UserID, &todo.ListID, &todo.Title, &todo.Content, &todo.Completed, &todo.CompletedAt, &todo.DueAt, &todo.Priority, &todo.Tags, &todo.ItemsTotal, &todo.ItemsDone, &todo.CreatedAt, &todo.UpdatedAt, &todo.DeletedAt, &todo.Version, &todo.RecurrenceRule, &todo.RecurrenceTZ, &todo.RecurrenceIndex, &todo.NextTodoID, &todo.recurrenceStart, &todo.AssigneeID}
+	err := row.Scan(append(dest, extra...)...)
+	if err == pgx.ErrNoRows {
+		return nil, ErrTodoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func validPriority(priority int) bool {
+	return priority >= PriorityNone && priority <= PriorityHigh
+}
+
+// utc strips the location of a due date, since the todos table stores timestamps without time zone.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// withTx runs fn in a transaction, which is committed only if fn succeeds.
+func (s ServiceImpl) withTx(fn func(tx *pgx.Tx) error) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// fetch reads a todo by id, without checking who it belongs to.
+func fetch(tx *pgx.Tx, todoID int) (*Todo, error) {
+	query := `select ` + todoColumns + ` from todos where todo_id = $1`
+	return scanTodo(tx.QueryRow(query, todoID))
+}
+
+// setTags replaces the tags of a todo, creating the tags its owner doesn't have yet.
+func setTags(tx *pgx.Tx, userID int, todoID int, tags []string) error {
+
+	_, err := tx.Exec(`delete from todo_tags where todo_id = $1`, todoID)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`insert into tags(user_id, name) select $1, unnest($2::text[]) on conflict (user_id, name) do nothing`, userID, tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`insert into todo_tags(todo_id, tag_id) select $1, tag_id from tags where user_id = $2 and name = any($3::text[])`, todoID, userID, tags)
+	return err
+}
+
 type ServiceImpl struct {
-	conn *pgx.ConnPool
+	conn  *pgx.ConnPool
+	hooks []events.Hook
 }
 
 func NewServiceImpl(conn *pgx.ConnPool) *ServiceImpl {
@@ -60,90 +383,397 @@ func NewServiceImpl(conn *pgx.ConnPool) *ServiceImpl {
 
 func (s ServiceImpl) Create(ctx context.Context, cmd *CreateTodoCommand) (*Todo, error) {
 
-	todo := Todo{}
-	query := `insert into todos(user_id, title, content) values ($1,$2,$3) returning todo_id, user_id, title, content`
-	row := s.conn.QueryRow(query, cmd.UserID, cmd.Title, cmd.Content)
-	if row == nil {
-		return nil, errors.New("err todo create empty row")
-	}
-	err := row.Scan(&todo.TodoID, &todo.UserID, &todo.Title, &todo.Content)
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) (err error) {
+		todo, err = s.create(tx, cmd)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &todo, nil
+	return todo, nil
 }
 
-func (s ServiceImpl) GetAll(ctx context.Context, cmd *GetAllTodosCommand) ([]*Todo, error) {
+func (s ServiceImpl) create(tx *pgx.Tx, cmd *CreateTodoCommand) (*Todo, error) {
+
+	if !validPriority(cmd.Priority) {
+		return nil, ErrInvalidPriority
+	}
+	tags, err := tag.NormalizeNames(cmd.Tags)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := newRecurrence(cmd.Recurrence, cmd.RecurrenceTZ, cmd.DueAt)
+	if err != nil {
+		return nil, err
+	}
 
-	query := `select todo_id, title, content from todos where user_id = $1`
+	// A todo added to a list shared with the user belongs to the owner of the list, like the
+	// other todos in it.
+	ownerID := cmd.UserID
+	if cmd.ListID != nil {
+		ownerID, err = list.CheckWritable(tx, cmd.UserID, *cmd.ListID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	rows, err := s.conn.Query(query, cmd.UserID)
-	if rows == nil {
-		return nil, errors.New("error todo get all empty rows")
+	var todoID int
+	query := `insert into todos(user_id, list_id, title, content, due_at, priority, recurrence_rule, recurrence_tz, recurrence_start, assignee_id)
+		values ($1,$2,$3,$4,$5,$6,$7,$8,case when $7::text is null then null else $5::timestamp end,$9) returning todo_id`
+	err = tx.QueryRow(query, ownerID, cmd.ListID, cmd.Title, cmd.Content, utc(cmd.DueAt), cmd.Priority, rec.rule, rec.tz, cmd.AssigneeID).Scan(&todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags) > 0 {
+		err = setTags(tx, ownerID, todoID, tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	todo, err := fetch(tx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkAssignee(tx, todo)
+	if err != nil {
+		return nil, err
+	}
+	err = recordRevision(tx, cmd.UserID, ActionCreate, nil, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, s.changed(tx, events.TodoCreated, todo)
+}
+
+func (s ServiceImpl) GetAll(ctx context.Context, cmd *GetAllTodosCommand) (*TodoPage, error) {
+
+	q, err := newListQuery(cmd)
+	if err != nil {
+		return nil, err
 	}
+
+	rows, err := s.conn.Query(q.sql, q.args...)
 	if err != nil {
 		return nil, err
 	}
+	if rows == nil {
+		return nil, errors.New("error todo get all empty rows")
+	}
+	defer rows.Close()
 
-	todos := make([]*Todo, 0)
+	todos := make([]*Todo, 0, q.limit+1)
 
 	for rows.Next() {
-		todo := Todo{}
-		rows.Scan(&todo.TodoID, &todo.Title, &todo.Content)
-		todos = append(todos, &todo)
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return todos, nil
+	page := &TodoPage{Todos: todos}
+	if len(todos) > q.limit {
+		page.Todos = todos[:q.limit]
+		page.NextCursor, err = encodeCursor(q.sort, q.desc, page.Todos[q.limit-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
 }
 
+// Get reads a todo of the user, or shared with them.
 func (s ServiceImpl) Get(ctx context.Context, cmd *GetTodoCommand) (*Todo, error) {
-	query := `select todo_id, title, content from todos where user_id = $1 and todo_id = $2`
+	query := `select ` + todoColumns + ` from todos where todo_id = $1 and deleted_at is null`
 
-	row := s.conn.QueryRow(query, cmd.UserID, cmd.TodoID)
+	row := s.conn.QueryRow(query, cmd.TodoID)
 	if row == nil {
 		return nil, errors.New("error GetOne")
 	}
 
-	var todo Todo
-	err := row.Scan(&todo.TodoID, &todo.UserID, &todo.Title, todo.Content)
+	todo, err := scanTodo(row)
 	if err != nil {
 		return nil, err
 	}
-
-	return &todo, nil
+	err = authorize(s.conn, cmd.UserID, todo, policy.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
 }
 
 func (s ServiceImpl) Update(ctx context.Context, cmd *UpdateTodoCommand) (*Todo, error) {
 
-	query := `update todos set title = $1, content = $2, updated_at = $3 where todo_id = $4 returning  todo_id, user_id, title, content`
-	row := s.conn.QueryRow(query, cmd.title, cmd.content, time.Now(), cmd.TodoID)
-	if row == nil {
-		return nil, errors.New("err todo update empty row")
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) (err error) {
+		todo, err = s.update(tx, cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	var todo Todo
-	err := row.Scan(&todo.TodoID, &todo.UserID, &todo.Title, &todo.Content)
+
+	return todo, nil
+}
+
+func (s ServiceImpl) update(tx *pgx.Tx, cmd *UpdateTodoCommand) (*Todo, error) {
+
+	if cmd.Priority != nil && !validPriority(*cmd.Priority) {
+		return nil, ErrInvalidPriority
+	}
+	var tags []string
+	if cmd.Tags != nil {
+		var err error
+		tags, err = tag.NormalizeNames(cmd.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionEdit)
 	if err != nil {
 		return nil, err
 	}
+	if !cmd.IfMatch.Allow(current.Version) {
+		return nil, ErrVersionMismatch
+	}
 
-	return &todo, nil
+	// Moving a todo between lists changes who it is shared with, which only its owner decides.
+	if cmd.ListID.Set && !sameID(cmd.ListID.Value, current.ListID) {
+		err = authorize(tx, cmd.UserID, current, policy.PermissionOwn)
+		if err != nil {
+			return nil, err
+		}
+		if cmd.ListID.Value != nil {
+			ownerID, err := list.CheckWritable(tx, current.UserID, *cmd.ListID.Value)
+			if err != nil {
+				return nil, err
+			}
+			if ownerID != current.UserID {
+				return nil, list.ErrListNotFound
+			}
+		}
+	}
+
+	next := cmd.apply(current)
+	rec, err := newRecurrence(next.rule, next.tz, next.dueAt)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `update todos set version = version + 1, list_id = $1, title = $2, content = $3, due_at = $4, priority = $5, updated_at = $6,
+		recurrence_start = case when recurrence_rule is not distinct from $8 and recurrence_tz is not distinct from $9 then recurrence_start when $8::text is null then null else $4::timestamp end,
+		recurrence_index = case when recurrence_rule is not distinct from $8 and recurrence_tz is not distinct from $9 then recurrence_index else 0 end,
+		recurrence_rule = $8, recurrence_tz = $9, assignee_id = $10
+		where todo_id = $7`
+	_, err = tx.Exec(query, next.listID, next.title, next.content, utc(next.dueAt), next.priority, time.Now(), cmd.TodoID, rec.rule, rec.tz, next.assigneeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags != nil {
+		err = setTags(tx, current.UserID, cmd.TodoID, tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	todo, err := fetch(tx, cmd.TodoID)
+	if err != nil {
+		return nil, err
+	}
+	// Moving a todo to another list changes who can access it, so the assignee is checked then too.
+	if !sameID(todo.AssigneeID, current.AssigneeID) || !sameID(todo.ListID, current.ListID) {
+		err = checkAssignee(tx, todo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = recordRevision(tx, cmd.UserID, ActionUpdate, current, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, s.updated(tx, current, todo)
 }
 
+// Delete moves a todo to the trash, from where it can be restored until it is purged.
 func (s ServiceImpl) Delete(ctx context.Context, cmd *DeleteTodoCommand) (*Todo, error) {
 
-	query := `delete from todos where todo_id = $1 returning  todo_id, user_id, title, content`
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) (err error) {
+		todo, err = s.remove(tx, cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	row := s.conn.QueryRow(query, cmd.TodoID)
-	if row == nil {
-		return nil, errors.New("error sql delete empty row")
+	return todo, nil
+}
+
+func (s ServiceImpl) remove(tx *pgx.Tx, cmd *DeleteTodoCommand) (*Todo, error) {
+
+	current, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionOwn)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.IfMatch.Allow(current.Version) {
+		return nil, ErrVersionMismatch
 	}
 
-	todo := Todo{}
-	err := row.Scan(&todo.TodoID, &todo.UserID, &todo.Title, &todo.Content)
+	_, err = tx.Exec(`update todos set version = version + 1, deleted_at = $1, updated_at = $1 where todo_id = $2`, time.Now(), cmd.TodoID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &todo, nil
+	todo, err := fetch(tx, cmd.TodoID)
+	if err != nil {
+		return nil, err
+	}
+	err = recordRevision(tx, cmd.UserID, ActionDelete, current, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, s.changed(tx, events.TodoDeleted, todo)
+}
+
+// Complete marks a todo as done. Completing a recurring todo for the first time spawns its
+// next occurrence in the same transaction.
+func (s ServiceImpl) Complete(ctx context.Context, cmd *CompleteTodoCommand) (*Todo, error) {
+
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) (err error) {
+		todo, err = s.complete(tx, cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+func (s ServiceImpl) complete(tx *pgx.Tx, cmd *CompleteTodoCommand) (*Todo, error) {
+
+	current, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextID *int
+	if !current.Completed && current.RecurrenceRule != nil && current.NextTodoID == nil {
+		id, err := s.spawnNext(tx, current)
+		if err != nil {
+			return nil, err
+		}
+		if id != 0 {
+			nextID = &id
+		}
+	}
+
+	query := `update todos set version = version + 1, completed = true, completed_at = coalesce(completed_at, $1), updated_at = $1,
+		recurrence_next_id = coalesce(recurrence_next_id, $2) where todo_id = $3`
+	_, err = tx.Exec(query, time.Now(), nextID, cmd.TodoID)
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := fetch(tx, cmd.TodoID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Completed {
+		return todo, s.changed(tx, events.TodoUpdated, todo)
+	}
+	return todo, s.changed(tx, events.TodoCompleted, todo)
+}
+
+func (s ServiceImpl) Reopen(ctx context.Context, cmd *ReopenTodoCommand) (*Todo, error) {
+
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) (err error) {
+		todo, err = s.reopen(tx, cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+func (s ServiceImpl) reopen(tx *pgx.Tx, cmd *ReopenTodoCommand) (*Todo, error) {
+	_, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `update todos set version = version + 1, completed = false, completed_at = null, updated_at = $1 where todo_id = $2 returning ` + todoColumns
+	todo, err := scanTodo(tx.QueryRow(query, time.Now(), cmd.TodoID))
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, s.changed(tx, events.TodoUpdated, todo)
+}
+
+// escapeHTML is the SQL expression of column escaped for HTML, so that the markup a todo holds
+// comes back as text once highlighted.
+func escapeHTML(column string) string {
+	return `replace(replace(replace(replace(replace(` + column + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// Search ranks full-text matches of title and content among the todos the user can view, and
+// falls back to trigram similarity so that queries with typos still find their todos.
+func (s ServiceImpl) Search(ctx context.Context, cmd *SearchTodosCommand) ([]*SearchResult, error) {
+
+	if cmd.Query == "" {
+		return nil, ErrEmptySearch
+	}
+	limit := cmd.Limit
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit < 0 || limit > MaxSearchLimit {
+		return nil, ErrInvalidSearchLimit
+	}
+
+	query := `select ` + todoColumns + `,
+		(ts_rank(search, q) + greatest(word_similarity($2, title), word_similarity($2, content)))::float8 as rank,
+		ts_headline('english', ` + escapeHTML("title") + `, q, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+		ts_headline('english', ` + escapeHTML("content") + `, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+		from todos, websearch_to_tsquery('english', $2) q
+		where ` + policy.VisibleTodos("$1") + ` and deleted_at is null and (search @@ q or $2 <% title or $2 <% content)
+		order by rank desc, todo_id desc
+		limit $3`
+
+	rows, err := s.conn.Query(query, cmd.UserID, cmd.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*SearchResult, 0)
+	for rows.Next() {
+		var result SearchResult
+		result.Todo, err = scanTodo(rows, &result.Rank, &result.TitleHighlight, &result.ContentHighlight)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &result)
+	}
+
+	return results, rows.Err()
 }
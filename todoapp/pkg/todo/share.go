@@ -0,0 +1,131 @@
+package todo
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/policy"
+	"time"
+)
+
+// ShareService manages who a todo is shared with, which only its owner can do.
+type ShareService interface {
+	GetShares(ctx context.Context, cmd *GetSharesCommand) ([]*policy.Share, error)
+	Share(ctx context.Context, cmd *ShareTodoCommand) (*policy.Share, error)
+	Unshare(ctx context.Context, cmd *UnshareTodoCommand) (*policy.Share, error)
+}
+
+type GetSharesCommand struct {
+	UserID int
+	TodoID int
+}
+
+// ShareTodoCommand grants Role to the user of Email, or changes the role they have.
+type ShareTodoCommand struct {
+	UserID int
+	TodoID int
+	Email  string
+	Role   string
+}
+
+// UnshareTodoCommand revokes the share of a todo with ShareUserID. Besides the owner, users
+// can revoke their own share.
+type UnshareTodoCommand struct {
+	UserID      int
+	TodoID      int
+	ShareUserID int
+}
+
+// authorize checks the user has permission p on a todo, failing with ErrTodoNotFound when it
+// isn't theirs nor shared with them.
+func authorize(q policy.Querier, userID int, todo *Todo, p policy.Permission) error {
+	role, err := policy.TodoRole(q, userID, todo.UserID, todo.TodoID, todo.ListID)
+	if err != nil {
+		return err
+	}
+	return policy.Check(role, p, ErrTodoNotFound)
+}
+
+// checkAssignee fails with ErrInvalidAssignee when the todo is assigned to a user who can't access it.
+func checkAssignee(q policy.Querier, todo *Todo) error {
+	if todo.AssigneeID == nil {
+		return nil
+	}
+	role, err := policy.TodoRole(q, *todo.AssigneeID, todo.UserID, todo.TodoID, todo.ListID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return ErrInvalidAssignee
+	}
+	return nil
+}
+
+// sameID reports whether two optional ids are equal.
+func sameID(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (s ServiceImpl) GetShares(ctx context.Context, cmd *GetSharesCommand) ([]*policy.Share, error) {
+
+	var shares []*policy.Share
+	err := s.withTx(func(tx *pgx.Tx) error {
+		_, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionOwn)
+		if err != nil {
+			return err
+		}
+		shares, err = policy.TodoShares.Get(tx, cmd.TodoID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s ServiceImpl) Share(ctx context.Context, cmd *ShareTodoCommand) (*policy.Share, error) {
+
+	var share *policy.Share
+	err := s.withTx(func(tx *pgx.Tx) error {
+		todo, err := lockTodo(tx, cmd.UserID, cmd.TodoID, policy.PermissionOwn)
+		if err != nil {
+			return err
+		}
+		share, err = policy.TodoShares.Grant(tx, cmd.TodoID, todo.UserID, cmd.Email, cmd.Role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (s ServiceImpl) Unshare(ctx context.Context, cmd *UnshareTodoCommand) (*policy.Share, error) {
+
+	permission := policy.PermissionOwn
+	if cmd.ShareUserID == cmd.UserID {
+		permission = policy.PermissionView
+	}
+
+	var share *policy.Share
+	err := s.withTx(func(tx *pgx.Tx) error {
+		_, err := lockTodo(tx, cmd.UserID, cmd.TodoID, permission)
+		if err != nil {
+			return err
+		}
+		share, err = policy.TodoShares.Revoke(tx, cmd.TodoID, cmd.ShareUserID)
+		if err != nil {
+			return err
+		}
+		return policy.ForgetTodos(tx, time.Now(), `select todo_id, $2::bigint as user_id from todos where todo_id = $1`, cmd.TodoID, cmd.ShareUserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
@@ -0,0 +1,78 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type ShareTodoHttpHandler struct {
+	Service ShareService
+}
+
+func NewShareTodoHttpHandler(s ShareService) *ShareTodoHttpHandler {
+	return &ShareTodoHttpHandler{Service: s}
+}
+
+// ServeHTTP shares a todo with the user of the email, or changes their role when it already is.
+func (h ShareTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := policy.ShareRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := ShareTodoCommand{
+		UserID: userID,
+		TodoID: todoID,
+		Email:  dto.Email,
+		Role:   dto.Role,
+	}
+
+	share, err := h.Service.Share(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrInvalidRole) || errors.Is(err, policy.ErrUserNotFound) || errors.Is(err, policy.ErrShareWithOwner) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(policy.NewResponseShareDTO(share))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,115 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kuberneteslab/todoapp/pkg/events"
+	"net/http"
+	"time"
+)
+
+// KeepAliveInterval is how often a comment is sent on an idle event stream, so that proxies
+// don't close it.
+const KeepAliveInterval = 25 * time.Second
+
+// EventSource delivers the events of todos to its subscribers.
+type EventSource interface {
+	Subscribe(filter func(events.Event) bool) *events.Subscription
+	Unsubscribe(s *events.Subscription)
+}
+
+type StreamEventsHttpHandler struct {
+	Service Service
+	Events  EventSource
+}
+
+func NewStreamEventsHttpHandler(s Service, e EventSource) *StreamEventsHttpHandler {
+	return &StreamEventsHttpHandler{Service: s, Events: e}
+}
+
+// DeletedTodoEventDTO is the data of a deleted event. Created and updated events carry the
+// todo as ResponseTodoDTO.
+type DeletedTodoEventDTO struct {
+	TodoID  int `json:"todo_id"`
+	Version int `json:"version"`
+}
+
+// ServeHTTP streams the created, updated and deleted events of the todos the user can view as
+// Server-Sent Events. A restored todo is sent as created. The stream ends when events may have
+// been missed, and the client should then read its todos again after reconnecting.
+func (h StreamEventsHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("streaming is not supported"))
+		return
+	}
+
+	subscription := h.Events.Subscribe(func(event events.Event) bool {
+		return event.VisibleTo(userID)
+	})
+	defer h.Events.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("retry: 3000\n\n"))
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			w.Write([]byte(": keep-alive\n\n"))
+		case event, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			data, err := eventData(r.Context(), h.Service, userID, event)
+			if errors.Is(err, ErrTodoNotFound) {
+				// Deleted or unshared since, or moved out of a list shared with the user.
+				continue
+			}
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", streamEventType(event.Type), data)
+		}
+		flusher.Flush()
+	}
+}
+
+// streamEventType is the type of an event on the event streams, which send completions as updates.
+func streamEventType(eventType string) string {
+	if eventType == events.TodoCompleted {
+		return events.TodoUpdated
+	}
+	return eventType
+}
+
+// eventData is the data sent to userID for an event: the todo as they see it now, or its id once deleted.
+func eventData(ctx context.Context, s Service, userID int, event events.Event) ([]byte, error) {
+	if event.Type == events.TodoDeleted {
+		return json.Marshal(DeletedTodoEventDTO{TodoID: event.TodoID, Version: event.Version})
+	}
+
+	todo, err := s.Get(ctx, &GetTodoCommand{UserID: userID, TodoID: event.TodoID})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(newResponseTodoDTO(todo))
+}
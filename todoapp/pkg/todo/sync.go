@@ -0,0 +1,437 @@
+package todo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/policy"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	// SyncLastWriterWins resolves a conflicting change by keeping the side changed last.
+	SyncLastWriterWins = "last_writer_wins"
+	// SyncFieldMerge keeps the changes of both sides to different fields, and resolves the
+	// fields changed on both sides by keeping the side changed last.
+	SyncFieldMerge = "field_merge"
+
+	ResolvedClient = "client"
+	ResolvedServer = "server"
+
+	DefaultSyncLimit = 500
+	MaxSyncLimit     = 1000
+
+	// SyncOverlap is how far back from the last change a sync token starts the next sync. Changes
+	// of transactions that were still running when a sync read the todos, or written by pods
+	// with a skewed clock, may have an updated_at older than changes already read; reading a
+	// window again catches them, at the cost of returning some changes twice.
+	SyncOverlap = 30 * time.Second
+)
+
+var (
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	ErrInvalidStrategy  = errors.New("strategy must be last_writer_wins or field_merge")
+	ErrSyncSize         = errors.New("a sync must have between 1 and 100 changes")
+)
+
+type SyncService interface {
+	Changes(ctx context.Context, cmd *GetChangesCommand) (*ChangesPage, error)
+	Sync(ctx context.Context, cmd *SyncCommand) ([]*SyncResult, error)
+}
+
+// GetChangesCommand reads the changes since the token of a previous page, or every todo when
+// Since is empty.
+type GetChangesCommand struct {
+	UserID int
+	Since  string
+	Limit  int
+}
+
+// Change is a todo that changed, or the tombstone of a todo deleted for good or no longer shared
+// with the user, which has no Todo.
+type Change struct {
+	TodoID    int
+	Todo      *Todo
+	ChangedAt time.Time
+}
+
+// Deleted reports whether the todo of the change is in the trash or was deleted for good.
+func (c *Change) Deleted() bool {
+	return c.Todo == nil || c.Todo.DeletedAt != nil
+}
+
+// ChangesPage is a page of changes in the order they were made. Token reads the next page when
+// HasMore, and the changes made since otherwise.
+type ChangesPage struct {
+	Changes []*Change
+	Token   string
+	HasMore bool
+}
+
+// SyncCommand applies the changes a client made offline, in order.
+type SyncCommand struct {
+	UserID   int
+	Strategy string
+	Changes  []SyncChange
+}
+
+// SyncChange is a change made by a client at ChangedAt to the todo as it was at BaseVersion, or
+// a todo it created when TodoID is 0. Fields is a merge patch of the todo, and Base the values
+// the fields it changes had at BaseVersion, which field merges use to tell which fields the
+// server changed since.
+type SyncChange struct {
+	ClientID    string
+	TodoID      int
+	BaseVersion int
+	ChangedAt   time.Time
+	Deleted     bool
+	Fields      map[string]json.RawMessage
+	Base        map[string]json.RawMessage
+}
+
+// SyncConflict is a change made on the server to a todo since the base version of a client
+// change. Fields are the fields both changed, and are empty when either deleted the todo.
+type SyncConflict struct {
+	Fields     []string
+	Resolution string
+}
+
+// SyncResult is the outcome of a change. Todo is the todo as it is on the server afterwards,
+// nil if it was deleted for good.
+type SyncResult struct {
+	ClientID string
+	TodoID   int
+	Todo     *Todo
+	Conflict *SyncConflict
+	Err      error
+}
+
+// syncToken is where a sync resumes: after the change at Cursor and CursorID. High is the
+// latest change returned so far, which the overlap of the next sync is taken from.
+type syncToken struct {
+	Cursor   time.Time
+	CursorID int
+	High     time.Time
+}
+
+func (t syncToken) String() string {
+	raw := fmt.Sprintf("%d.%d.%d", t.Cursor.UnixMicro(), t.CursorID, t.High.UnixMicro())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseSyncToken(s string) (syncToken, error) {
+	if s == "" {
+		return syncToken{Cursor: time.Unix(0, 0).UTC(), High: time.Unix(0, 0).UTC()}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return syncToken{}, ErrInvalidSyncToken
+	}
+	var cursor, high int64
+	var cursorID int
+	_, err = fmt.Sscanf(string(raw), "%d.%d.%d", &cursor, &cursorID, &high)
+	if err != nil {
+		return syncToken{}, ErrInvalidSyncToken
+	}
+	return syncToken{Cursor: time.UnixMicro(cursor).UTC(), CursorID: cursorID, High: time.UnixMicro(high).UTC()}, nil
+}
+
+// Changes lists the todos the user can view that changed or were shared with them after the
+// token, trashed ones included, along with the tombstones of the todos deleted for good or
+// unshared with them.
+func (s ServiceImpl) Changes(ctx context.Context, cmd *GetChangesCommand) (*ChangesPage, error) {
+
+	token, err := parseSyncToken(cmd.Since)
+	if err != nil {
+		return nil, err
+	}
+	limit := cmd.Limit
+	if limit <= 0 || limit > MaxSyncLimit {
+		limit = DefaultSyncLimit
+	}
+
+	changes := make([]*Change, 0)
+
+	// A todo changes for the user when it is updated, and when it is shared with them.
+	query := `select ` + todoColumns + `, changed_at from (
+			select todos.*, greatest(updated_at, (select granted_at from todo_grants g where g.todo_id = todos.todo_id and g.user_id = $1)) as changed_at
+			from todos where ` + policy.VisibleTodos("$1") + `) todos
+		where (changed_at, todo_id) > ($2, $3)
+		order by changed_at, todo_id limit $4`
+	rows, err := s.conn.Query(query, cmd.UserID, token.Cursor, token.CursorID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var changedAt time.Time
+		todo, err := scanTodo(rows, &changedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &Change{TodoID: todo.TodoID, Todo: todo, ChangedAt: changedAt})
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	rows.Close()
+
+	query = `select todo_id, deleted_at from todo_tombstones where user_id = $1 and (deleted_at, todo_id) > ($2, $3) order by deleted_at, todo_id limit $4`
+	rows, err = s.conn.Query(query, cmd.UserID, token.Cursor, token.CursorID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var change Change
+		err = rows.Scan(&change.TodoID, &change.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &change)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].ChangedAt.Equal(changes[j].ChangedAt) {
+			return changes[i].ChangedAt.Before(changes[j].ChangedAt)
+		}
+		return changes[i].TodoID < changes[j].TodoID
+	})
+
+	page := &ChangesPage{Changes: changes}
+	if len(changes) > limit {
+		page.Changes = changes[:limit]
+		page.HasMore = true
+	}
+
+	next := token
+	if len(page.Changes) > 0 {
+		last := page.Changes[len(page.Changes)-1]
+		next.Cursor, next.CursorID = last.ChangedAt, last.TodoID
+		if last.ChangedAt.After(next.High) {
+			next.High = last.ChangedAt
+		}
+	}
+	if !page.HasMore {
+		next.Cursor, next.CursorID = next.High.Add(-SyncOverlap), 0
+	}
+	page.Token = next.String()
+
+	return page, nil
+}
+
+// Sync applies each change in a savepoint, so a change that fails doesn't prevent the others.
+func (s ServiceImpl) Sync(ctx context.Context, cmd *SyncCommand) ([]*SyncResult, error) {
+
+	if cmd.Strategy != SyncLastWriterWins && cmd.Strategy != SyncFieldMerge {
+		return nil, ErrInvalidStrategy
+	}
+	if len(cmd.Changes) == 0 || len(cmd.Changes) > MaxBatchOperations {
+		return nil, ErrSyncSize
+	}
+
+	results := make([]*SyncResult, len(cmd.Changes))
+	err := s.withTx(func(tx *pgx.Tx) error {
+		for i, change := range cmd.Changes {
+			_, err := tx.Exec(`savepoint sync_change`)
+			if err != nil {
+				return err
+			}
+
+			result, err := s.syncChange(tx, cmd.UserID, cmd.Strategy, change)
+			if err != nil {
+				results[i] = &SyncResult{ClientID: change.ClientID, TodoID: change.TodoID, Err: err}
+				_, err = tx.Exec(`rollback to savepoint sync_change`)
+			} else {
+				results[i] = result
+				_, err = tx.Exec(`release savepoint sync_change`)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s ServiceImpl) syncChange(tx *pgx.Tx, userID int, strategy string, change SyncChange) (*SyncResult, error) {
+
+	result := &SyncResult{ClientID: change.ClientID, TodoID: change.TodoID}
+
+	if change.TodoID == 0 {
+		if change.Deleted {
+			// Created and deleted offline: there is nothing to sync.
+			return result, nil
+		}
+		cmd, err := createFromFields(userID, change.Fields)
+		if err != nil {
+			return nil, err
+		}
+		result.Todo, err = s.create(tx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		result.TodoID = result.Todo.TodoID
+		return result, nil
+	}
+
+	query := `select ` + todoColumns + ` from todos where todo_id = $1 for update`
+	current, err := scanTodo(tx.QueryRow(query, change.TodoID))
+	if err == nil {
+		err = authorize(tx, userID, current, policy.PermissionEdit)
+	}
+	if errors.Is(err, ErrTodoNotFound) {
+		// Deleted for good on the server, which always wins.
+		if !change.Deleted {
+			result.Conflict = &SyncConflict{Fields: []string{}, Resolution: ResolvedServer}
+		}
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Todo = current
+
+	if current.DeletedAt != nil {
+		if !change.Deleted {
+			result.Conflict = &SyncConflict{Fields: []string{}, Resolution: ResolvedServer}
+		}
+		return result, nil
+	}
+
+	clientLast := change.ChangedAt.After(current.UpdatedAt)
+	conflicting := current.Version != change.BaseVersion
+
+	if change.Deleted {
+		if conflicting {
+			result.Conflict = &SyncConflict{Fields: []string{}, Resolution: ResolvedServer}
+			if !clientLast {
+				return result, nil
+			}
+			result.Conflict.Resolution = ResolvedClient
+		}
+		result.Todo, err = s.remove(tx, &DeleteTodoCommand{UserID: userID, TodoID: current.TodoID, IfMatch: Versions{current.Version}})
+		return result, err
+	}
+
+	fields := change.Fields
+	if conflicting {
+		fields, result.Conflict, err = resolveFields(current, change, strategy == SyncFieldMerge, clientLast)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(fields) == 0 {
+		return result, nil
+	}
+
+	cmd := &UpdateTodoCommand{UserID: userID, TodoID: current.TodoID, IfMatch: Versions{current.Version}}
+	err = applyFields(cmd, fields)
+	if err != nil {
+		return nil, err
+	}
+	result.Todo, err = s.update(tx, cmd)
+	return result, err
+}
+
+// resolveFields returns the fields of a change to write over a todo changed on the server since
+// the base version of the change, and the conflict, if any. Without merge, the whole change is a
+// conflict unless it only sets the values the todo already has.
+func resolveFields(current *Todo, change SyncChange, merge bool, clientLast bool) (map[string]json.RawMessage, *SyncConflict, error) {
+
+	document, err := todoDocument(current)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed := map[string]json.RawMessage{}
+	conflicts := make([]string, 0)
+	for name, raw := range change.Fields {
+		var value interface{}
+		err := json.Unmarshal(raw, &value)
+		if err != nil {
+			return nil, nil, invalidPatch("/%s is not valid JSON", name)
+		}
+		server := document[name]
+		if reflect.DeepEqual(server, value) {
+			continue
+		}
+		changed[name] = raw
+
+		if merge {
+			if base, ok := change.Base[name]; ok {
+				var baseValue interface{}
+				if json.Unmarshal(base, &baseValue) == nil && reflect.DeepEqual(server, baseValue) {
+					// Only changed by the client.
+					continue
+				}
+			}
+		}
+		conflicts = append(conflicts, name)
+	}
+
+	if len(conflicts) == 0 {
+		return changed, nil, nil
+	}
+	sort.Strings(conflicts)
+
+	conflict := &SyncConflict{Fields: conflicts, Resolution: ResolvedServer}
+	if clientLast {
+		conflict.Resolution = ResolvedClient
+		return changed, conflict, nil
+	}
+	if !merge {
+		return nil, conflict, nil
+	}
+	for _, name := range conflicts {
+		delete(changed, name)
+	}
+	return changed, conflict, nil
+}
+
+// createFromFields returns the command creating a todo with the fields of a merge patch.
+func createFromFields(userID int, fields map[string]json.RawMessage) (*CreateTodoCommand, error) {
+
+	var patch UpdateTodoCommand
+	err := applyFields(&patch, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &CreateTodoCommand{
+		UserID:     userID,
+		ListID:     patch.ListID.Value,
+		AssigneeID: patch.AssigneeID.Value,
+		DueAt:      patch.DueAt.Value,
+		Tags:       patch.Tags,
+	}
+	if patch.Title != nil {
+		cmd.Title = *patch.Title
+	}
+	if patch.Content != nil {
+		cmd.Content = *patch.Content
+	}
+	if patch.Priority != nil {
+		cmd.Priority = *patch.Priority
+	}
+	if patch.Recurrence.Value != nil {
+		cmd.Recurrence = *patch.Recurrence.Value
+	}
+	if patch.RecurrenceTZ != nil {
+		cmd.RecurrenceTZ = *patch.RecurrenceTZ
+	}
+	return cmd, nil
+}
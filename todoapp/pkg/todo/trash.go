@@ -0,0 +1,144 @@
+package todo
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"log"
+	"time"
+)
+
+// DefaultTrashRetention is how long deleted todos stay in the trash when no retention is configured.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+type GetTrashCommand struct {
+	UserID int
+}
+
+type RestoreTodoCommand struct {
+	UserID int
+	TodoID int
+}
+
+// PurgeTodoCommand deletes a todo of the trash for good.
+type PurgeTodoCommand struct {
+	UserID int
+	TodoID int
+}
+
+// GetTrash lists the deleted todos of a user, most recently deleted first.
+func (s ServiceImpl) GetTrash(ctx context.Context, cmd *GetTrashCommand) ([]*Todo, error) {
+
+	query := `select ` + todoColumns + ` from todos where user_id = $1 and deleted_at is not null order by deleted_at desc, todo_id desc`
+
+	rows, err := s.conn.Query(query, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := make([]*Todo, 0)
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	return todos, rows.Err()
+}
+
+func (s ServiceImpl) Restore(ctx context.Context, cmd *RestoreTodoCommand) (*Todo, error) {
+
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) error {
+		query := `update todos set version = version + 1, deleted_at = null, updated_at = $1 where todo_id = $2 and user_id = $3 and deleted_at is not null returning ` + todoColumns
+		var err error
+		todo, err = scanTodo(tx.QueryRow(query, time.Now(), cmd.TodoID, cmd.UserID))
+		if err != nil {
+			return err
+		}
+		err = recordRevision(tx, cmd.UserID, ActionRestore, nil, todo)
+		if err != nil {
+			return err
+		}
+		return s.changed(tx, events.TodoCreated, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+// Purge deletes a todo for good. Only todos in the trash can be purged.
+// Purge leaves tombstones of the todo for its owner and the users it was shared with, so that
+// clients syncing later learn it is gone.
+func (s ServiceImpl) Purge(ctx context.Context, cmd *PurgeTodoCommand) (*Todo, error) {
+
+	var todo *Todo
+	err := s.withTx(func(tx *pgx.Tx) error {
+		query := `select ` + todoColumns + ` from todos where todo_id = $1 and user_id = $2 and deleted_at is not null for update`
+		var err error
+		todo, err = scanTodo(tx.QueryRow(query, cmd.TodoID, cmd.UserID))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(purgeQuery(`todo_id = $1`), todo.TodoID, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+// PurgeTrash deletes for good the todos of every user that have been in the trash for longer
+// than retention, leaving tombstones, and returns how many were deleted.
+func (s ServiceImpl) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+
+	var purged int64
+	err := s.conn.QueryRow(purgeQuery(`deleted_at < $1`), time.Now().Add(-retention), time.Now()).Scan(&purged)
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// purgeQuery deletes the todos matching where, which takes $1, and leaves tombstones at $2 for
+// their owners and the users they were shared with. The shares are read before the deletion
+// cascades to them, since the statement sees the rows as they were when it started. It returns
+// how many todos were deleted.
+func purgeQuery(where string) string {
+	return `with purged as (delete from todos where ` + where + ` returning todo_id, user_id, list_id),
+		tombstones as (
+			insert into todo_tombstones(todo_id, user_id, deleted_at)
+			select todo_id, user_id, $2::timestamp from purged
+			union select p.todo_id, s.user_id, $2::timestamp from purged p join todo_shares s on s.todo_id = p.todo_id
+			union select p.todo_id, s.user_id, $2::timestamp from purged p join list_shares s on s.list_id = p.list_id
+			on conflict (todo_id, user_id) do update set deleted_at = excluded.deleted_at)
+		select count(*) from purged`
+}
+
+// RunTrashPurger purges the trash every interval until ctx is done.
+func RunTrashPurger(ctx context.Context, s *ServiceImpl, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeTrash(ctx, retention)
+		if err != nil {
+			log.Println("error purging trash: ", err.Error())
+		} else if purged > 0 {
+			log.Printf("purged %v todos from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
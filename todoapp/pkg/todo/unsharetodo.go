@@ -0,0 +1,73 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+	"strconv"
+)
+
+type UnshareTodoHttpHandler struct {
+	Service ShareService
+}
+
+func NewUnshareTodoHttpHandler(s ShareService) *UnshareTodoHttpHandler {
+	return &UnshareTodoHttpHandler{Service: s}
+}
+
+// ServeHTTP revokes the share of a todo with the user of the {userID} path parameter, either
+// by the owner of the todo or by that user.
+func (h UnshareTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	shareUserID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("user id must be a number"))
+		return
+	}
+
+	cmd := UnshareTodoCommand{
+		UserID:      userID,
+		TodoID:      todoID,
+		ShareUserID: shareUserID,
+	}
+
+	share, err := h.Service.Unshare(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) || errors.Is(err, policy.ErrShareNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(policy.NewResponseShareDTO(share))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -2,10 +2,14 @@ package todo
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
-	"kuberneteslab/todoapp/pkg/middlewares"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/policy"
+	"kuberneteslab/todoapp/pkg/tag"
+	"mime"
 	"net/http"
-	"strconv"
+	"time"
 )
 
 type UpdateTodoHttpHandler struct {
@@ -16,68 +20,176 @@ func NewUpdateTodoHttpHandler(s Service) *UpdateTodoHttpHandler {
 	return &UpdateTodoHttpHandler{Service: s}
 }
 
+// UpdateTodoRequestDTO identifies the todo for the deprecated /todo route, which reads the ids
+// from the body next to the fields of the merge patch.
 type UpdateTodoRequestDTO struct {
-	UserID  int    `json:"user_id"`
-	TodoID  int    `json:"todo_id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	UserID int `json:"user_id,omitempty"`
+	TodoID int `json:"todo_id,omitempty"`
 }
 
 type UpdateTodoResponseDTO struct {
-	TodoID  int    `json:"todo_id"`
-	UserID  int    `json:"user_id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	TodoID       int        `json:"todo_id"`
+	UserID       int        `json:"user_id"`
+	ListID       *int       `json:"list_id,omitempty"`
+	AssigneeID   *int       `json:"assignee_id,omitempty"`
+	Title        string     `json:"title"`
+	Content      string     `json:"content"`
+	Completed    bool       `json:"completed"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Priority     int        `json:"priority"`
+	Tags         []string   `json:"tags"`
+	Version      int        `json:"version"`
+	Recurrence   *string    `json:"recurrence,omitempty"`
+	RecurrenceTZ *string    `json:"recurrence_tz,omitempty"`
 }
 
+// ServeHTTP changes only the fields the request carries, sent either as a merge patch
+// (application/merge-patch+json, or application/json) or as a JSON Patch (application/json-patch+json).
 func (h UpdateTodoHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	dto := UpdateTodoRequestDTO{}
-	bytes, err := io.ReadAll(r.Body)
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		contentType = contentTypeJSON
+	}
+	if contentType != contentTypeJSON && contentType != contentTypeMergePatch && contentType != contentTypeJSONPatch {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		w.Write([]byte(errUnsupportedPatch.Error()))
 		return
 	}
-	err = json.Unmarshal(bytes, &dto)
+
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	defer r.Body.Close()
 
-	tokenUserID := r.Header.Get(middlewares.HeaderKeyUserID)
-	userID := strconv.Itoa(dto.UserID)
+	var ids UpdateTodoRequestDTO
+	fields := map[string]json.RawMessage{}
+	if contentType != contentTypeJSONPatch && len(body) > 0 {
+		err = json.Unmarshal(body, &fields)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(invalidPatch("a merge patch must be an object").Error()))
+			return
+		}
+		// The deprecated /todo route identifies the todo in the body.
+		if contentType == contentTypeJSON {
+			json.Unmarshal(body, &ids)
+			delete(fields, "user_id")
+			delete(fields, "todo_id")
+		}
+	}
 
-	if tokenUserID != userID {
+	userID, err := requestUserID(r, ids.UserID)
+	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte("can't update todos for another user"))
 		return
 	}
 
+	todoID, err := requestTodoID(r, ids.TodoID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	ifMatch, err := requestIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
 	cmd := UpdateTodoCommand{
-		UserID:  dto.UserID,
-		TodoID:  dto.TodoID,
-		title:   dto.Title,
-		content: dto.Content,
+		UserID:  userID,
+		TodoID:  todoID,
+		IfMatch: ifMatch,
+	}
+
+	if contentType == contentTypeJSONPatch {
+		current, err := h.Service.Get(r.Context(), &GetTodoCommand{UserID: userID, TodoID: todoID})
+		if errors.Is(err, ErrTodoNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// The patch applies to the todo as read now, so it must not have changed when it is written.
+		if cmd.IfMatch == nil {
+			cmd.IfMatch = Versions{current.Version}
+		}
+
+		fields, err = jsonPatchFields(current, body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	err = applyFields(&cmd, fields)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	todo, err := h.Service.Update(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidPriority) || errors.Is(err, tag.ErrInvalidName) || errors.Is(err, ErrInvalidAssignee) ||
+		errors.Is(err, list.ErrListNotFound) || errors.Is(err, list.ErrListArchived) || isRecurrenceError(err) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	responseDTO := UpdateTodoResponseDTO{
-		TodoID:  todo.TodoID,
-		UserID:  todo.UserID,
-		Title:   todo.Title,
-		Content: todo.Content,
+		TodoID:       todo.TodoID,
+		UserID:       todo.UserID,
+		ListID:       todo.ListID,
+		AssigneeID:   todo.AssigneeID,
+		Title:        todo.Title,
+		Content:      todo.Content,
+		Completed:    todo.Completed,
+		CompletedAt:  todo.CompletedAt,
+		DueAt:        todo.DueAt,
+		Priority:     todo.Priority,
+		Tags:         todo.Tags,
+		Version:      todo.Version,
+		Recurrence:   todo.RecurrenceRule,
+		RecurrenceTZ: todo.RecurrenceTZ,
 	}
 
-	bytes, err = json.Marshal(responseDTO)
+	bytes, err := json.Marshal(responseDTO)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
+	w.Header().Set("ETag", todoETag(todo))
 	w.WriteHeader(http.StatusOK)
 	w.Write(bytes)
 }
@@ -0,0 +1,83 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type UpdateCommentHttpHandler struct {
+	Service CommentService
+}
+
+func NewUpdateCommentHttpHandler(s CommentService) *UpdateCommentHttpHandler {
+	return &UpdateCommentHttpHandler{Service: s}
+}
+
+func (h UpdateCommentHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := CommentRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	commentID, err := requestCommentID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := UpdateCommentCommand{
+		UserID:    userID,
+		TodoID:    todoID,
+		CommentID: commentID,
+		Body:      dto.Body,
+	}
+
+	comment, err := h.Service.UpdateComment(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) || errors.Is(err, ErrCommentNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidComment) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseCommentDTO(comment))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
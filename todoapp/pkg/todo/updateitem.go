@@ -0,0 +1,89 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"kuberneteslab/todoapp/pkg/policy"
+	"net/http"
+)
+
+type UpdateItemHttpHandler struct {
+	Service ChecklistService
+}
+
+func NewUpdateItemHttpHandler(s ChecklistService) *UpdateItemHttpHandler {
+	return &UpdateItemHttpHandler{Service: s}
+}
+
+type UpdateItemRequestDTO struct {
+	Title *string `json:"title,omitempty"`
+	Done  *bool   `json:"done,omitempty"`
+}
+
+func (h UpdateItemHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := UpdateItemRequestDTO{}
+	err := decodeBody(r, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := requestUserID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	todoID, err := requestTodoID(r, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	itemID, err := requestItemID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	cmd := UpdateItemCommand{
+		UserID: userID,
+		TodoID: todoID,
+		ItemID: itemID,
+		Title:  dto.Title,
+		Done:   dto.Done,
+	}
+
+	item, err := h.Service.UpdateItem(r.Context(), &cmd)
+	if errors.Is(err, ErrTodoNotFound) || errors.Is(err, ErrItemNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrEmptyItemTitle) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseItemDTO(item))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
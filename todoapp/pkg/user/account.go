@@ -0,0 +1,157 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"strings"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrEmailTaken      = errors.New("email is already used by another user")
+	ErrUsernameTaken   = errors.New("username is already used by another user")
+	ErrInvalidUser     = errors.New("username and email can't be empty")
+	ErrWrongPassword   = errors.New("current password is wrong")
+	ErrInvalidPassword = errors.New("password can't be empty")
+)
+
+type GetUserCommand struct {
+	UserID int
+}
+
+// UpdateUserCommand changes the fields that are set. Changing the email requires the current
+// password, and revokes every session but SessionID, the one the change was made from.
+type UpdateUserCommand struct {
+	UserID          int
+	SessionID       int
+	UserName        *string
+	Email           *string
+	CurrentPassword string
+}
+
+// ChangePasswordCommand changes the password of a user, and revokes every session but SessionID,
+// the one the change was made from.
+type ChangePasswordCommand struct {
+	UserID          int
+	SessionID       int
+	CurrentPassword string
+	NewPassword     string
+}
+
+const userColumns = `user_id, email, username, hashed_password, created_at, updated_at`
+
+func scanUser(row *pgx.Row) (*User, error) {
+	var user User
+	err := row.Scan(&user.UserID, &user.Email, &user.Username, &user.HashedPassword, &user.CreatedAt, &user.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// conflict maps a violation of the unique constraints of users to the field it is about.
+func conflict(err error) error {
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23505" {
+		switch pgErr.ConstraintName {
+		case "users_email_key":
+			return ErrEmailTaken
+		case "users_username_key":
+			return ErrUsernameTaken
+		}
+	}
+	return err
+}
+
+func (s ServiceImpl) Get(ctx context.Context, cmd *GetUserCommand) (*User, error) {
+	return scanUser(s.conn.QueryRow(`select `+userColumns+` from users where user_id = $1`, cmd.UserID))
+}
+
+func (s ServiceImpl) Update(ctx context.Context, cmd *UpdateUserCommand) (*User, error) {
+
+	for _, field := range []*string{cmd.UserName, cmd.Email} {
+		if field != nil && strings.TrimSpace(*field) == "" {
+			return nil, ErrInvalidUser
+		}
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanUser(tx.QueryRow(`select `+userColumns+` from users where user_id = $1 for update`, cmd.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	// The email is what passwords are reset with, so whoever holds a stolen token mustn't be able
+	// to take the account over by changing it.
+	emailChanged := cmd.Email != nil && *cmd.Email != current.Email
+	if emailChanged {
+		err = s.auth.CheckPassword(cmd.CurrentPassword, current.HashedPassword)
+		if err != nil {
+			return nil, ErrWrongPassword
+		}
+	}
+
+	query := `update users set username = coalesce($1, username), email = coalesce($2, email), updated_at = now()
+		where user_id = $3 returning ` + userColumns
+	user, err := scanUser(tx.QueryRow(query, cmd.UserName, cmd.Email, cmd.UserID))
+	if err != nil {
+		return nil, conflict(err)
+	}
+
+	if emailChanged {
+		_, err = tx.Exec(`update sessions set revoked_at = now() where user_id = $1 and session_id <> $2 and revoked_at is null`, cmd.UserID, cmd.SessionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return user, tx.Commit()
+}
+
+func (s ServiceImpl) ChangePassword(ctx context.Context, cmd *ChangePasswordCommand) error {
+
+	if cmd.NewPassword == "" {
+		return ErrInvalidPassword
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRow(`select `+userColumns+` from users where user_id = $1 for update`, cmd.UserID))
+	if err != nil {
+		return err
+	}
+
+	err = s.auth.CheckPassword(cmd.CurrentPassword, user.HashedPassword)
+	if err != nil {
+		return ErrWrongPassword
+	}
+
+	hashedPassword, err := s.auth.HashPassword(cmd.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update users set hashed_password = $1, updated_at = now() where user_id = $2`, hashedPassword, cmd.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update sessions set revoked_at = now() where user_id = $1 and session_id <> $2 and revoked_at is null`, cmd.UserID, cmd.SessionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -1,35 +1,79 @@
 package user
 
 import (
+	"crypto/ed25519"
 	"errors"
 	"github.com/o1egl/paseto"
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/crypto/chacha20"
 	"time"
 )
 
+const (
+	// ModeLocal encrypts v2.local tokens, which only holders of the keys can read.
+	ModeLocal = "local"
+	// ModePublic signs v2.public tokens with Ed25519, using the keys as seeds, so that other
+	// services can verify them with the keys PublicKeys returns.
+	ModePublic = "public"
+)
+
+var (
+	ErrInvalidMode  = errors.New("auth mode must be local or public")
+	ErrNoPublicKeys = errors.New("tokens are not signed with public keys")
+)
+
 type AuthService struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto *paseto.V2
+	keys   *Keyring
+	mode   string
+}
+
+// tokenFooter is the unencrypted footer of a token, naming the key it was made with.
+type tokenFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// PublicKey is a key that verifies v2.public tokens. RetiresAt is set once it has been replaced.
+type PublicKey struct {
+	ID         string
+	Key        ed25519.PublicKey
+	ActiveFrom time.Time
+	RetiresAt  *time.Time
 }
 
+// NewAuthService returns an AuthService in local mode with a single key, which can't be rotated.
 func NewAuthService(simmetriKey string) (*AuthService, error) {
 
-	if len(simmetriKey) != chacha20.KeySize {
-		return nil, errors.New("invalid key size")
+	keys, err := NewKeyring([]Key{{ID: DefaultKeyID, Secret: []byte(simmetriKey)}}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyringAuthService(keys, ModeLocal)
+}
+
+func NewKeyringAuthService(keys *Keyring, mode string) (*AuthService, error) {
+
+	if mode != ModeLocal && mode != ModePublic {
+		return nil, ErrInvalidMode
 	}
 
 	return &AuthService{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(simmetriKey),
+		paseto: paseto.NewV2(),
+		keys:   keys,
+		mode:   mode,
 	}, nil
-
 }
 
-func (pm *AuthService) CreateToken(userID int, username string, duration time.Duration) (string, error) {
-	payload := NewPayload(userID, username, duration)
+func (pm *AuthService) CreateToken(userID int, username string, sessionID int, duration time.Duration) (string, error) {
+	payload := NewPayload(userID, username, sessionID, duration)
+
+	key := pm.keys.signing(time.Now())
+	footer := tokenFooter{KeyID: key.ID}
+	if pm.mode == ModePublic {
+		return pm.paseto.Sign(ed25519.NewKeyFromSeed(key.Secret), payload, footer)
+	}
 
-	encrypted, err := pm.paseto.Encrypt(pm.symmetricKey, payload, nil)
+	encrypted, err := pm.paseto.Encrypt(key.Secret, payload, footer)
 	if err != nil {
 		return "", err
 	}
@@ -39,8 +83,31 @@ func (pm *AuthService) CreateToken(userID int, username string, duration time.Du
 
 func (pm *AuthService) VerifyToken(token string) (*Payload, error) {
 
+	_, purpose, err := paseto.GetTokenInfo(token)
+	if err != nil {
+		return nil, err
+	}
+	if (pm.mode == ModePublic) != (purpose == paseto.PUBLIC) {
+		return nil, errors.New("token is not a " + pm.mode + " token")
+	}
+
+	var footer tokenFooter
+	err = paseto.ParseFooter(token, &footer)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := pm.keys.verifying(footer.KeyID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	payload := Payload{}
-	err := pm.paseto.Decrypt(token, pm.symmetricKey, &payload, nil)
+	if pm.mode == ModePublic {
+		err = pm.paseto.Verify(token, ed25519.NewKeyFromSeed(key.Secret).Public(), &payload, nil)
+	} else {
+		err = pm.paseto.Decrypt(token, key.Secret, &payload, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -52,17 +119,41 @@ func (pm *AuthService) VerifyToken(token string) (*Payload, error) {
 	return &payload, nil
 }
 
+// PublicKeys returns the keys that verify the tokens signed now or later, including keys that
+// are not active yet, so that other services know them before the first token they sign.
+func (pm *AuthService) PublicKeys() ([]PublicKey, error) {
+
+	if pm.mode != ModePublic {
+		return nil, ErrNoPublicKeys
+	}
+
+	keys := make([]PublicKey, 0)
+	for _, key := range pm.keys.unretired(time.Now()) {
+		keys = append(keys, PublicKey{
+			ID:         key.ID,
+			Key:        ed25519.NewKeyFromSeed(key.Secret).Public().(ed25519.PublicKey),
+			ActiveFrom: key.ActiveFrom,
+			RetiresAt:  pm.keys.retiresAt(key.ID),
+		})
+	}
+	return keys, nil
+}
+
+// Payload is the content of an access token. SessionID is the login it was issued for, which
+// can be revoked before the token expires.
 type Payload struct {
 	UserID    int       `json:"user_id"`
 	Username  string    `json:"user_name"`
+	SessionID int       `json:"session_id"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-func NewPayload(userID int, username string, duration time.Duration) *Payload {
+func NewPayload(userID int, username string, sessionID int, duration time.Duration) *Payload {
 	return &Payload{
 		Username:  username,
 		UserID:    userID,
+		SessionID: sessionID,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
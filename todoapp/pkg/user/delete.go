@@ -0,0 +1,49 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type DeleteUserHttpHandler struct {
+	Service Service
+}
+
+func NewDeleteUserHttpHandler(s Service) *DeleteUserHttpHandler {
+	return &DeleteUserHttpHandler{Service: s}
+}
+
+type DeleteUserResponseDTO struct {
+	UserID   int    `json:"user_id"`
+	UserName string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// ServeHTTP deletes the account of the user along with everything it owns, and with it its sessions.
+func (h DeleteUserHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	payload, ok := PayloadFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.Service.Delete(r.Context(), &DeleteUserCommand{UserID: payload.UserID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(DeleteUserResponseDTO{
+		UserID:   user.UserID,
+		UserName: user.Username,
+		Email:    user.Email,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,61 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+type ForgotPasswordHttpHandler struct {
+	Service Service
+}
+
+func NewForgotPasswordHttpHandler(s Service) *ForgotPasswordHttpHandler {
+	return &ForgotPasswordHttpHandler{Service: s}
+}
+
+type ForgotPasswordRequestDTO struct {
+	Email string `json:"email"`
+}
+
+// ServeHTTP answers right away and the same whether the email has an account or not, while the
+// email is sent in the background. Requests beyond the limits of the email or of the client IP
+// are refused with 429.
+func (h ForgotPasswordHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var request ForgotPasswordRequestDTO
+	err = json.Unmarshal(bytes, &request)
+	if err != nil || request.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	err = h.Service.ForgotPassword(r.Context(), &ForgotPasswordCommand{Email: request.Email, IP: ip})
+	if errors.Is(err, ErrTooManyResets) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(ResetLimitWindow.Seconds())))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type GetUserHttpHandler struct {
+	Service Service
+}
+
+func NewGetUserHttpHandler(s Service) *GetUserHttpHandler {
+	return &GetUserHttpHandler{Service: s}
+}
+
+type ResponseUserDTO struct {
+	UserID    int       `json:"user_id"`
+	UserName  string    `json:"username"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newResponseUserDTO(user *User) ResponseUserDTO {
+	return ResponseUserDTO{
+		UserID:    user.UserID,
+		UserName:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func (h GetUserHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	payload, ok := PayloadFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.Service.Get(r.Context(), &GetUserCommand{UserID: payload.UserID})
+	if errors.Is(err, ErrUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseUserDTO(user))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/chacha20"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultKeyID is the id of the key configured alone through auth_key.
+	DefaultKeyID = "default"
+	// DefaultKeyGrace is how long a replaced key still verifies tokens when no grace is configured.
+	// It must outlast the access tokens signed right before the rotation.
+	DefaultKeyGrace = time.Hour
+)
+
+var ErrUnknownKey = errors.New("token was signed with an unknown or retired key")
+
+// Key is a key of a Keyring. It signs tokens from ActiveFrom until the next key becomes active.
+type Key struct {
+	ID         string
+	Secret     []byte
+	ActiveFrom time.Time
+}
+
+// Keyring holds the keys tokens are signed with. The key that became active last signs new
+// tokens, while the keys it replaced keep verifying tokens for a grace window, so that rotating
+// keys doesn't log every user out. A key can be added ahead of time with a later ActiveFrom, so
+// that every server switches to it at once.
+type Keyring struct {
+	keys  []Key
+	grace time.Duration
+}
+
+func NewKeyring(keys []Key, grace time.Duration) (*Keyring, error) {
+
+	if len(keys) == 0 {
+		return nil, errors.New("no key configured")
+	}
+
+	ids := map[string]bool{}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("keys must have an id")
+		}
+		if ids[key.ID] {
+			return nil, fmt.Errorf("key %v is configured twice", key.ID)
+		}
+		ids[key.ID] = true
+		if len(key.Secret) != chacha20.KeySize {
+			return nil, errors.New("invalid key size")
+		}
+	}
+
+	sorted := make([]Key, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom)
+	})
+	if sorted[0].ActiveFrom.After(time.Now()) {
+		return nil, errors.New("no key is active yet")
+	}
+
+	return &Keyring{keys: sorted, grace: grace}, nil
+}
+
+// signing returns the key that signs the tokens issued at now.
+func (k *Keyring) signing(now time.Time) Key {
+	active := k.keys[0]
+	for _, key := range k.keys[1:] {
+		if key.ActiveFrom.After(now) {
+			break
+		}
+		active = key
+	}
+	return active
+}
+
+// verifying returns the key with the given id, unless it was replaced longer than the grace window ago.
+func (k *Keyring) verifying(id string, now time.Time) (Key, error) {
+	for _, key := range k.unretired(now) {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return Key{}, ErrUnknownKey
+}
+
+// unretired returns the keys that still verify tokens at now.
+func (k *Keyring) unretired(now time.Time) []Key {
+	keys := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		retiresAt := k.retiresAt(key.ID)
+		if retiresAt == nil || !now.After(*retiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// retiresAt returns when a key stops verifying tokens, which is the grace window after the next
+// key becomes active, or nil for the last key.
+func (k *Keyring) retiresAt(id string) *time.Time {
+	for i, key := range k.keys[:len(k.keys)-1] {
+		if key.ID == id {
+			retiresAt := k.keys[i+1].ActiveFrom.Add(k.grace)
+			return &retiresAt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package user
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(id string, activeFrom time.Time) Key {
+	return Key{ID: id, Secret: []byte(strings.Repeat(id, 32)[:32]), ActiveFrom: activeFrom}
+}
+
+func mustAuthService(t *testing.T, keys *Keyring, mode string) *AuthService {
+	auth, err := NewKeyringAuthService(keys, mode)
+	require.NoError(t, err)
+	return auth
+}
+
+func TestKeyring(t *testing.T) {
+
+	now := time.Now()
+
+	t.Run("new keyring with invalid keys should fail", func(t *testing.T) {
+		for _, keys := range [][]Key{
+			nil,
+			{{ID: "a", Secret: []byte("short")}},
+			{testKey("a", time.Time{}), testKey("a", now)},
+			{{ID: "", Secret: []byte(strings.Repeat("a", 32))}},
+			{testKey("a", now.Add(time.Hour))},
+		} {
+			_, err := NewKeyring(keys, time.Hour)
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("sign should use the key that became active last", func(t *testing.T) {
+		keys, err := NewKeyring([]Key{
+			testKey("c", now.Add(time.Hour)),
+			testKey("a", time.Time{}),
+			testKey("b", now.Add(-time.Minute)),
+		}, time.Hour)
+		require.NoError(t, err)
+
+		require.Equal(t, "b", keys.signing(now).ID)
+		require.Equal(t, "c", keys.signing(now.Add(2*time.Hour)).ID)
+	})
+
+	t.Run("verify should accept a replaced key only during the grace window", func(t *testing.T) {
+		keys, err := NewKeyring([]Key{
+			testKey("a", time.Time{}),
+			testKey("b", now.Add(-time.Minute)),
+		}, time.Hour)
+		require.NoError(t, err)
+
+		_, err = keys.verifying("a", now)
+		require.NoError(t, err)
+		_, err = keys.verifying("a", now.Add(2*time.Hour))
+		require.ErrorIs(t, err, ErrUnknownKey)
+		_, err = keys.verifying("b", now.Add(2*time.Hour))
+		require.NoError(t, err)
+		_, err = keys.verifying("x", now)
+		require.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("tokens signed before a rotation should still verify after it", func(t *testing.T) {
+		before, err := NewKeyring([]Key{testKey("a", time.Time{})}, time.Hour)
+		require.NoError(t, err)
+		token, err := mustAuthService(t, before, ModeLocal).CreateToken(1, "username", 2, time.Minute)
+		require.NoError(t, err)
+
+		after, err := NewKeyring([]Key{testKey("a", time.Time{}), testKey("b", now)}, time.Hour)
+		require.NoError(t, err)
+		payload, err := mustAuthService(t, after, ModeLocal).VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, 2, payload.SessionID)
+
+		retired, err := NewKeyring([]Key{testKey("b", time.Time{})}, time.Hour)
+		require.NoError(t, err)
+		_, err = mustAuthService(t, retired, ModeLocal).VerifyToken(token)
+		require.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("public tokens should verify with the published keys only", func(t *testing.T) {
+		keys, err := NewKeyring([]Key{
+			testKey("a", time.Time{}),
+			testKey("b", now.Add(-2*time.Hour)),
+			testKey("c", now.Add(time.Hour)),
+		}, time.Hour)
+		require.NoError(t, err)
+		auth := mustAuthService(t, keys, ModePublic)
+
+		token, err := auth.CreateToken(1, "username", 2, time.Minute)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(token, "v2.public."))
+
+		payload, err := auth.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, 1, payload.UserID)
+
+		publicKeys, err := auth.PublicKeys()
+		require.NoError(t, err)
+		require.Equal(t, 2, len(publicKeys))
+		require.Equal(t, "b", publicKeys[0].ID)
+		require.NotNil(t, publicKeys[0].RetiresAt)
+		require.Equal(t, "c", publicKeys[1].ID)
+		require.Nil(t, publicKeys[1].RetiresAt)
+
+		var verified Payload
+		err = auth.paseto.Verify(token, publicKeys[0].Key, &verified, nil)
+		require.NoError(t, err)
+
+		_, err = mustAuthService(t, keys, ModeLocal).VerifyToken(token)
+		require.Error(t, err)
+		_, err = mustAuthService(t, keys, ModeLocal).PublicKeys()
+		require.ErrorIs(t, err, ErrNoPublicKeys)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"time"
 )
 
 type LoginUserHttpHandler struct {
@@ -19,9 +20,22 @@ type LoginUserRequestDTO struct {
 	UserPassword string `json:"password"`
 }
 
+// LoginUserResponseDTO carries a short-lived access token, sent as the bearer token of requests,
+// and the refresh token that trades for the next one when it expires.
 type LoginUserResponseDTO struct {
-	Token  string `json:"token"`
-	UserID int    `json:"user_id"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	UserID       int       `json:"user_id"`
+}
+
+func newLoginUserResponseDTO(auth *Auth) LoginUserResponseDTO {
+	return LoginUserResponseDTO{
+		Token:        auth.token,
+		RefreshToken: auth.refreshToken,
+		ExpiresAt:    auth.expiresAt,
+		UserID:       auth.userID,
+	}
 }
 
 func (h LoginUserHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -51,8 +65,7 @@ func (h LoginUserHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	response := LoginUserResponseDTO{Token: auth.token, UserID: auth.userID}
-	bytes, err = json.Marshal(response)
+	bytes, err = json.Marshal(newLoginUserResponseDTO(auth))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -0,0 +1,54 @@
+package user
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+type LogoutUserHttpHandler struct {
+	Service Service
+}
+
+func NewLogoutUserHttpHandler(s Service) *LogoutUserHttpHandler {
+	return &LogoutUserHttpHandler{Service: s}
+}
+
+// LogoutUserRequestDTO is the optional body of a logout. All logs out every session of the user
+// instead of only the one of the token.
+type LogoutUserRequestDTO struct {
+	All bool `json:"all"`
+}
+
+func (h LogoutUserHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	payload, ok := PayloadFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var request LogoutUserRequestDTO
+	if len(bytes) > 0 {
+		err = json.Unmarshal(bytes, &request)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = h.Service.Logout(r.Context(), &LogoutUserCommand{UserID: payload.UserID, SessionID: payload.SessionID, All: request.All})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
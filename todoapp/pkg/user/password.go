@@ -0,0 +1,76 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type ChangePasswordHttpHandler struct {
+	Service Service
+}
+
+func NewChangePasswordHttpHandler(s Service) *ChangePasswordHttpHandler {
+	return &ChangePasswordHttpHandler{Service: s}
+}
+
+type ChangePasswordRequestDTO struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ServeHTTP changes the password of the user. Every other session is logged out, while the one
+// making the change stays logged in.
+func (h ChangePasswordHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	payload, ok := PayloadFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var request ChangePasswordRequestDTO
+	err = json.Unmarshal(bytes, &request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := ChangePasswordCommand{
+		UserID:          payload.UserID,
+		SessionID:       payload.SessionID,
+		CurrentPassword: request.CurrentPassword,
+		NewPassword:     request.NewPassword,
+	}
+
+	err = h.Service.ChangePassword(r.Context(), &cmd)
+	if errors.Is(err, ErrUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrWrongPassword) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidPassword) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,278 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/mail"
+	"log"
+	"time"
+)
+
+const (
+	// ResetTokenTTL is how long a password reset token can be used.
+	ResetTokenTTL = time.Hour
+	// ResetLimitWindow is the window ResetLimits count the password reset requests over.
+	ResetLimitWindow = time.Hour
+	// MaxResetAttempts is how many times the email of a password reset request is tried before
+	// giving up on it.
+	MaxResetAttempts = 5
+
+	resetMailTimeout = 30 * time.Second
+	resetLease       = 2 * resetMailTimeout
+	resetBackoff     = time.Minute
+)
+
+var (
+	ErrInvalidResetToken = errors.New("reset token is invalid, expired or already used")
+	ErrTooManyResets     = errors.New("too many password reset requests, try again later")
+)
+
+// ResetLimits bounds how many password reset requests are accepted within ResetLimitWindow for
+// an email, and from an IP.
+type ResetLimits struct {
+	PerEmail int
+	PerIP    int
+}
+
+// DefaultResetLimits are the ResetLimits used when none are configured.
+var DefaultResetLimits = ResetLimits{PerEmail: 3, PerIP: 20}
+
+type ForgotPasswordCommand struct {
+	Email string
+	IP    string
+}
+
+type ResetPasswordCommand struct {
+	Token       string
+	NewPassword string
+}
+
+// ForgotPassword queues a password reset request for email, which RunResetMailer sends a reset
+// token for, replacing the tokens sent before. The email isn't looked up until it is sent, so
+// that the answer doesn't tell whether it has an account. A request beyond the ResetLimits of its
+// email or IP fails with ErrTooManyResets.
+func (s ServiceImpl) ForgotPassword(ctx context.Context, cmd *ForgotPasswordCommand) error {
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The requests of an email, then of an IP, are serialized so that concurrent ones can't all
+	// pass the limits.
+	_, err = tx.Exec(`select pg_advisory_xact_lock(1, hashtext(lower(btrim($1)))), pg_advisory_xact_lock(2, hashtext($2))`, cmd.Email, cmd.IP)
+	if err != nil {
+		return err
+	}
+
+	var perEmail, perIP int
+	query := `select count(*) filter (where lower(btrim(email)) = lower(btrim($1))), count(*) filter (where ip = $2)
+		from password_reset_requests where (lower(btrim(email)) = lower(btrim($1)) or ip = $2) and created_at > now() - $3 * interval '1 second'`
+	err = tx.QueryRow(query, cmd.Email, cmd.IP, int64(ResetLimitWindow.Seconds())).Scan(&perEmail, &perIP)
+	if err != nil {
+		return err
+	}
+	if perEmail >= s.resetLimits.PerEmail || perIP >= s.resetLimits.PerIP {
+		return ErrTooManyResets
+	}
+
+	_, err = tx.Exec(`insert into password_reset_requests(email, ip) values ($1, $2)`, cmd.Email, cmd.IP)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SendResets sends up to limit password reset requests that are due, one after another, and
+// returns how many it tried. Each is claimed for a lease right before it is sent, so the mailers
+// of several pods don't send it twice, and retried with a backoff when sending fails.
+func (s ServiceImpl) SendResets(ctx context.Context, limit int) (int, error) {
+
+	tried := 0
+	for tried < limit {
+		requestID, email, attempts, err := s.claimReset()
+		if err != nil {
+			return tried, err
+		}
+		if requestID == 0 {
+			break
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, resetMailTimeout)
+		sendErr := s.sendResetToken(sendCtx, email)
+		cancel()
+
+		if sendErr == nil {
+			_, err = s.conn.Exec(`update password_reset_requests set attempts = $1, last_error = null, sent_at = now() where request_id = $2`, attempts+1, requestID)
+		} else {
+			log.Println("error sending password reset: ", sendErr.Error())
+			query := `update password_reset_requests set attempts = $1, last_error = $2, next_attempt_at = now() + $3 * interval '1 second' where request_id = $4`
+			_, err = s.conn.Exec(query, attempts+1, sendErr.Error(), int64((resetBackoff << attempts).Seconds()), requestID)
+		}
+		if err != nil {
+			return tried, err
+		}
+		tried++
+	}
+	return tried, nil
+}
+
+// claimReset leases the password reset request due first, and returns a zero id when none is due.
+func (s ServiceImpl) claimReset() (int, string, int, error) {
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer tx.Rollback()
+
+	var requestID, attempts int
+	var email string
+	query := `select request_id, email, attempts from password_reset_requests
+		where sent_at is null and attempts < $1 and next_attempt_at <= now()
+		order by next_attempt_at limit 1
+		for update skip locked`
+	err = tx.QueryRow(query, MaxResetAttempts).Scan(&requestID, &email, &attempts)
+	if err == pgx.ErrNoRows {
+		return 0, "", 0, nil
+	}
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	_, err = tx.Exec(`update password_reset_requests set next_attempt_at = now() + $1 * interval '1 second' where request_id = $2`, int64(resetLease.Seconds()), requestID)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	return requestID, email, attempts, tx.Commit()
+}
+
+// RunResetMailer sends the due password reset requests every interval until ctx is done.
+func RunResetMailer(ctx context.Context, s *ServiceImpl, interval time.Duration) {
+	const batch = 20
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			tried, err := s.SendResets(ctx, batch)
+			if err != nil {
+				log.Println("error sending password resets: ", err.Error())
+			}
+			if err != nil || tried < batch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendResetToken stores a new reset token of the user with email, if any, and emails it to them.
+func (s ServiceImpl) sendResetToken(ctx context.Context, email string) error {
+
+	user, err := scanUser(s.conn.QueryRow(`select `+userColumns+` from users where email = $1`, email))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`delete from password_resets where user_id = $1`, user.UserID)
+	if err != nil {
+		return err
+	}
+	query := `insert into password_resets(token_hash, user_id, expires_at) values ($1, $2, now() + $3 * interval '1 second')`
+	_, err = tx.Exec(query, hashToken(token), user.UserID, int64(ResetTokenTTL.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse this token to choose a new password within the next %v:\n\n%s\n\n"+
+			"If you didn't ask to reset your password, you can ignore this email.\n", user.Username, ResetTokenTTL, token),
+	})
+}
+
+// ResetPassword sets a new password with a reset token, which can't be used again, and logs out
+// every session of the user. The password reset requests of the user then stop counting against
+// their ResetLimits.
+func (s ServiceImpl) ResetPassword(ctx context.Context, cmd *ResetPasswordCommand) error {
+
+	if cmd.NewPassword == "" {
+		return ErrInvalidPassword
+	}
+
+	hashedPassword, err := s.auth.HashPassword(cmd.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	hash := hashToken(cmd.Token)
+	var userID int
+	var valid bool
+	query := `select user_id, used_at is null and expires_at > now() from password_resets where token_hash = $1 for update`
+	err = tx.QueryRow(query, hash).Scan(&userID, &valid)
+	if err == pgx.ErrNoRows || (err == nil && !valid) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update password_resets set used_at = now() where token_hash = $1`, hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update users set hashed_password = $1, updated_at = now() where user_id = $2`, hashedPassword, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update sessions set revoked_at = now() where user_id = $1 and revoked_at is null`, userID)
+	if err != nil {
+		return err
+	}
+
+	query = `delete from password_reset_requests where lower(btrim(email)) = (select lower(btrim(email)) from users where user_id = $1)`
+	_, err = tx.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
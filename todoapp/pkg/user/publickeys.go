@@ -0,0 +1,75 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type PublicKeysHttpHandler struct {
+	Auth *AuthService
+}
+
+func NewPublicKeysHttpHandler(a *AuthService) *PublicKeysHttpHandler {
+	return &PublicKeysHttpHandler{Auth: a}
+}
+
+type GetPublicKeysResponseDTO struct {
+	Keys []ResponsePublicKeyDTO `json:"keys"`
+}
+
+// ResponsePublicKeyDTO is a key that verifies v2.public tokens whose footer kid is its id. The key
+// is serialized as a PASERK (k2.public.<base64url key>).
+type ResponsePublicKeyDTO struct {
+	ID         string     `json:"kid"`
+	Version    string     `json:"version"`
+	Purpose    string     `json:"purpose"`
+	Key        string     `json:"paserk"`
+	ActiveFrom time.Time  `json:"active_from"`
+	RetiresAt  *time.Time `json:"retires_at,omitempty"`
+}
+
+func newResponsePublicKeyDTO(key PublicKey) ResponsePublicKeyDTO {
+	return ResponsePublicKeyDTO{
+		ID:         key.ID,
+		Version:    "v2",
+		Purpose:    "public",
+		Key:        "k2.public." + base64.RawURLEncoding.EncodeToString(key.Key),
+		ActiveFrom: key.ActiveFrom,
+		RetiresAt:  key.RetiresAt,
+	}
+}
+
+// ServeHTTP publishes the public keys for other services to verify tokens with. Verifiers should
+// read them again when they meet a kid they don't know, as keys are added before they sign.
+func (h PublicKeysHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	keys, err := h.Auth.PublicKeys()
+	if errors.Is(err, ErrNoPublicKeys) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := GetPublicKeysResponseDTO{Keys: make([]ResponsePublicKeyDTO, 0, len(keys))}
+	for _, key := range keys {
+		res.Keys = append(res.Keys, newResponsePublicKeyDTO(key))
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,58 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type RefreshTokenHttpHandler struct {
+	Service Service
+}
+
+func NewRefreshTokenHttpHandler(s Service) *RefreshTokenHttpHandler {
+	return &RefreshTokenHttpHandler{Service: s}
+}
+
+type RefreshTokenRequestDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ServeHTTP answers with new tokens as the login does. The refresh token sent can't be used again.
+func (h RefreshTokenHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var request RefreshTokenRequestDTO
+	err = json.Unmarshal(bytes, &request)
+	if err != nil || request.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	auth, err := h.Service.Refresh(r.Context(), &RefreshTokenCommand{RefreshToken: request.RefreshToken})
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(newLoginUserResponseDTO(auth))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
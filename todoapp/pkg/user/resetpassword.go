@@ -0,0 +1,51 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type ResetPasswordHttpHandler struct {
+	Service Service
+}
+
+func NewResetPasswordHttpHandler(s Service) *ResetPasswordHttpHandler {
+	return &ResetPasswordHttpHandler{Service: s}
+}
+
+type ResetPasswordRequestDTO struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (h ResetPasswordHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var request ResetPasswordRequestDTO
+	err = json.Unmarshal(bytes, &request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = h.Service.ResetPassword(r.Context(), &ResetPasswordCommand{Token: request.Token, NewPassword: request.NewPassword})
+	if errors.Is(err, ErrInvalidResetToken) || errors.Is(err, ErrInvalidPassword) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/mail"
 	"time"
 )
 
@@ -12,12 +13,21 @@ type User struct {
 	Email          string
 	Username       string
 	HashedPassword string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 type Service interface {
 	Create(ctx context.Context, cmd *CreateUserCommand) (*User, error)
 	Delete(ctx context.Context, cmd *DeleteUserCommand) (*User, error)
 	Login(ctx context.Context, cmd *LoginUserCommand) (*Auth, error)
+	Refresh(ctx context.Context, cmd *RefreshTokenCommand) (*Auth, error)
+	Logout(ctx context.Context, cmd *LogoutUserCommand) error
+	Get(ctx context.Context, cmd *GetUserCommand) (*User, error)
+	Update(ctx context.Context, cmd *UpdateUserCommand) (*User, error)
+	ChangePassword(ctx context.Context, cmd *ChangePasswordCommand) error
+	ForgotPassword(ctx context.Context, cmd *ForgotPasswordCommand) error
+	ResetPassword(ctx context.Context, cmd *ResetPasswordCommand) error
 }
 
 type CreateUserCommand struct {
@@ -36,14 +46,22 @@ type LoginUserCommand struct {
 }
 
 type ServiceImpl struct {
-	conn *pgx.ConnPool
-	auth *AuthService
+	conn        *pgx.ConnPool
+	auth        *AuthService
+	mailer      mail.Mailer
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	resetLimits ResetLimits
 }
 
-func NewServiceImpl(conn *pgx.ConnPool, authSvc *AuthService) *ServiceImpl {
+func NewServiceImpl(conn *pgx.ConnPool, authSvc *AuthService, mailer mail.Mailer, accessTTL time.Duration, refreshTTL time.Duration, resetLimits ResetLimits) *ServiceImpl {
 	return &ServiceImpl{
-		auth: authSvc,
-		conn: conn,
+		auth:        authSvc,
+		conn:        conn,
+		mailer:      mailer,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+		resetLimits: resetLimits,
 	}
 }
 
@@ -107,20 +125,13 @@ func (s ServiceImpl) Login(ctx context.Context, cmd *LoginUserCommand) (*Auth, e
 		return nil, err
 	}
 
-	token, err := s.auth.CreateToken(user.UserID, user.Username, time.Hour*48)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Auth{
-		token:     token,
-		userID:    user.UserID,
-		userEmail: user.Email,
-	}, nil
+	return s.startSession(&user)
 }
 
 type Auth struct {
-	token     string
-	userID    int
-	userEmail string
+	token        string
+	refreshToken string
+	expiresAt    time.Time
+	userID       int
+	userEmail    string
 }
@@ -0,0 +1,260 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/jackc/pgx"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultAccessTokenTTL is how long an access token is valid when no TTL is configured.
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is how long a refresh token is valid when no TTL is configured. Each
+	// refresh issues a new one, so a session lasts as long as it is used within this time.
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	tokenSize = 32
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, so its session was revoked")
+	ErrSessionRevoked      = errors.New("session was revoked")
+)
+
+type RefreshTokenCommand struct {
+	RefreshToken string
+}
+
+// LogoutUserCommand revokes a session of a user, or all of them when All is set.
+type LogoutUserCommand struct {
+	UserID    int
+	SessionID int
+	All       bool
+}
+
+type payloadKey struct{}
+
+// NewContext returns a copy of ctx carrying the payload of the token a request was authenticated with.
+func NewContext(ctx context.Context, payload *Payload) context.Context {
+	return context.WithValue(ctx, payloadKey{}, payload)
+}
+
+// PayloadFromContext returns the payload NewContext stored in ctx, if any.
+func PayloadFromContext(ctx context.Context) (*Payload, bool) {
+	payload, ok := ctx.Value(payloadKey{}).(*Payload)
+	return payload, ok
+}
+
+// hashToken is what refresh and reset tokens are stored by, so that they can't be read back from the database.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken returns a random token, as sent for refresh and password reset tokens.
+func newToken() (string, error) {
+	b := make([]byte, tokenSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// startSession creates a session for a user who just logged in, and issues its first tokens.
+func (s ServiceImpl) startSession(user *User) (*Auth, error) {
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var sessionID int
+	query := `insert into sessions(user_id, expires_at) values ($1, now() + $2 * interval '1 second') returning session_id`
+	err = tx.QueryRow(query, user.UserID, int64(s.refreshTTL.Seconds())).Scan(&sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := s.issueTokens(tx, user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return auth, tx.Commit()
+}
+
+// issueTokens stores a new refresh token of a session, which the session is extended to outlive,
+// and signs an access token for it.
+func (s ServiceImpl) issueTokens(tx *pgx.Tx, user *User, sessionID int) (*Auth, error) {
+
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := int64(s.refreshTTL.Seconds())
+	query := `insert into refresh_tokens(token_hash, session_id, expires_at) values ($1, $2, now() + $3 * interval '1 second')`
+	_, err = tx.Exec(query, hashToken(refreshToken), sessionID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`update sessions set expires_at = now() + $1 * interval '1 second' where session_id = $2`, ttl, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.auth.CreateToken(user.UserID, user.Username, sessionID, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{
+		token:        token,
+		refreshToken: refreshToken,
+		expiresAt:    time.Now().Add(s.accessTTL),
+		userID:       user.UserID,
+		userEmail:    user.Email,
+	}, nil
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh token of the same
+// session. Each refresh token can be used once: a token used again may have been stolen, and as
+// it can't be told whether the thief or its owner sent it, its whole session is revoked.
+func (s ServiceImpl) Refresh(ctx context.Context, cmd *RefreshTokenCommand) (*Auth, error) {
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	hash := hashToken(cmd.RefreshToken)
+	query := `select t.session_id, t.used_at is not null, t.expires_at < now(), s.revoked_at is not null, u.user_id, u.email, u.username
+		from refresh_tokens t join sessions s on s.session_id = t.session_id join users u on u.user_id = s.user_id
+		where t.token_hash = $1 for update of t, s`
+
+	var sessionID int
+	var used, expired, revoked bool
+	var user User
+	err = tx.QueryRow(query, hash).Scan(&sessionID, &used, &expired, &revoked, &user.UserID, &user.Email, &user.Username)
+	if err == pgx.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revoked || expired {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if used {
+		_, err = tx.Exec(`update sessions set revoked_at = now() where session_id = $1`, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(`update refresh_tokens set used_at = now() where token_hash = $1`, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := s.issueTokens(tx, &user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return auth, tx.Commit()
+}
+
+// Logout revokes a session, so that neither its access tokens nor its refresh tokens are
+// accepted anymore.
+func (s ServiceImpl) Logout(ctx context.Context, cmd *LogoutUserCommand) error {
+
+	sessionID := cmd.SessionID
+	if cmd.All {
+		sessionID = 0
+	}
+
+	query := `update sessions set revoked_at = now() where user_id = $1 and ($2 = 0 or session_id = $2) and revoked_at is null`
+	_, err := s.conn.Exec(query, cmd.UserID, sessionID)
+	return err
+}
+
+// VerifyToken verifies an access token, and that its session hasn't been revoked since it was issued.
+func (s ServiceImpl) VerifyToken(token string) (*Payload, error) {
+
+	payload, err := s.auth.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var active bool
+	query := `select revoked_at is null from sessions where session_id = $1 and user_id = $2`
+	err = s.conn.QueryRow(query, payload.SessionID, payload.UserID).Scan(&active)
+	if err == pgx.ErrNoRows || (err == nil && !active) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PurgeSessions deletes expired and revoked sessions, expired refresh and password reset tokens,
+// and the password reset requests done with that no longer count against the limits, and returns
+// how many sessions were deleted.
+func (s ServiceImpl) PurgeSessions(ctx context.Context) (int64, error) {
+	_, err := s.conn.Exec(`delete from refresh_tokens where expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.conn.Exec(`delete from password_resets where expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	query := `delete from password_reset_requests where created_at < now() - $1 * interval '1 second' and (sent_at is not null or attempts >= $2)`
+	_, err = s.conn.Exec(query, int64(ResetLimitWindow.Seconds()), MaxResetAttempts)
+	if err != nil {
+		return 0, err
+	}
+	tag, err := s.conn.Exec(`delete from sessions where expires_at < now() or revoked_at is not null`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RunSessionPurger purges sessions every interval until ctx is done.
+func RunSessionPurger(ctx context.Context, s *ServiceImpl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeSessions(ctx)
+		if err != nil {
+			log.Println("error purging sessions: ", err.Error())
+		} else if purged > 0 {
+			log.Printf("purged %v sessions", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
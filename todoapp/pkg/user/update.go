@@ -0,0 +1,90 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type UpdateUserHttpHandler struct {
+	Service Service
+}
+
+func NewUpdateUserHttpHandler(s Service) *UpdateUserHttpHandler {
+	return &UpdateUserHttpHandler{Service: s}
+}
+
+// UpdateUserRequestDTO is a merge patch of the account. Fields left out are not changed.
+// CurrentPassword is required to change the email.
+type UpdateUserRequestDTO struct {
+	UserName        *string `json:"username"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password,omitempty"`
+}
+
+func (h UpdateUserHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	payload, ok := PayloadFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var request UpdateUserRequestDTO
+	err = json.Unmarshal(bytes, &request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := UpdateUserCommand{
+		UserID:          payload.UserID,
+		SessionID:       payload.SessionID,
+		UserName:        request.UserName,
+		Email:           request.Email,
+		CurrentPassword: request.CurrentPassword,
+	}
+
+	user, err := h.Service.Update(r.Context(), &cmd)
+	if errors.Is(err, ErrUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrWrongPassword) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrEmailTaken) || errors.Is(err, ErrUsernameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if errors.Is(err, ErrInvalidUser) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(newResponseUserDTO(user))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
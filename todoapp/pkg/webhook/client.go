@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("webhook url resolves to a loopback, private or link-local address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which some clusters use for their pod and service networks.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// forbidden reports whether ip can't be reached from outside the network of the server, in which
+// case a webhook posting to it would let users probe the cluster through the delivery log.
+func forbidden(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// checkAddress rejects connections to forbidden addresses. It runs once the host of a webhook
+// is resolved, so that a name can't point there either.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || forbidden(ip) {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are posted with. Unless allowPrivate is set, which is
+// meant for local setups, it only connects to public addresses. It doesn't follow redirects, which
+// could lead anywhere, nor go through a proxy, which would dial in its place.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: DeliveryTimeout}
+	if !allowPrivate {
+		dialer.Control = checkAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   DeliveryTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
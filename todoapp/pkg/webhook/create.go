@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+)
+
+type CreateWebhookHttpHandler struct {
+	Service Service
+}
+
+func NewCreateWebhookHttpHandler(s Service) *CreateWebhookHttpHandler {
+	return &CreateWebhookHttpHandler{Service: s}
+}
+
+// CreateWebhookRequestDTO subscribes a URL to events, or to every event when empty. The
+// secret signing deliveries is generated when empty.
+type CreateWebhookRequestDTO struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// CreateWebhookResponseDTO is the only response carrying the secret of a webhook.
+type CreateWebhookResponseDTO struct {
+	ResponseWebhookDTO
+	Secret string `json:"secret"`
+}
+
+func (h CreateWebhookHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	dto := CreateWebhookRequestDTO{}
+	bytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.Unmarshal(bytes, &dto)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cmd := CreateWebhookCommand{
+		UserID: userID,
+		URL:    dto.URL,
+		Secret: dto.Secret,
+		Events: dto.Events,
+	}
+
+	webhook, err := h.Service.Create(r.Context(), &cmd)
+	if errors.Is(err, ErrInvalidURL) || errors.Is(err, ErrInvalidEvents) || errors.Is(err, ErrInvalidSecret) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err = json.Marshal(CreateWebhookResponseDTO{ResponseWebhookDTO: newResponseWebhookDTO(webhook), Secret: webhook.Secret})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type DeleteWebhookHttpHandler struct {
+	Service Service
+}
+
+func NewDeleteWebhookHttpHandler(s Service) *DeleteWebhookHttpHandler {
+	return &DeleteWebhookHttpHandler{Service: s}
+}
+
+func (h DeleteWebhookHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.Service.Delete(r.Context(), &DeleteWebhookCommand{UserID: userID, WebhookID: webhookID})
+	if errors.Is(err, ErrWebhookNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseWebhookDTO(webhook))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"encoding/json"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"time"
+)
+
+type GetAllWebhooksHttpHandler struct {
+	Service Service
+}
+
+func NewGetAllWebhooksHttpHandler(s Service) *GetAllWebhooksHttpHandler {
+	return &GetAllWebhooksHttpHandler{Service: s}
+}
+
+type GetAllResponseDTO struct {
+	Webhooks []ResponseWebhookDTO `json:"webhooks"`
+}
+
+type ResponseWebhookDTO struct {
+	WebhookID int       `json:"webhook_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newResponseWebhookDTO(webhook *Webhook) ResponseWebhookDTO {
+	return ResponseWebhookDTO{
+		WebhookID: webhook.WebhookID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func (h GetAllWebhooksHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	webhooks, err := h.Service.GetAll(r.Context(), &GetAllWebhooksCommand{UserID: userID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]ResponseWebhookDTO, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		res = append(res, newResponseWebhookDTO(webhook))
+	}
+
+	bytes, err := json.Marshal(GetAllResponseDTO{Webhooks: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
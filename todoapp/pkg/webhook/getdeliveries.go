@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type GetDeliveriesHttpHandler struct {
+	Service Service
+}
+
+func NewGetDeliveriesHttpHandler(s Service) *GetDeliveriesHttpHandler {
+	return &GetDeliveriesHttpHandler{Service: s}
+}
+
+type GetDeliveriesResponseDTO struct {
+	Deliveries []ResponseDeliveryDTO `json:"deliveries"`
+}
+
+// ResponseDeliveryDTO is a delivery of the log. NextAttemptAt is only set while it is pending,
+// and Payload is the body it is posted with.
+type ResponseDeliveryDTO struct {
+	DeliveryID     int             `json:"delivery_id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func newResponseDeliveryDTO(delivery *Delivery) ResponseDeliveryDTO {
+	dto := ResponseDeliveryDTO{
+		DeliveryID:     delivery.DeliveryID,
+		WebhookID:      delivery.WebhookID,
+		Event:          delivery.Event,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		Payload:        json.RawMessage(delivery.Payload),
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+	if delivery.Status == StatusPending {
+		dto.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return dto
+}
+
+// ServeHTTP lists the latest deliveries of a webhook, newest first.
+func (h GetDeliveriesHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.Service.GetDeliveries(r.Context(), &GetDeliveriesCommand{UserID: userID, WebhookID: webhookID})
+	if errors.Is(err, ErrWebhookNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]ResponseDeliveryDTO, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		res = append(res, newResponseDeliveryDTO(delivery))
+	}
+
+	bytes, err := json.Marshal(GetDeliveriesResponseDTO{Deliveries: res})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
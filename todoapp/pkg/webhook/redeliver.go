@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"kuberneteslab/todoapp/pkg/middlewares"
+	"net/http"
+	"strconv"
+)
+
+type RedeliverHttpHandler struct {
+	Service Service
+}
+
+func NewRedeliverHttpHandler(s Service) *RedeliverHttpHandler {
+	return &RedeliverHttpHandler{Service: s}
+}
+
+// ServeHTTP queues the event of a delivery to be sent again, as a new delivery, and answers
+// 202 with it. The delivery it copies is left as it was.
+func (h RedeliverHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := middlewares.UserIDFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := strconv.Atoi(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmd := RedeliverCommand{
+		UserID:     userID,
+		WebhookID:  webhookID,
+		DeliveryID: deliveryID,
+	}
+
+	delivery, err := h.Service.Redeliver(r.Context(), &cmd)
+	if errors.Is(err, ErrDeliveryNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(newResponseDeliveryDTO(delivery))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(bytes)
+}
@@ -0,0 +1,490 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx"
+	"io"
+	"kuberneteslab/todoapp/pkg/events"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	EventCreated   = "todo.created"
+	EventUpdated   = "todo.updated"
+	EventCompleted = "todo.completed"
+	EventDeleted   = "todo.deleted"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	// DefaultBackoff is the wait before the second attempt of a delivery, doubled after each
+	// failed attempt up to MaxBackoff.
+	DefaultBackoff = 30 * time.Second
+	MaxBackoff     = 6 * time.Hour
+	// MaxAttempts is how many times a delivery is attempted before it is marked as failed.
+	MaxAttempts     = 8
+	DeliveryTimeout = 10 * time.Second
+
+	MinSecretLength = 16
+	// deliveryLease is how long a claimed delivery is hidden from other dispatchers while it is
+	// sent. It must outlast DeliveryTimeout, with slack for recording the outcome.
+	deliveryLease = 6 * DeliveryTimeout
+	logLimit      = 100
+)
+
+// AllEvents are the events a webhook can subscribe to.
+var AllEvents = []string{EventCreated, EventUpdated, EventCompleted, EventDeleted}
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	ErrInvalidURL       = errors.New("url must be an absolute http or https URL")
+	ErrInvalidEvents    = errors.New("events must be todo.created, todo.updated, todo.completed or todo.deleted")
+	ErrInvalidSecret    = errors.New("secret must be at least 16 characters")
+)
+
+// Webhook is where a user wants the events of their todos posted.
+type Webhook struct {
+	WebhookID int
+	UserID    int
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Delivery is an event posted, or to be posted, to a webhook.
+type Delivery struct {
+	DeliveryID     int
+	WebhookID      int
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode *int
+	LastError      *string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type Service interface {
+	Create(ctx context.Context, cmd *CreateWebhookCommand) (*Webhook, error)
+	GetAll(ctx context.Context, cmd *GetAllWebhooksCommand) ([]*Webhook, error)
+	Delete(ctx context.Context, cmd *DeleteWebhookCommand) (*Webhook, error)
+	GetDeliveries(ctx context.Context, cmd *GetDeliveriesCommand) ([]*Delivery, error)
+	Redeliver(ctx context.Context, cmd *RedeliverCommand) (*Delivery, error)
+}
+
+// CreateWebhookCommand subscribes URL to Events, or to every event when empty. A secret is
+// generated when Secret is empty.
+type CreateWebhookCommand struct {
+	UserID int
+	URL    string
+	Secret string
+	Events []string
+}
+
+type GetAllWebhooksCommand struct {
+	UserID int
+}
+
+type DeleteWebhookCommand struct {
+	UserID    int
+	WebhookID int
+}
+
+// GetDeliveriesCommand lists the latest deliveries of a webhook, newest first.
+type GetDeliveriesCommand struct {
+	UserID    int
+	WebhookID int
+}
+
+// RedeliverCommand queues a new delivery of the event of a delivery.
+type RedeliverCommand struct {
+	UserID     int
+	WebhookID  int
+	DeliveryID int
+}
+
+type ServiceImpl struct {
+	conn    *pgx.ConnPool
+	client  *http.Client
+	backoff time.Duration
+}
+
+// NewServiceImpl returns a service whose deliveries are only posted to public addresses, unless
+// allowPrivate is set.
+func NewServiceImpl(conn *pgx.ConnPool, backoff time.Duration, allowPrivate bool) *ServiceImpl {
+	return &ServiceImpl{
+		conn:    conn,
+		client:  newClient(allowPrivate),
+		backoff: backoff,
+	}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const webhookColumns = `webhook_id, user_id, url, secret, events, created_at`
+
+func scanWebhook(row scanner) (*Webhook, error) {
+	var w Webhook
+	err := row.Scan(&w.WebhookID, &w.UserID, &w.URL, &w.Secret, &w.Events, &w.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+const deliveryColumns = `delivery_id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanDelivery(row scanner) (*Delivery, error) {
+	var d Delivery
+	err := row.Scan(&d.DeliveryID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// normalizeEvents returns the events subscribed to, without duplicates and in the order of AllEvents.
+func normalizeEvents(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return AllEvents, nil
+	}
+	subscribed := map[string]bool{}
+	for _, name := range names {
+		subscribed[name] = true
+	}
+	normalized := make([]string, 0, len(subscribed))
+	for _, event := range AllEvents {
+		if subscribed[event] {
+			normalized = append(normalized, event)
+		}
+	}
+	if len(normalized) != len(subscribed) {
+		return nil, ErrInvalidEvents
+	}
+	return normalized, nil
+}
+
+func newSecret() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// Sign returns the signature header of a delivery sent at t: the time in Unix seconds, and the
+// hex HMAC-SHA256, keyed with the secret of the webhook, of that time and the body joined by a
+// dot. Receivers should recompute it, and reject deliveries whose time is too old.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := fmt.Sprint(t.Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func (s ServiceImpl) Create(ctx context.Context, cmd *CreateWebhookCommand) (*Webhook, error) {
+
+	if !validURL(cmd.URL) {
+		return nil, ErrInvalidURL
+	}
+	names, err := normalizeEvents(cmd.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret := cmd.Secret
+	if secret == "" {
+		secret, err = newSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(secret) < MinSecretLength {
+		return nil, ErrInvalidSecret
+	}
+
+	query := `insert into webhooks(user_id, url, secret, events) values ($1, $2, $3, $4) returning ` + webhookColumns
+	return scanWebhook(s.conn.QueryRow(query, cmd.UserID, cmd.URL, secret, names))
+}
+
+func (s ServiceImpl) GetAll(ctx context.Context, cmd *GetAllWebhooksCommand) ([]*Webhook, error) {
+
+	rows, err := s.conn.Query(`select `+webhookColumns+` from webhooks where user_id = $1 order by webhook_id`, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Delete removes a webhook along with its deliveries, including those not sent yet.
+func (s ServiceImpl) Delete(ctx context.Context, cmd *DeleteWebhookCommand) (*Webhook, error) {
+
+	query := `delete from webhooks where webhook_id = $1 and user_id = $2 returning ` + webhookColumns
+	return scanWebhook(s.conn.QueryRow(query, cmd.WebhookID, cmd.UserID))
+}
+
+func (s ServiceImpl) GetDeliveries(ctx context.Context, cmd *GetDeliveriesCommand) ([]*Delivery, error) {
+
+	_, err := scanWebhook(s.conn.QueryRow(`select `+webhookColumns+` from webhooks where webhook_id = $1 and user_id = $2`, cmd.WebhookID, cmd.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + deliveryColumns + ` from webhook_deliveries where webhook_id = $1 order by delivery_id desc limit $2`
+	rows, err := s.conn.Query(query, cmd.WebhookID, logLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*Delivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s ServiceImpl) Redeliver(ctx context.Context, cmd *RedeliverCommand) (*Delivery, error) {
+
+	query := `insert into webhook_deliveries(webhook_id, event, payload, next_attempt_at)
+		select d.webhook_id, d.event, d.payload, $1 from webhook_deliveries d join webhooks w on w.webhook_id = d.webhook_id
+		where d.delivery_id = $2 and d.webhook_id = $3 and w.user_id = $4
+		returning ` + deliveryColumns
+	return scanDelivery(s.conn.QueryRow(query, time.Now(), cmd.DeliveryID, cmd.WebhookID, cmd.UserID))
+}
+
+// payloadDTO is the body of a delivery. Todo is the todo after the change, left out when only
+// its id is known.
+type payloadDTO struct {
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	TodoID    int             `json:"todo_id"`
+	Todo      json.RawMessage `json:"todo,omitempty"`
+}
+
+// TodosChanged queues a delivery of each event to the webhooks of its user subscribed to it,
+// in the transaction of the change, so events are only delivered once it commits.
+func (s ServiceImpl) TodosChanged(tx *pgx.Tx, changes ...events.Event) error {
+	for _, change := range changes {
+		event := "todo." + change.Type
+		payload, err := json.Marshal(payloadDTO{Event: event, CreatedAt: time.Now().UTC(), TodoID: change.TodoID, Todo: change.Todo})
+		if err != nil {
+			return err
+		}
+
+		query := `insert into webhook_deliveries(webhook_id, event, payload, next_attempt_at)
+			select webhook_id, $2, $3, $4 from webhooks where user_id = $1 and $2 = any(events)`
+		_, err = tx.Exec(query, change.UserID, event, string(payload), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimed is a delivery claimed by a dispatcher, with the webhook it is sent to.
+type claimed struct {
+	delivery *Delivery
+	url      string
+	secret   string
+}
+
+// DeliverDue sends up to limit deliveries that are due, one after another, and returns how many
+// it sent. Each is claimed for a lease right before it is sent, so dispatchers of several pods
+// don't send it twice.
+func (s ServiceImpl) DeliverDue(ctx context.Context, limit int) (int, error) {
+
+	sent := 0
+	for sent < limit {
+		c, err := s.claim()
+		if err != nil {
+			return sent, err
+		}
+		if c == nil {
+			break
+		}
+
+		statusCode, err := s.send(ctx, *c)
+		err = s.record(c.delivery, statusCode, err)
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// claim leases the delivery due first, and returns nil when none is due. The lease outlasts a
+// send, so it can't run out while the delivery is being sent.
+func (s ServiceImpl) claim() (*claimed, error) {
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `select ` + prefixed("d.", deliveryColumns) + `, w.url, w.secret
+		from webhook_deliveries d join webhooks w on w.webhook_id = d.webhook_id
+		where d.status = $1 and d.next_attempt_at <= $2
+		order by d.next_attempt_at limit 1
+		for update of d skip locked`
+	var c claimed
+	var d Delivery
+	err = tx.QueryRow(query, StatusPending, time.Now()).Scan(&d.DeliveryID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &c.url, &c.secret)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.delivery = &d
+
+	_, err = tx.Exec(`update webhook_deliveries set next_attempt_at = $1 where delivery_id = $2`, time.Now().Add(deliveryLease), d.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return &c, tx.Commit()
+}
+
+func prefixed(prefix string, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = prefix + name
+	}
+	return strings.Join(names, ", ")
+}
+
+// send posts a delivery, and returns the status code of the response, 0 when there was none.
+func (s ServiceImpl) send(ctx context.Context, c claimed) (int, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, strings.NewReader(c.delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, c.delivery.Event)
+	req.Header.Set(DeliveryHeader, fmt.Sprint(c.delivery.DeliveryID))
+	req.Header.Set(SignatureHeader, Sign(c.secret, time.Now(), []byte(c.delivery.Payload)))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver answered %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// record stores the outcome of an attempt, and schedules the next one after a failure.
+func (s ServiceImpl) record(d *Delivery, statusCode int, sendErr error) error {
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	attempts := d.Attempts + 1
+	now := time.Now()
+
+	if sendErr == nil {
+		query := `update webhook_deliveries set status = $1, attempts = $2, last_status_code = $3, last_error = null, delivered_at = $4 where delivery_id = $5`
+		_, err := s.conn.Exec(query, StatusSucceeded, attempts, code, now, d.DeliveryID)
+		return err
+	}
+
+	status := StatusPending
+	if attempts >= MaxAttempts {
+		status = StatusFailed
+	}
+	backoff := s.backoff << (attempts - 1)
+	if backoff > MaxBackoff || backoff <= 0 {
+		backoff = MaxBackoff
+	}
+	query := `update webhook_deliveries set status = $1, attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5 where delivery_id = $6`
+	_, err := s.conn.Exec(query, status, attempts, code, sendErr.Error(), now.Add(backoff), d.DeliveryID)
+	return err
+}
+
+// RunDispatcher sends the due deliveries every interval until ctx is done.
+func RunDispatcher(ctx context.Context, s *ServiceImpl, interval time.Duration) {
+	const batch = 20
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			sent, err := s.DeliverDue(ctx, batch)
+			if err != nil {
+				log.Println("error delivering webhooks: ", err.Error())
+			}
+			if err != nil || sent < batch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+
+	t.Run("send should post the payload signed with the secret of the webhook", func(t *testing.T) {
+		received := make(chan *http.Request, 1)
+		bodies := make(chan string, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- r
+			bodies <- string(body)
+		}))
+		defer receiver.Close()
+
+		s := NewServiceImpl(nil, DefaultBackoff, true)
+		payload := `{"event":"todo.created","todo_id":1}`
+		delivery := &Delivery{DeliveryID: 7, Event: EventCreated, Payload: payload}
+		statusCode, err := s.send(context.Background(), claimed{delivery: delivery, url: receiver.URL, secret: "0123456789abcdef"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, statusCode)
+
+		r := <-received
+		require.Equal(t, payload, <-bodies)
+		require.Equal(t, EventCreated, r.Header.Get(EventHeader))
+		require.Equal(t, "7", r.Header.Get(DeliveryHeader))
+
+		var timestamp int64
+		var signature string
+		_, err = fmt.Sscanf(strings.Replace(r.Header.Get(SignatureHeader), ",", " ", 1), "t=%d v1=%s", &timestamp, &signature)
+		require.NoError(t, err)
+		require.InDelta(t, time.Now().Unix(), timestamp, 5)
+
+		mac := hmac.New(sha256.New, []byte("0123456789abcdef"))
+		mac.Write([]byte(fmt.Sprintf("%d.%s", timestamp, payload)))
+		require.Equal(t, hex.EncodeToString(mac.Sum(nil)), signature)
+	})
+
+	t.Run("send to a receiver answering an error should fail with its status", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer receiver.Close()
+
+		s := NewServiceImpl(nil, DefaultBackoff, true)
+		delivery := &Delivery{DeliveryID: 1, Event: EventDeleted, Payload: `{}`}
+		statusCode, err := s.send(context.Background(), claimed{delivery: delivery, url: receiver.URL, secret: "0123456789abcdef"})
+		require.Error(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, statusCode)
+	})
+	t.Run("send to a loopback receiver should be refused unless private networks are allowed", func(t *testing.T) {
+		received := 0
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received++
+		}))
+		defer receiver.Close()
+
+		s := NewServiceImpl(nil, DefaultBackoff, false)
+		delivery := &Delivery{DeliveryID: 1, Event: EventCreated, Payload: `{}`}
+		statusCode, err := s.send(context.Background(), claimed{delivery: delivery, url: receiver.URL, secret: "0123456789abcdef"})
+		require.ErrorIs(t, err, ErrForbiddenAddress)
+		require.Equal(t, 0, statusCode)
+		require.Equal(t, 0, received)
+	})
+
+	t.Run("send to a receiver answering a redirect should not follow it", func(t *testing.T) {
+		followed := false
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			followed = true
+		}))
+		defer target.Close()
+		receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+		defer receiver.Close()
+
+		s := NewServiceImpl(nil, DefaultBackoff, true)
+		delivery := &Delivery{DeliveryID: 1, Event: EventCreated, Payload: `{}`}
+		statusCode, err := s.send(context.Background(), claimed{delivery: delivery, url: receiver.URL, secret: "0123456789abcdef"})
+		require.Error(t, err)
+		require.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		require.False(t, followed)
+	})
+}
+
+func TestForbidden(t *testing.T) {
+	tests := []struct {
+		ip        string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.96.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.10", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			require.Equal(t, test.forbidden, forbidden(net.ParseIP(test.ip)))
+		})
+	}
+}
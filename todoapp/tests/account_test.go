@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/mail"
+	"kuberneteslab/todoapp/pkg/server"
+	"kuberneteslab/todoapp/pkg/user"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntegrationAccount(t *testing.T) {
+
+	t.Run("get the account should return the user of the token", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		response := shareRequestHelper(t, http.MethodGet, "http://localhost:8080/users/me", token, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var userDTO user.ResponseUserDTO
+		err = json.Unmarshal(bytesReaded, &userDTO)
+		require.NoError(t, err)
+		require.Equal(t, userID, userDTO.UserID)
+		require.Equal(t, "username1", userDTO.UserName)
+		require.Equal(t, "email1", userDTO.Email)
+	})
+
+	t.Run("update the account with a used email should return conflict", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		otherID, _ := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{userID, otherID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		response := shareRequestHelper(t, http.MethodPatch, "http://localhost:8080/users/me", token, map[string]string{"email": "email2", "current_password": "password1"})
+		require.Equal(t, http.StatusConflict, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, "http://localhost:8080/users/me", token, map[string]string{"username": "username2"})
+		require.Equal(t, http.StatusConflict, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, "http://localhost:8080/users/me", token, map[string]string{"username": ""})
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, "http://localhost:8080/users/me", token, map[string]string{"username": "renamed"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var userDTO user.ResponseUserDTO
+		err = json.Unmarshal(bytesReaded, &userDTO)
+		require.NoError(t, err)
+		require.Equal(t, "renamed", userDTO.UserName)
+		require.Equal(t, "email1", userDTO.Email)
+	})
+
+	t.Run("change the email should require the current password and log out the other sessions", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+		other := loginHelper(t, "email1", "password1")
+
+		url := "http://localhost:8080/users/me"
+		response := shareRequestHelper(t, http.MethodPatch, url, token, map[string]string{"email": "email2"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+		response = shareRequestHelper(t, http.MethodPatch, url, token, map[string]string{"email": "email2", "current_password": "wrong"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+		require.Equal(t, http.StatusOK, authorizedGetHelper(t, url, other.Token).StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, url, token, map[string]string{"email": "email2", "current_password": "password1"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		require.Equal(t, http.StatusOK, authorizedGetHelper(t, url, token).StatusCode)
+		require.Equal(t, http.StatusUnauthorized, authorizedGetHelper(t, url, other.Token).StatusCode)
+		res, _ := refreshHelper(t, other.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("change the password should require the current one and log out the other sessions", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+		other := loginHelper(t, "email1", "password1")
+
+		url := "http://localhost:8080/users/me/password"
+		response := shareRequestHelper(t, http.MethodPost, url, token, &user.ChangePasswordRequestDTO{CurrentPassword: "wrong", NewPassword: "password2"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPost, url, token, &user.ChangePasswordRequestDTO{CurrentPassword: "password1", NewPassword: "password2"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		require.Equal(t, http.StatusOK, authorizedGetHelper(t, "http://localhost:8080/users/me", token).StatusCode)
+		require.Equal(t, http.StatusUnauthorized, authorizedGetHelper(t, "http://localhost:8080/users/me", other.Token).StatusCode)
+		res, _ := refreshHelper(t, other.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		loginHelper(t, "email1", "password2")
+	})
+
+	t.Run("delete the account should delete the user", func(t *testing.T) {
+		_, token := credentialsHelper(t)
+
+		response := shareRequestHelper(t, http.MethodDelete, "http://localhost:8080/users/me", token, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		marshalled, err := json.Marshal(&user.LoginUserRequestDTO{UserEmail: "email1", UserPassword: "password1"})
+		require.NoError(t, err)
+		res, err := http.Post("http://localhost:8080/user/login", "application/json", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
+
+func TestIntegrationPasswordReset(t *testing.T) {
+
+	t.Run("reset the password with an emailed token should work once and log out every session", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		sent := len(mailboxHelper(t))
+		response := shareRequestHelper(t, http.MethodPost, "http://localhost:8080/user/password/forgot", "", &user.ForgotPasswordRequestDTO{Email: "email1"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		resetToken := resetTokenHelper(t, "email1", sent)
+
+		url := "http://localhost:8080/user/password/reset"
+		response = shareRequestHelper(t, http.MethodPost, url, "", &user.ResetPasswordRequestDTO{Token: resetToken, NewPassword: "password2"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		require.Equal(t, http.StatusUnauthorized, authorizedGetHelper(t, "http://localhost:8080/users/me", token).StatusCode)
+		loginHelper(t, "email1", "password2")
+
+		response = shareRequestHelper(t, http.MethodPost, url, "", &user.ResetPasswordRequestDTO{Token: resetToken, NewPassword: "password3"})
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("forgot the password of an unknown email should return ok without sending anything", func(t *testing.T) {
+		before := len(mailboxHelper(t))
+
+		email := fmt.Sprint("unknown", time.Now().UnixNano())
+		response := shareRequestHelper(t, http.MethodPost, "http://localhost:8080/user/password/forgot", "", &user.ForgotPasswordRequestDTO{Email: email})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		// The email would be sent in the background.
+		time.Sleep(2 * time.Second)
+		require.Equal(t, before, len(mailboxHelper(t)))
+	})
+
+	t.Run("forgot the password too often should return too many requests", func(t *testing.T) {
+		email := fmt.Sprint("limited", time.Now().UnixNano())
+		url := "http://localhost:8080/user/password/forgot"
+		for i := 0; i < user.DefaultResetLimits.PerEmail; i++ {
+			response := shareRequestHelper(t, http.MethodPost, url, "", &user.ForgotPasswordRequestDTO{Email: email})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		response := shareRequestHelper(t, http.MethodPost, url, "", &user.ForgotPasswordRequestDTO{Email: " " + strings.ToUpper(email)})
+		require.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+		require.NotEmpty(t, response.Header.Get("Retry-After"))
+	})
+
+	t.Run("reset the password with an unknown token should return bad request", func(t *testing.T) {
+		response := shareRequestHelper(t, http.MethodPost, "http://localhost:8080/user/password/reset", "", &user.ResetPasswordRequestDTO{Token: "unknown", NewPassword: "password2"})
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
+
+// mailboxHelper returns the emails the server sent to the file of its mail config.
+func mailboxHelper(t *testing.T) []mail.Message {
+	content, err := os.ReadFile("../../config/local.json")
+	require.NoError(t, err)
+	var config server.Config
+	err = json.Unmarshal(content, &config)
+	require.NoError(t, err)
+
+	messages, err := mail.ReadFile(config.Mail.File)
+	require.NoError(t, err)
+	return messages
+}
+
+var resetTokenPattern = regexp.MustCompile(`\n\n(\S+)\n\n`)
+
+// resetTokenHelper waits for the server to email a reset token to email, after the first sent
+// emails of the mailbox, and returns it.
+func resetTokenHelper(t *testing.T, email string, sent int) string {
+	var token string
+	require.Eventually(t, func() bool {
+		messages := mailboxHelper(t)
+		for i := len(messages) - 1; i >= sent; i-- {
+			if messages[i].To == email {
+				match := resetTokenPattern.FindStringSubmatch(messages[i].Body)
+				if match == nil {
+					return false
+				}
+				token = match[1]
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond, "no email was sent to "+email)
+	return token
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/todo"
+	"kuberneteslab/todoapp/pkg/user"
+	"kuberneteslab/todoapp/pkg/webhook"
+	"net/http"
+	"testing"
+)
+
+func TestIntegrationChecklist(t *testing.T) {
+
+	t.Run("add, toggle, reorder and remove items should update the todo progress", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+		itemsURL := fmt.Sprintf("http://localhost:8080/todos/%v/items", todoResponse.TodoID)
+
+		items := make([]todo.ResponseItemDTO, 0, 2)
+		for _, title := range []string{"first", "second"} {
+			marshalled, err := json.Marshal(&todo.AddItemRequestDTO{Title: title})
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, itemsURL, bytes.NewBuffer(marshalled))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var item todo.ResponseItemDTO
+			err = json.Unmarshal(bytesReaded, &item)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.Equal(t, title, item.Title)
+			items = append(items, item)
+		}
+		require.Less(t, items[0].Position, items[1].Position)
+
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%v/toggle", itemsURL, items[0].ItemID), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		got, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: userID, TodoID: todoResponse.TodoID})
+		require.NoError(t, err)
+		require.Equal(t, 50, got.Progress())
+
+		marshalled, err := json.Marshal(&todo.ReorderItemsRequestDTO{ItemIDs: []int{items[1].ItemID, items[0].ItemID}})
+		require.NoError(t, err)
+		req, err = http.NewRequest(http.MethodPut, itemsURL+"/order", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var reordered todo.GetItemsResponseDTO
+		err = json.Unmarshal(bytesReaded, &reordered)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, items[1].ItemID, reordered.Items[0].ItemID)
+		require.Equal(t, items[0].ItemID, reordered.Items[1].ItemID)
+
+		req, err = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%v", itemsURL, items[1].ItemID), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		req, err = http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/todos/%v", todoResponse.TodoID), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.ResponseTodoDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+		require.Equal(t, 100, todoResponseDTO.Progress)
+	})
+
+	t.Run("toggle an item should notify the todo with its new progress", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+		created := createWebhookHelper(t, token, webhook.CreateWebhookRequestDTO{URL: "http://example.com/hook", Events: []string{webhook.EventUpdated}})
+
+		item, err := ts.AddItem(context.Background(), &todo.AddItemCommand{UserID: userID, TodoID: todoResponse.TodoID, Title: "first"})
+		require.NoError(t, err)
+		_, err = ts.ToggleItem(context.Background(), &todo.ToggleItemCommand{UserID: userID, TodoID: todoResponse.TodoID, ItemID: item.ItemID})
+		require.NoError(t, err)
+
+		progress := map[float64]bool{}
+		for _, delivery := range getDeliveriesHelper(t, token, created.WebhookID) {
+			var payload map[string]interface{}
+			err = json.Unmarshal(delivery.Payload, &payload)
+			require.NoError(t, err)
+			progress[payload["todo"].(map[string]interface{})["progress"].(float64)] = true
+		}
+		require.Equal(t, map[float64]bool{0: true, 100: true}, progress)
+	})
+
+	t.Run("reorder with missing items should return bad request", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+		for _, title := range []string{"first", "second"} {
+			_, err := ts.AddItem(context.Background(), &todo.AddItemCommand{UserID: userID, TodoID: todoResponse.TodoID, Title: title})
+			require.NoError(t, err)
+		}
+
+		marshalled, err := json.Marshal(&todo.ReorderItemsRequestDTO{ItemIDs: []int{1}})
+		require.NoError(t, err)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/items/order", todoResponse.TodoID)
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("add an item to a todo of other user should return not found", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: ownerID})
+			require.NoError(t, err)
+		}()
+		todoResponse := createTodoHelper(t, ownerID, ownerToken)
+
+		otherID, otherToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: otherID})
+			require.NoError(t, err)
+		}()
+
+		marshalled, err := json.Marshal(&todo.AddItemRequestDTO{Title: "item"})
+		require.NoError(t, err)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/items", todoResponse.TodoID)
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherToken))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/policy"
+	"kuberneteslab/todoapp/pkg/todo"
+	"kuberneteslab/todoapp/pkg/user"
+	"net/http"
+	"testing"
+)
+
+func TestIntegrationCollaboration(t *testing.T) {
+
+	t.Run("assign a todo to a grantee should list it as assigned to them", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		assigneeID, assigneeToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, assigneeID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "unassigned"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, url+"/shares", ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleEditor})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, url, ownerToken, map[string]int{"assignee_id": assigneeID})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var updated todo.UpdateTodoResponseDTO
+		err = json.Unmarshal(bytesReaded, &updated)
+		require.NoError(t, err)
+		require.Equal(t, assigneeID, *updated.AssigneeID)
+
+		response = shareRequestHelper(t, http.MethodGet, "http://localhost:8080/users/me/todos?assignee=me", assigneeToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var todosDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todosDTO)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(todosDTO.Todos))
+		require.Equal(t, shared.TodoID, todosDTO.Todos[0].TodoID)
+		require.Equal(t, assigneeID, *todosDTO.Todos[0].AssigneeID)
+
+		response = shareRequestHelper(t, http.MethodGet, "http://localhost:8080/users/me/todos?assignee=me", ownerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+		err = json.Unmarshal(bytesReaded, &todosDTO)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(todosDTO.Todos))
+	})
+
+	t.Run("assign a todo to a user without access should return 400", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		strangerID, _ := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, strangerID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		created, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "private"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", created.TodoID)
+		response := shareRequestHelper(t, http.MethodPatch, url, ownerToken, map[string]int{"assignee_id": strangerID})
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("comment on a shared todo should be readable by everyone with access", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		viewerID, viewerToken := credentialsHelperWith(t, "username2", "email2")
+		strangerID, strangerToken := credentialsHelperWith(t, "username3", "email3")
+		defer func() {
+			for _, id := range []int{ownerID, viewerID, strangerID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, url+"/shares", ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPost, url+"/comments", viewerToken, &todo.CommentRequestDTO{Body: "looks good"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPost, url+"/comments", viewerToken, &todo.CommentRequestDTO{Body: "  "})
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPost, url+"/comments", strangerToken, &todo.CommentRequestDTO{Body: "hello"})
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodGet, url+"/comments", ownerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var commentsDTO todo.GetCommentsResponseDTO
+		err = json.Unmarshal(bytesReaded, &commentsDTO)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(commentsDTO.Comments))
+		require.Equal(t, viewerID, commentsDTO.Comments[0].UserID)
+		require.Equal(t, "username2", commentsDTO.Comments[0].UserName)
+		require.Equal(t, "looks good", commentsDTO.Comments[0].Body)
+		require.Nil(t, commentsDTO.Comments[0].EditedAt)
+	})
+
+	t.Run("edit a comment should only be allowed for its author", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		editorID, editorToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, editorID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, url+"/shares", ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleEditor})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		comment, err := ts.AddComment(context.Background(), &todo.AddCommentCommand{UserID: editorID, TodoID: shared.TodoID, Body: "first"})
+		require.NoError(t, err)
+		commentURL := fmt.Sprintf("%v/comments/%v", url, comment.CommentID)
+
+		response = shareRequestHelper(t, http.MethodPatch, commentURL, ownerToken, &todo.CommentRequestDTO{Body: "changed"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, commentURL, editorToken, &todo.CommentRequestDTO{Body: "changed"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var commentDTO todo.ResponseCommentDTO
+		err = json.Unmarshal(bytesReaded, &commentDTO)
+		require.NoError(t, err)
+		require.Equal(t, "changed", commentDTO.Body)
+		require.NotNil(t, commentDTO.EditedAt)
+
+		// The owner of the todo can still remove comments on it.
+		response = shareRequestHelper(t, http.MethodDelete, commentURL, ownerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodDelete, commentURL, editorToken, nil)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+}
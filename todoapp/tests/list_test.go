@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/todo"
+	"kuberneteslab/todoapp/pkg/user"
+	"net/http"
+	"testing"
+)
+
+func TestIntegrationLists(t *testing.T) {
+
+	t.Run("create a list and a todo in it should filter the listing by list", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		marshalled, err := json.Marshal(&list.CreateListRequestDTO{Name: "Work"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/lists", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var listResponseDTO list.ResponseListDTO
+		err = json.Unmarshal(bytesReaded, &listResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "Work", listResponseDTO.Name)
+
+		inList, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, ListID: &listResponseDTO.ListID, Title: "in list"})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "no list"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/users/me/todos?list_id=%v", listResponseDTO.ListID)
+		req, err = http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 1, len(todoResponseDTO.Todos))
+		require.Equal(t, inList.TodoID, todoResponseDTO.Todos[0].TodoID)
+		require.Equal(t, listResponseDTO.ListID, *todoResponseDTO.Todos[0].ListID)
+	})
+
+	t.Run("create a todo in an archived list should fail", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		l, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: userID, Name: "old"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/lists/%v/archive", l.ListID)
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, ListID: &l.ListID, Title: "late"})
+		require.ErrorIs(t, err, list.ErrListArchived)
+	})
+
+	t.Run("delete a list should move or delete its todos", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		moved, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: userID, Name: "moved"})
+		require.NoError(t, err)
+		target, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: userID, Name: "target"})
+		require.NoError(t, err)
+		dropped, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: userID, Name: "dropped"})
+		require.NoError(t, err)
+
+		movedTodo, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, ListID: &moved.ListID, Title: "moved"})
+		require.NoError(t, err)
+		droppedTodo, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, ListID: &dropped.ListID, Title: "dropped"})
+		require.NoError(t, err)
+		trashedTodo, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, ListID: &moved.ListID, Title: "trashed"})
+		require.NoError(t, err)
+		trashed, err := ts.Delete(context.Background(), &todo.DeleteTodoCommand{UserID: userID, TodoID: trashedTodo.TodoID})
+		require.NoError(t, err)
+
+		for _, url := range []string{
+			fmt.Sprintf("http://localhost:8080/lists/%v?todos=move&move_to=%v", moved.ListID, target.ListID),
+			fmt.Sprintf("http://localhost:8080/lists/%v?todos=delete", dropped.ListID),
+		} {
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		got, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: userID, TodoID: movedTodo.TodoID})
+		require.NoError(t, err)
+		require.Equal(t, target.ListID, *got.ListID)
+
+		// The trashed todo moves along without a new version.
+		var listID, version int
+		err = db.QueryRow(`select list_id, version from todos where todo_id = $1`, trashed.TodoID).Scan(&listID, &version)
+		require.NoError(t, err)
+		require.Equal(t, target.ListID, listID)
+		require.Equal(t, trashed.Version, version)
+
+		_, err = ts.Get(context.Background(), &todo.GetTodoCommand{UserID: userID, TodoID: droppedTodo.TodoID})
+		require.ErrorIs(t, err, todo.ErrTodoNotFound)
+	})
+
+}
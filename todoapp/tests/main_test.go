@@ -2,9 +2,13 @@ package tests
 
 import (
 	"github.com/jackc/pgx"
+	"kuberneteslab/todoapp/pkg/events"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/mail"
 	"kuberneteslab/todoapp/pkg/server"
 	"kuberneteslab/todoapp/pkg/todo"
 	"kuberneteslab/todoapp/pkg/user"
+	"kuberneteslab/todoapp/pkg/webhook"
 	"log"
 	"net/http"
 	"os"
@@ -13,8 +17,11 @@ import (
 )
 
 var (
-	us *user.ServiceImpl
-	ts *todo.ServiceImpl
+	db  *pgx.ConnPool
+	us  *user.ServiceImpl
+	ts  *todo.ServiceImpl
+	ls  *list.ServiceImpl
+	whs *webhook.ServiceImpl
 )
 
 func TestMain(m *testing.M) {
@@ -46,6 +53,7 @@ func TestMain(m *testing.M) {
 	}
 
 	conn, err := pgx.NewConnPool(pc)
+	db = conn
 	if err != nil {
 		log.Fatal("cannot connect to database: ", err.Error())
 	}
@@ -54,8 +62,13 @@ func TestMain(m *testing.M) {
 		log.Fatal("error creating auth service", err.Error())
 	}
 
-	us = user.NewServiceImpl(conn, authSvc)
+	us = user.NewServiceImpl(conn, authSvc, mail.NewMemoryMailer(), user.DefaultAccessTokenTTL, user.DefaultRefreshTokenTTL, user.DefaultResetLimits)
 	ts = todo.NewServiceImpl(conn)
+	ts.AddHook(events.Notifier{})
+	ls = list.NewServiceImpl(conn)
+	ls.AddHook(events.Notifier{})
+	whs = webhook.NewServiceImpl(conn, webhook.DefaultBackoff, true)
+	ts.AddHook(whs)
 	exitVal := m.Run()
 	os.Exit(exitVal)
 }
@@ -0,0 +1,380 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/list"
+	"kuberneteslab/todoapp/pkg/policy"
+	"kuberneteslab/todoapp/pkg/todo"
+	"kuberneteslab/todoapp/pkg/user"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntegrationSharing(t *testing.T) {
+
+	t.Run("share a todo as viewer should let the grantee read it but not change it", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		viewerID, viewerToken := credentialsHelperWith(t, "username2", "email2")
+		strangerID, strangerToken := credentialsHelperWith(t, "username3", "email3")
+		defer func() {
+			for _, id := range []int{ownerID, viewerID, strangerID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/shares", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, url, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		url = fmt.Sprintf("http://localhost:8080/todos/%v", shared.TodoID)
+		response = shareRequestHelper(t, http.MethodGet, url, viewerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPatch, url, viewerToken, map[string]string{"title": "changed"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodGet, url, strangerToken, nil)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodGet, "http://localhost:8080/users/me/todos", viewerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var todosDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todosDTO)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(todosDTO.Todos))
+		require.Equal(t, shared.TodoID, todosDTO.Todos[0].TodoID)
+	})
+
+	t.Run("share a todo as editor should let the grantee change it until revoked", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		editorID, editorToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, editorID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/shares", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, url, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleEditor})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		todoURL := fmt.Sprintf("http://localhost:8080/todos/%v", shared.TodoID)
+		response = shareRequestHelper(t, http.MethodPatch, todoURL, editorToken, map[string]string{"title": "changed"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPost, todoURL+"/complete", editorToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodDelete, todoURL, editorToken, nil)
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodGet, url, editorToken, nil)
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodDelete, fmt.Sprintf("%s/%v", url, editorID), ownerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodGet, todoURL, editorToken, nil)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		current, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: ownerID, TodoID: shared.TodoID})
+		require.NoError(t, err)
+		require.Equal(t, "changed", current.Title)
+		require.True(t, current.Completed)
+	})
+
+	t.Run("share a list should share the todos in it", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		viewerID, viewerToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, viewerID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		l, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: ownerID, Name: "Team"})
+		require.NoError(t, err)
+		inList, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, ListID: &l.ListID, Title: "in list"})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "private"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/lists/%v/shares", l.ListID)
+		response := shareRequestHelper(t, http.MethodPost, url, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodGet, "http://localhost:8080/lists", viewerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var listsDTO list.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &listsDTO)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(listsDTO.Lists))
+		require.Equal(t, l.ListID, listsDTO.Lists[0].ListID)
+		require.Equal(t, policy.RoleViewer, listsDTO.Lists[0].Role)
+
+		response = shareRequestHelper(t, http.MethodGet, "http://localhost:8080/users/me/todos", viewerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var todosDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todosDTO)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(todosDTO.Todos))
+		require.Equal(t, inList.TodoID, todosDTO.Todos[0].TodoID)
+
+		response = shareRequestHelper(t, http.MethodPatch, fmt.Sprintf("http://localhost:8080/todos/%v", inList.TodoID), viewerToken, map[string]string{"title": "changed"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+	})
+
+	t.Run("stream events of a grantee should push the changes of the todos shared with them", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		granteeID, granteeToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, granteeID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/shares", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, url, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080/todos/events", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", granteeToken))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		received := make(chan [2]string)
+		go func() {
+			defer close(received)
+			var eventType string
+			scanner := bufio.NewScanner(response.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if strings.HasPrefix(line, "event: ") {
+					eventType = strings.TrimPrefix(line, "event: ")
+				}
+				if strings.HasPrefix(line, "data: ") {
+					received <- [2]string{eventType, strings.TrimPrefix(line, "data: ")}
+				}
+			}
+		}()
+		// Give the stream time to subscribe before changing todos.
+		time.Sleep(500 * time.Millisecond)
+
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "private"})
+		require.NoError(t, err)
+		title := "renamed"
+		_, err = ts.Update(context.Background(), &todo.UpdateTodoCommand{UserID: ownerID, TodoID: shared.TodoID, Title: &title})
+		require.NoError(t, err)
+
+		event := <-received
+		require.Equal(t, "updated", event[0])
+		var updatedDTO todo.ResponseTodoDTO
+		require.NoError(t, json.Unmarshal([]byte(event[1]), &updatedDTO))
+		require.Equal(t, shared.TodoID, updatedDTO.TodoID)
+		require.Equal(t, "renamed", updatedDTO.Title)
+	})
+
+	t.Run("sync of a grantee should return the shared todos and tombstones once unshared", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		granteeID, _ := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, granteeID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		l, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: ownerID, Name: "Team"})
+		require.NoError(t, err)
+		inList, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, ListID: &l.ListID, Title: "in list"})
+		require.NoError(t, err)
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "private"})
+		require.NoError(t, err)
+
+		todoShares := fmt.Sprintf("http://localhost:8080/todos/%v/shares", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, todoShares, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleEditor})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		listShares := fmt.Sprintf("http://localhost:8080/lists/%v/shares", l.ListID)
+		response = shareRequestHelper(t, http.MethodPost, listShares, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		first, err := ts.Changes(context.Background(), &todo.GetChangesCommand{UserID: granteeID})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(first.Changes))
+		for _, change := range first.Changes {
+			require.False(t, change.Deleted())
+			require.Contains(t, []int{inList.TodoID, shared.TodoID}, change.TodoID)
+		}
+
+		response = shareRequestHelper(t, http.MethodDelete, fmt.Sprintf("%s/%v", todoShares, granteeID), ownerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		response = shareRequestHelper(t, http.MethodDelete, fmt.Sprintf("%s/%v", listShares, granteeID), ownerToken, nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		second, err := ts.Changes(context.Background(), &todo.GetChangesCommand{UserID: granteeID, Since: first.Token})
+		require.NoError(t, err)
+		tombstones := map[int]bool{}
+		for _, change := range second.Changes {
+			if change.Todo == nil {
+				tombstones[change.TodoID] = true
+			}
+		}
+		require.Equal(t, map[int]bool{inList.TodoID: true, shared.TodoID: true}, tombstones)
+
+		owner, err := ts.Changes(context.Background(), &todo.GetChangesCommand{UserID: ownerID})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(owner.Changes))
+	})
+
+	t.Run("sync of a grantee with a token should return the todos shared with them since", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		granteeID, _ := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, granteeID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		l, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: ownerID, Name: "Team"})
+		require.NoError(t, err)
+		inList, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, ListID: &l.ListID, Title: "in list"})
+		require.NoError(t, err)
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+		// The todos last changed long before the grantee syncs.
+		_, err = db.Exec(`update todos set updated_at = now() - interval '1 hour' where user_id = $1`, ownerID)
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: granteeID, Title: "own"})
+		require.NoError(t, err)
+
+		first, err := ts.Changes(context.Background(), &todo.GetChangesCommand{UserID: granteeID})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(first.Changes))
+
+		todoShares := fmt.Sprintf("http://localhost:8080/todos/%v/shares", shared.TodoID)
+		response := shareRequestHelper(t, http.MethodPost, todoShares, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		listShares := fmt.Sprintf("http://localhost:8080/lists/%v/shares", l.ListID)
+		response = shareRequestHelper(t, http.MethodPost, listShares, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		second, err := ts.Changes(context.Background(), &todo.GetChangesCommand{UserID: granteeID, Since: first.Token})
+		require.NoError(t, err)
+		revealed := map[int]bool{}
+		for _, change := range second.Changes {
+			require.False(t, change.Deleted())
+			revealed[change.TodoID] = true
+		}
+		require.True(t, revealed[inList.TodoID])
+		require.True(t, revealed[shared.TodoID])
+	})
+
+	t.Run("create a todo in a shared list should be allowed to editors only", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		editorID, editorToken := credentialsHelperWith(t, "username2", "email2")
+		viewerID, viewerToken := credentialsHelperWith(t, "username3", "email3")
+		defer func() {
+			for _, id := range []int{ownerID, editorID, viewerID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		l, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: ownerID, Name: "Team"})
+		require.NoError(t, err)
+		url := fmt.Sprintf("http://localhost:8080/lists/%v/shares", l.ListID)
+		response := shareRequestHelper(t, http.MethodPost, url, ownerToken, &policy.ShareRequestDTO{Email: "email2", Role: policy.RoleEditor})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		response = shareRequestHelper(t, http.MethodPost, url, ownerToken, &policy.ShareRequestDTO{Email: "email3", Role: policy.RoleViewer})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = shareRequestHelper(t, http.MethodPost, "http://localhost:8080/todos", editorToken, &todo.CreateTodoRequestDTO{ListID: &l.ListID, Title: "from the editor"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		var created todo.CreateTodoResponseDTO
+		require.NoError(t, json.Unmarshal(bytesReaded, &created))
+
+		added, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: ownerID, TodoID: created.TodoID})
+		require.NoError(t, err)
+		require.Equal(t, ownerID, added.UserID)
+		require.Equal(t, l.ListID, *added.ListID)
+
+		response = shareRequestHelper(t, http.MethodPost, "http://localhost:8080/todos", viewerToken, &todo.CreateTodoRequestDTO{ListID: &l.ListID, Title: "from the viewer"})
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+	})
+
+	t.Run("share a todo with an unknown email or an invalid role should return bad request", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		otherID, _ := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			for _, id := range []int{ownerID, otherID} {
+				_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: id})
+				require.NoError(t, err)
+			}
+		}()
+
+		shared, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: ownerID, Title: "shared"})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/shares", shared.TodoID)
+		for _, dto := range []policy.ShareRequestDTO{
+			{Email: "nobody", Role: policy.RoleViewer},
+			{Email: "email2", Role: policy.RoleOwner},
+			{Email: "email1", Role: policy.RoleEditor},
+		} {
+			response := shareRequestHelper(t, http.MethodPost, url, ownerToken, &dto)
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		}
+	})
+}
+
+func shareRequestHelper(t *testing.T, method string, url string, token string, body interface{}) *http.Response {
+
+	var reader io.Reader
+	if body != nil {
+		marshalled, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewBuffer(marshalled)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	response, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return response
+}
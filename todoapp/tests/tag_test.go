@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/tag"
+	"kuberneteslab/todoapp/pkg/todo"
+	"kuberneteslab/todoapp/pkg/user"
+	"net/http"
+	"testing"
+)
+
+func TestIntegrationTags(t *testing.T) {
+
+	t.Run("create a todo with tags should return normalized tags", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoRequestDTO := todo.CreateTodoRequestDTO{
+			Title:   "title1",
+			Content: "content1",
+			Tags:    []string{"Urgent", " backend ", "urgent"},
+		}
+		marshalled, err := json.Marshal(&todoRequestDTO)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todos", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.CreateTodoResponseDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, []string{"backend", "urgent"}, todoResponseDTO.Tags)
+	})
+
+	t.Run("get todos filtered by tags should apply all and any semantics", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		both, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "both", Tags: []string{"backend", "urgent"}})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "backend", Tags: []string{"backend"}})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "untagged"})
+		require.NoError(t, err)
+
+		for query, expected := range map[string]int{"tags=backend,urgent": 1, "tags=backend,urgent&tag_match=any": 2} {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/users/me/todos?"+query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var todoResponseDTO todo.GetAllResponseDTO
+			err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.Equal(t, expected, len(todoResponseDTO.Todos), query)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/users/me/todos?tags=urgent", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(todoResponseDTO.Todos))
+		require.Equal(t, both.TodoID, todoResponseDTO.Todos[0].TodoID)
+	})
+
+	t.Run("get tags should return usage counts", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		_, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "one", Tags: []string{"backend"}})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "two", Tags: []string{"backend", "urgent"}})
+		require.NoError(t, err)
+
+		marshalled, err := json.Marshal(&tag.CreateTagRequestDTO{Name: "later"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/tags", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		req, err = http.NewRequest(http.MethodGet, "http://localhost:8080/tags", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var tagsResponseDTO tag.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &tagsResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		counts := make(map[string]int)
+		for _, tg := range tagsResponseDTO.Tags {
+			counts[tg.Name] = tg.TodoCount
+		}
+		require.Equal(t, map[string]int{"backend": 2, "later": 0, "urgent": 1}, counts)
+	})
+
+	t.Run("create a tag with a used name should return conflict", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		for i, expected := range []int{http.StatusOK, http.StatusConflict} {
+			marshalled, err := json.Marshal(&tag.CreateTagRequestDTO{Name: "Backend"})
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/tags", bytes.NewBuffer(marshalled))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, expected, response.StatusCode, i)
+		}
+	})
+
+}
@@ -1,16 +1,21 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
 	"io"
+	"kuberneteslab/todoapp/pkg/list"
 	"kuberneteslab/todoapp/pkg/todo"
 	"kuberneteslab/todoapp/pkg/user"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIntegrationTodos(t *testing.T) {
@@ -119,11 +124,11 @@ func TestIntegrationTodos(t *testing.T) {
 			require.NoError(t, err)
 		}()
 
-		updateRequest := todo.UpdateTodoRequestDTO{
-			UserID:  userID,
-			TodoID:  todoResponse.TodoID,
-			Title:   "title updated",
-			Content: "content updated",
+		updateRequest := map[string]interface{}{
+			"user_id": userID,
+			"todo_id": todoResponse.TodoID,
+			"title":   "title updated",
+			"content": "content updated",
 		}
 		marshalled, err := json.Marshal(&updateRequest)
 		require.NoError(t, err)
@@ -141,10 +146,10 @@ func TestIntegrationTodos(t *testing.T) {
 		require.NoError(t, err)
 
 		require.Equal(t, http.StatusOK, response.StatusCode)
-		require.Equal(t, updateRequest.Title, todoResponseDTO.Title)
-		require.Equal(t, updateRequest.Content, todoResponseDTO.Content)
-		require.Equal(t, updateRequest.TodoID, todoResponseDTO.TodoID)
-		require.Equal(t, updateRequest.UserID, todoResponseDTO.UserID)
+		require.Equal(t, "title updated", todoResponseDTO.Title)
+		require.Equal(t, "content updated", todoResponseDTO.Content)
+		require.Equal(t, todoResponse.TodoID, todoResponseDTO.TodoID)
+		require.Equal(t, userID, todoResponseDTO.UserID)
 	})
 
 	t.Run("update todo with valid token that belongs to other user should return unauthorized", func(t *testing.T) {
@@ -160,11 +165,11 @@ func TestIntegrationTodos(t *testing.T) {
 			require.NoError(t, err)
 		}()
 
-		updateRequest := todo.UpdateTodoRequestDTO{
-			UserID:  999,
-			TodoID:  todoResponse.TodoID,
-			Title:   "title updated",
-			Content: "content updated",
+		updateRequest := map[string]interface{}{
+			"user_id": 999,
+			"todo_id": todoResponse.TodoID,
+			"title":   "title updated",
+			"content": "content updated",
 		}
 		marshalled, err := json.Marshal(&updateRequest)
 		require.NoError(t, err)
@@ -191,11 +196,11 @@ func TestIntegrationTodos(t *testing.T) {
 			require.NoError(t, err)
 		}()
 
-		updateRequest := todo.UpdateTodoRequestDTO{
-			UserID:  999,
-			TodoID:  todoResponse.TodoID,
-			Title:   "title updated",
-			Content: "content updated",
+		updateRequest := map[string]interface{}{
+			"user_id": 999,
+			"todo_id": todoResponse.TodoID,
+			"title":   "title updated",
+			"content": "content updated",
 		}
 		marshalled, err := json.Marshal(&updateRequest)
 		require.NoError(t, err)
@@ -378,18 +383,1126 @@ func TestIntegrationTodos(t *testing.T) {
 
 	})
 
-}
+	t.Run("create a todo with due date and priority should return them", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
 
-func credentialsHelper(t *testing.T) (int, string) {
+		dueAt := time.Date(2030, time.January, 2, 15, 4, 5, 0, time.UTC)
+		todoRequestDTO := todo.CreateTodoRequestDTO{
+			UserID:   userID,
+			Title:    "title1",
+			Content:  "content1",
+			DueAt:    &dueAt,
+			Priority: todo.PriorityHigh,
+		}
+		marshalled, err := json.Marshal(&todoRequestDTO)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todo", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.CreateTodoResponseDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NotNil(t, todoResponseDTO.DueAt)
+		require.True(t, dueAt.Equal(*todoResponseDTO.DueAt))
+		require.Equal(t, todo.PriorityHigh, todoResponseDTO.Priority)
+		require.False(t, todoResponseDTO.Completed)
+	})
+
+	t.Run("create a todo with invalid priority should return bad request", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoRequestDTO := todo.CreateTodoRequestDTO{
+			UserID:   userID,
+			Title:    "title1",
+			Content:  "content1",
+			Priority: 7,
+		}
+		marshalled, err := json.Marshal(&todoRequestDTO)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todo", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("complete and reopen a todo that belongs to me should return ok", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+
+		completeRequest := todo.CompleteTodoRequestDTO{
+			UserID: userID,
+			TodoID: todoResponse.TodoID,
+		}
+		marshalled, err := json.Marshal(&completeRequest)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todo/complete", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var completed todo.ResponseTodoDTO
+		err = json.Unmarshal(bytesReaded, &completed)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.True(t, completed.Completed)
+		require.NotNil(t, completed.CompletedAt)
+
+		req, err = http.NewRequest(http.MethodPost, "http://localhost:8080/todo/reopen", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var reopened todo.ResponseTodoDTO
+		err = json.Unmarshal(bytesReaded, &reopened)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.False(t, reopened.Completed)
+		require.Nil(t, reopened.CompletedAt)
+	})
+
+	t.Run("complete a todo for other user should return unauthorized", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+
+		completeRequest := todo.CompleteTodoRequestDTO{
+			UserID: 999,
+			TodoID: todoResponse.TodoID,
+		}
+		marshalled, err := json.Marshal(&completeRequest)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todo/complete", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	})
+
+	t.Run("get todos with limit should paginate with next cursor", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		created := make([]int, 0, 3)
+		for i := 0; i < 3; i++ {
+			created = append(created, createTodoHelper(t, userID, token).TodoID)
+		}
+
+		pages := make([]todo.GetAllResponseDTO, 0, 2)
+		cursor := ""
+		for {
+			url := fmt.Sprintf("http://localhost:8080/todo?user_id=%v&sort=created&limit=2&cursor=%v", userID, cursor)
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var page todo.GetAllResponseDTO
+			err = json.Unmarshal(bytesReaded, &page)
+			require.NoError(t, err)
+			pages = append(pages, page)
+
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+
+		require.Equal(t, 2, len(pages))
+		require.Equal(t, 2, len(pages[0].Todos))
+		require.Equal(t, 1, len(pages[1].Todos))
+		require.Equal(t, created[0], pages[0].Todos[0].TodoID)
+		require.Equal(t, created[1], pages[0].Todos[1].TodoID)
+		require.Equal(t, created[2], pages[1].Todos[0].TodoID)
+	})
+
+	t.Run("get todos filtered by status should only return matching todos", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		open := createTodoHelper(t, userID, token)
+		done := createTodoHelper(t, userID, token)
+		_, err := ts.Complete(context.Background(), &todo.CompleteTodoCommand{UserID: userID, TodoID: done.TodoID})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todo?user_id=%v&status=open", userID)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 1, len(todoResponseDTO.Todos))
+		require.Equal(t, open.TodoID, todoResponseDTO.Todos[0].TodoID)
+	})
+
+	t.Run("get todos with a malformed cursor should return bad request", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		url := fmt.Sprintf("http://localhost:8080/todo?user_id=%v&cursor=notacursor", userID)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("get, update and delete a todo by id should use the token user", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", todoResponse.TodoID)
+
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var got todo.ResponseTodoDTO
+		err = json.Unmarshal(bytesReaded, &got)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, todoResponse.TodoID, got.TodoID)
+		require.Equal(t, todoResponse.Name, got.Title)
+
+		marshalled, err := json.Marshal(map[string]string{"title": "title updated", "content": "content updated"})
+		require.NoError(t, err)
+		req, err = http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("If-Match", response.Header.Get("ETag"))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var updated todo.UpdateTodoResponseDTO
+		err = json.Unmarshal(bytesReaded, &updated)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "title updated", updated.Title)
+		require.Equal(t, userID, updated.UserID)
+
+		req, err = http.NewRequest(http.MethodDelete, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("If-Match", response.Header.Get("ETag"))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		req, err = http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("get a todo by id that belongs to other user should return not found", func(t *testing.T) {
+		ownerID, ownerToken := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: ownerID})
+			require.NoError(t, err)
+		}()
+		todoResponse := createTodoHelper(t, ownerID, ownerToken)
+
+		otherID, otherToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: otherID})
+			require.NoError(t, err)
+		}()
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", todoResponse.TodoID)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherToken))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("get my todos should return the todos of the token user", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		todoResponse := createTodoHelper(t, userID, token)
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/users/me/todos", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var todoResponseDTO todo.GetAllResponseDTO
+		err = json.Unmarshal(bytesReaded, &todoResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 1, len(todoResponseDTO.Todos))
+		require.Equal(t, todoResponse.TodoID, todoResponseDTO.Todos[0].TodoID)
+	})
+
+	t.Run("deprecated routes should advertise their successor", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		url := fmt.Sprintf("http://localhost:8080/todo?user_id=%v", userID)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "true", response.Header.Get("Deprecation"))
+		require.Contains(t, response.Header.Get("Link"), "/users/me/todos")
+	})
+
+	t.Run("search todos should rank matches and tolerate typos", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		groceries, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "buy groceries", Content: "milk and eggs"})
+		require.NoError(t, err)
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "call the bank", Content: "ask about the loan"})
+		require.NoError(t, err)
+
+		for _, q := range []string{"groceries", "grocries"} {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/todos/search?q="+q, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var searchResponseDTO todo.SearchResponseDTO
+			err = json.Unmarshal(bytesReaded, &searchResponseDTO)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.Equal(t, 1, len(searchResponseDTO.Results), q)
+			require.Equal(t, groceries.TodoID, searchResponseDTO.Results[0].TodoID)
+		}
+	})
+
+	t.Run("search todos should escape the markup of the highlighted content", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		_, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "<b>groceries</b>", Content: `<img src=x onerror="alert(1)"> milk`})
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/todos/search?q=groceries+milk", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var searchResponseDTO todo.SearchResponseDTO
+		err = json.Unmarshal(bytesReaded, &searchResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 1, len(searchResponseDTO.Results))
+		result := searchResponseDTO.Results[0]
+		require.Equal(t, "&lt;b&gt;<mark>groceries</mark>&lt;/b&gt;", result.TitleHighlight)
+		require.NotContains(t, result.ContentHighlight, "<img")
+		require.Contains(t, result.ContentHighlight, "&lt;img")
+		require.Contains(t, result.ContentHighlight, "<mark>milk</mark>")
+		require.Equal(t, "<b>groceries</b>", result.Title)
+	})
+
+	t.Run("search todos without query should return bad request", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/todos/search", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("complete a recurring todo should spawn its next occurrence once", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		dueAt := time.Date(2026, time.March, 7, 14, 0, 0, 0, time.UTC)
+		created, err := ts.Create(context.Background(), &todo.CreateTodoCommand{
+			UserID:       userID,
+			Title:        "water the plants",
+			DueAt:        &dueAt,
+			Tags:         []string{"home"},
+			Recurrence:   "FREQ=DAILY;COUNT=2",
+			RecurrenceTZ: "America/New_York",
+		})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/complete", created.TodoID)
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var completed todo.ResponseTodoDTO
+		err = json.Unmarshal(bytesReaded, &completed)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "FREQ=DAILY;COUNT=2", *completed.Recurrence)
+		require.NotNil(t, completed.NextTodoID)
+
+		next, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: userID, TodoID: *completed.NextTodoID})
+		require.NoError(t, err)
+		require.False(t, next.Completed)
+		require.Equal(t, []string{"home"}, next.Tags)
+		// 9:00 in New York, which moved from UTC-5 to UTC-4 on March 8.
+		require.Equal(t, time.Date(2026, time.March, 8, 13, 0, 0, 0, time.UTC), next.DueAt.UTC())
+
+		_, err = ts.Reopen(context.Background(), &todo.ReopenTodoCommand{UserID: userID, TodoID: created.TodoID})
+		require.NoError(t, err)
+		again, err := ts.Complete(context.Background(), &todo.CompleteTodoCommand{UserID: userID, TodoID: created.TodoID})
+		require.NoError(t, err)
+		require.Equal(t, *completed.NextTodoID, *again.NextTodoID)
+
+		last, err := ts.Complete(context.Background(), &todo.CompleteTodoCommand{UserID: userID, TodoID: next.TodoID})
+		require.NoError(t, err)
+		require.Nil(t, last.NextTodoID)
+	})
+
+	t.Run("create a recurring todo without due date or with an unsupported rule should fail", func(t *testing.T) {
+		_, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: 1, Title: "t", Recurrence: "FREQ=DAILY"})
+		require.ErrorIs(t, err, todo.ErrRecurrenceWithoutDue)
+
+		dueAt := time.Now()
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: 1, Title: "t", DueAt: &dueAt, Recurrence: "FREQ=HOURLY"})
+		require.Error(t, err)
+	})
+
+	t.Run("delete a todo should move it to the trash until restored or purged", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		restored := createTodoHelper(t, userID, token)
+		purged := createTodoHelper(t, userID, token)
+
+		for _, id := range []int{restored.TodoID, purged.TodoID} {
+			_, err := ts.Delete(context.Background(), &todo.DeleteTodoCommand{UserID: userID, TodoID: id})
+			require.NoError(t, err)
+		}
+
+		page, err := ts.GetAll(context.Background(), &todo.GetAllTodosCommand{UserID: userID})
+		require.NoError(t, err)
+		require.Equal(t, 0, len(page.Todos))
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/todos/trash", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var trashResponseDTO todo.GetTrashResponseDTO
+		err = json.Unmarshal(bytesReaded, &trashResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 2, len(trashResponseDTO.Todos))
+		require.NotNil(t, trashResponseDTO.Todos[0].DeletedAt)
+
+		url := fmt.Sprintf("http://localhost:8080/todos/%v/restore", restored.TodoID)
+		req, err = http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		got, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: userID, TodoID: restored.TodoID})
+		require.NoError(t, err)
+		require.Nil(t, got.DeletedAt)
+
+		url = fmt.Sprintf("http://localhost:8080/todos/trash/%v", restored.TodoID)
+		req, err = http.NewRequest(http.MethodDelete, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		url = fmt.Sprintf("http://localhost:8080/todos/trash/%v", purged.TodoID)
+		req, err = http.NewRequest(http.MethodDelete, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		trash, err := ts.GetTrash(context.Background(), &todo.GetTrashCommand{UserID: userID})
+		require.NoError(t, err)
+		require.Equal(t, 0, len(trash))
+	})
+
+	t.Run("update a todo should record its history and allow reverting it", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		created, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "first", Content: "a\nb"})
+		require.NoError(t, err)
+
+		marshalled, err := json.Marshal(map[string]string{"title": "second", "content": "a\nc"})
+		require.NoError(t, err)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", created.TodoID)
+		req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, created.Version))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		url = fmt.Sprintf("http://localhost:8080/todos/%v/history", created.TodoID)
+		req, err = http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var historyResponseDTO todo.GetHistoryResponseDTO
+		err = json.Unmarshal(bytesReaded, &historyResponseDTO)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 2, len(historyResponseDTO.Revisions))
+		update, create := historyResponseDTO.Revisions[0], historyResponseDTO.Revisions[1]
+		require.Equal(t, todo.ActionUpdate, update.Action)
+		require.Equal(t, userID, *update.UserID)
+		require.Equal(t, "first", *update.Title.Old)
+		require.Equal(t, "second", *update.Title.New)
+		require.Equal(t, []string{"  a", "- b", "+ c"}, update.ContentDiff)
+		require.Equal(t, todo.ActionCreate, create.Action)
+		require.Nil(t, create.Title.Old)
+
+		marshalled, err = json.Marshal(&todo.RevertTodoRequestDTO{RevisionID: create.RevisionID})
+		require.NoError(t, err)
+		url = fmt.Sprintf("http://localhost:8080/todos/%v/revert", created.TodoID)
+		for _, c := range []struct {
+			ifMatch string
+			status  int
+		}{
+			{"", http.StatusPreconditionRequired},
+			{fmt.Sprintf(`"%d"`, created.Version), http.StatusPreconditionFailed},
+		} {
+			req, err = http.NewRequest(http.MethodPost, url, bytes.NewBuffer(marshalled))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			if c.ifMatch != "" {
+				req.Header.Set("If-Match", c.ifMatch)
+			}
+			response, err = http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, c.status, response.StatusCode)
+		}
+
+		req, err = http.NewRequest(http.MethodPost, url, bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, created.Version+1))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		bytesReaded, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		var reverted todo.ResponseTodoDTO
+		err = json.Unmarshal(bytesReaded, &reverted)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "first", reverted.Title)
+		require.Equal(t, "a\nb", reverted.Content)
+
+		history, err := ts.History(context.Background(), &todo.GetHistoryCommand{UserID: userID, TodoID: created.TodoID})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(history))
+		require.Equal(t, todo.ActionRevert, history[0].Action)
+	})
+
+	t.Run("conditional requests should use the version of the todo as ETag", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		created, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "versioned"})
+		require.NoError(t, err)
+		require.Equal(t, 1, created.Version)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", created.TodoID)
+
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("If-None-Match", `"1"`)
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, response.StatusCode)
+		require.Equal(t, `"1"`, response.Header.Get("ETag"))
+
+		marshalled, err := json.Marshal(map[string]string{"title": "changed"})
+		require.NoError(t, err)
+
+		for _, c := range []struct {
+			ifMatch string
+			status  int
+		}{
+			{"", http.StatusPreconditionRequired},
+			{`"2"`, http.StatusPreconditionFailed},
+			{`W/"1"`, http.StatusPreconditionFailed},
+			{`"2", "3"`, http.StatusPreconditionFailed},
+			{`"9", "1"`, http.StatusOK},
+			{`"1"`, http.StatusPreconditionFailed},
+		} {
+			req, err = http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(marshalled))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			if c.ifMatch != "" {
+				req.Header.Set("If-Match", c.ifMatch)
+			}
+			response, err = http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, c.status, response.StatusCode, c.ifMatch)
+		}
+		require.Equal(t, `"2"`, response.Header.Get("ETag"))
+
+		req, err = http.NewRequest(http.MethodGet, "http://localhost:8080/users/me/todos", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		etag := response.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		req.Header.Set("If-None-Match", etag)
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, response.StatusCode)
+
+		req, err = http.NewRequest(http.MethodDelete, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("If-Match", `"1"`)
+		response, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusPreconditionFailed, response.StatusCode)
+	})
+
+	t.Run("patch a todo should only change the fields it carries", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		created, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "title", Content: "content", Priority: todo.PriorityHigh, Tags: []string{"a"}})
+		require.NoError(t, err)
+		url := fmt.Sprintf("http://localhost:8080/todos/%v", created.TodoID)
+
+		patch := func(contentType string, body string, version int) (*http.Response, todo.UpdateTodoResponseDTO) {
+			req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, version))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var updated todo.UpdateTodoResponseDTO
+			if response.StatusCode == http.StatusOK {
+				err = json.Unmarshal(bytesReaded, &updated)
+				require.NoError(t, err)
+			}
+			return response, updated
+		}
+
+		response, updated := patch("application/merge-patch+json", `{"title":"new title"}`, 1)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "new title", updated.Title)
+		require.Equal(t, "content", updated.Content)
+		require.Equal(t, todo.PriorityHigh, updated.Priority)
+		require.Equal(t, []string{"a"}, updated.Tags)
+
+		response, updated = patch("application/json-patch+json", `[{"op":"test","path":"/title","value":"new title"},{"op":"add","path":"/tags/-","value":"b"},{"op":"remove","path":"/content"}]`, 2)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "new title", updated.Title)
+		require.Equal(t, "", updated.Content)
+		require.Equal(t, []string{"a", "b"}, updated.Tags)
+
+		response, _ = patch("application/merge-patch+json", `{"completed":true}`, 3)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response, _ = patch("application/json-patch+json", `[{"op":"replace","path":"/owner","value":1}]`, 3)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response, _ = patch("text/plain", `title`, 3)
+		require.Equal(t, http.StatusUnsupportedMediaType, response.StatusCode)
+	})
+
+	t.Run("create a todo twice with the same idempotency key should replay the first response", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		key := fmt.Sprintf("create-todo-%v", userID)
+		post := func(title string) (*http.Response, todo.CreateTodoResponseDTO) {
+			marshalled, err := json.Marshal(&todo.CreateTodoRequestDTO{Title: title})
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todos", bytes.NewBuffer(marshalled))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			req.Header.Set("Idempotency-Key", key)
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var created todo.CreateTodoResponseDTO
+			if response.StatusCode == http.StatusOK {
+				err = json.Unmarshal(bytesReaded, &created)
+				require.NoError(t, err)
+			}
+			return response, created
+		}
+
+		first, firstDTO := post("only once")
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		require.Empty(t, first.Header.Get("Idempotent-Replayed"))
+
+		retry, retryDTO := post("only once")
+		require.Equal(t, http.StatusOK, retry.StatusCode)
+		require.Equal(t, "true", retry.Header.Get("Idempotent-Replayed"))
+		require.Equal(t, firstDTO.TodoID, retryDTO.TodoID)
+
+		other, _ := post("something else")
+		require.Equal(t, http.StatusUnprocessableEntity, other.StatusCode)
+
+		page, err := ts.GetAll(context.Background(), &todo.GetAllTodosCommand{UserID: userID})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.Todos))
+	})
+
+	t.Run("batch todo operations should commit all or none of them unless best effort", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		first, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "first"})
+		require.NoError(t, err)
+		second, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "second"})
+		require.NoError(t, err)
+
+		batch := func(body string) (*http.Response, todo.BatchTodosResponseDTO) {
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/todos/batch", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			var result todo.BatchTodosResponseDTO
+			if response.StatusCode != http.StatusBadRequest {
+				err = json.Unmarshal(bytesReaded, &result)
+				require.NoError(t, err)
+			}
+			return response, result
+		}
+
+		response, result := batch(fmt.Sprintf(`{"operations":[
+			{"op":"complete","todo_id":%d},
+			{"op":"update","todo_id":%d,"todo":{"tags":["moved"]}},
+			{"op":"delete","todo_id":%d}]}`, first.TodoID, second.TodoID, first.TodoID+second.TodoID))
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+		require.False(t, result.Committed)
+		require.Equal(t, []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound},
+			[]int{result.Results[0].Status, result.Results[1].Status, result.Results[2].Status})
+
+		current, err := ts.Get(context.Background(), &todo.GetTodoCommand{UserID: userID, TodoID: first.TodoID})
+		require.NoError(t, err)
+		require.False(t, current.Completed)
+
+		response, result = batch(fmt.Sprintf(`{"mode":"best_effort","operations":[
+			{"op":"complete","todo_id":%d},
+			{"op":"update","todo_id":%d,"version":7,"todo":{"title":"stale"}},
+			{"op":"create","todo":{"title":"third","tags":["new"]}},
+			{"op":"delete","todo_id":%d}]}`, first.TodoID, second.TodoID, second.TodoID))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.True(t, result.Committed)
+		require.Equal(t, http.StatusOK, result.Results[0].Status)
+		require.True(t, result.Results[0].Todo.Completed)
+		require.Equal(t, http.StatusPreconditionFailed, result.Results[1].Status)
+		require.Equal(t, http.StatusOK, result.Results[2].Status)
+		require.Equal(t, []string{"new"}, result.Results[2].Todo.Tags)
+		require.Equal(t, http.StatusOK, result.Results[3].Status)
+
+		page, err := ts.GetAll(context.Background(), &todo.GetAllTodosCommand{UserID: userID})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(page.Todos))
+
+		response, _ = batch(`{"operations":[{"op":"archive","todo_id":1}]}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response, _ = batch(`{"operations":[]}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("stream events should push the changes of the todos of the user", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		otherID, _ := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+			_, err = us.Delete(context.Background(), &user.DeleteUserCommand{UserID: otherID})
+			require.NoError(t, err)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080/todos/events", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+		received := make(chan [2]string)
+		go func() {
+			defer close(received)
+			var eventType string
+			scanner := bufio.NewScanner(response.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if strings.HasPrefix(line, "event: ") {
+					eventType = strings.TrimPrefix(line, "event: ")
+				}
+				if strings.HasPrefix(line, "data: ") {
+					received <- [2]string{eventType, strings.TrimPrefix(line, "data: ")}
+				}
+			}
+		}()
+		// Give the stream time to subscribe before changing todos.
+		time.Sleep(500 * time.Millisecond)
+
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: otherID, Title: "not mine"})
+		require.NoError(t, err)
+		created, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "mine"})
+		require.NoError(t, err)
+		title := "renamed"
+		_, err = ts.Update(context.Background(), &todo.UpdateTodoCommand{UserID: userID, TodoID: created.TodoID, Title: &title})
+		require.NoError(t, err)
+		_, err = ts.Delete(context.Background(), &todo.DeleteTodoCommand{UserID: userID, TodoID: created.TodoID})
+		require.NoError(t, err)
+
+		event := <-received
+		require.Equal(t, "created", event[0])
+		var createdDTO todo.ResponseTodoDTO
+		require.NoError(t, json.Unmarshal([]byte(event[1]), &createdDTO))
+		require.Equal(t, created.TodoID, createdDTO.TodoID)
+
+		event = <-received
+		require.Equal(t, "updated", event[0])
+
+		event = <-received
+		require.Equal(t, "deleted", event[0])
+		var deletedDTO todo.DeletedTodoEventDTO
+		require.NoError(t, json.Unmarshal([]byte(event[1]), &deletedDTO))
+		require.Equal(t, todo.DeletedTodoEventDTO{TodoID: created.TodoID, Version: 3}, deletedDTO)
+	})
+
+	t.Run("live todos should apply mutations and push the events of subscribed lists", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		work, err := ls.Create(context.Background(), &list.CreateListCommand{UserID: userID, Name: "work"})
+		require.NoError(t, err)
+
+		_, _, err = websocket.DefaultDialer.Dial("ws://localhost:8080/todos/live", http.Header{"Authorization": {"Bearer invalid"}})
+		require.ErrorIs(t, err, websocket.ErrBadHandshake)
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws://localhost:8080/todos/live", nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+		send := func(req todo.LiveRequestDTO) {
+			require.NoError(t, conn.WriteJSON(req))
+		}
+		receive := func() todo.LiveResponseDTO {
+			var res todo.LiveResponseDTO
+			require.NoError(t, conn.ReadJSON(&res))
+			return res
+		}
+
+		send(todo.LiveRequestDTO{ID: "1", Type: "auth", Token: token})
+		require.Equal(t, todo.LiveResponseDTO{Type: "ack", ID: "1", Status: http.StatusOK}, receive())
+
+		send(todo.LiveRequestDTO{ID: "2", Type: "subscribe", ListID: &work.ListID})
+		require.Equal(t, "ack", receive().Type)
+
+		_, err = ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "outside the list"})
+		require.NoError(t, err)
+
+		send(todo.LiveRequestDTO{ID: "3", Type: "create", Todo: json.RawMessage(fmt.Sprintf(`{"title":"in the list","list_id":%d}`, work.ListID))})
+		ack := receive()
+		require.Equal(t, "ack", ack.Type)
+		require.Equal(t, "3", ack.ID)
+		var created todo.ResponseTodoDTO
+		require.NoError(t, json.Unmarshal(ack.Data, &created))
+
+		event := receive()
+		require.Equal(t, "event", event.Type)
+		require.Equal(t, "created", event.Event)
+		var pushed todo.ResponseTodoDTO
+		require.NoError(t, json.Unmarshal(event.Data, &pushed))
+		require.Equal(t, created.TodoID, pushed.TodoID)
+
+		version := 7
+		send(todo.LiveRequestDTO{ID: "4", Type: "update", TodoID: created.TodoID, Version: &version, Todo: json.RawMessage(`{"title":"stale"}`)})
+		failed := receive()
+		require.Equal(t, "error", failed.Type)
+		require.Equal(t, http.StatusPreconditionFailed, failed.Status)
+
+		send(todo.LiveRequestDTO{ID: "5", Type: "archive"})
+		require.Equal(t, http.StatusBadRequest, receive().Status)
+	})
+
+	t.Run("sync should return changes with tombstones and resolve conflicting changes", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		kept, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "kept", Content: "content"})
+		require.NoError(t, err)
+		purged, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: "purged"})
+		require.NoError(t, err)
+
+		sync := func(method string, url string, body string, dst interface{}) *http.Response {
+			req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			bytesReaded, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			if response.StatusCode == http.StatusOK {
+				require.NoError(t, json.Unmarshal(bytesReaded, dst))
+			}
+			return response
+		}
+
+		var first todo.GetChangesResponseDTO
+		response := sync(http.MethodGet, "http://localhost:8080/sync", "", &first)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 2, len(first.Changes))
+		require.False(t, first.HasMore)
+
+		_, err = ts.Delete(context.Background(), &todo.DeleteTodoCommand{UserID: userID, TodoID: purged.TodoID})
+		require.NoError(t, err)
+		_, err = ts.Purge(context.Background(), &todo.PurgeTodoCommand{UserID: userID, TodoID: purged.TodoID})
+		require.NoError(t, err)
+
+		var second todo.GetChangesResponseDTO
+		response = sync(http.MethodGet, "http://localhost:8080/sync?since="+first.Token, "", &second)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		tombstone := second.Changes[len(second.Changes)-1]
+		require.Equal(t, purged.TodoID, tombstone.TodoID)
+		require.True(t, tombstone.Deleted)
+		require.Nil(t, tombstone.Todo)
+
+		title := "changed on the server"
+		_, err = ts.Update(context.Background(), &todo.UpdateTodoCommand{UserID: userID, TodoID: kept.TodoID, Title: &title})
+		require.NoError(t, err)
+
+		var merged todo.PushChangesResponseDTO
+		response = sync(http.MethodPost, "http://localhost:8080/sync", fmt.Sprintf(`{"strategy":"field_merge","changes":[
+			{"todo_id":%d,"base_version":1,"changed_at":"2000-01-01T00:00:00Z","fields":{"title":"changed offline","content":"offline"},"base":{"title":"kept","content":"content"}},
+			{"client_id":"new-1","changed_at":"2000-01-01T00:00:00Z","fields":{"title":"created offline"}}]}`, kept.TodoID), &merged)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		require.Equal(t, http.StatusOK, merged.Results[0].Status)
+		require.Equal(t, &todo.SyncConflictDTO{Fields: []string{"title"}, Resolution: "server"}, merged.Results[0].Conflict)
+		require.Equal(t, "changed on the server", merged.Results[0].Todo.Title)
+		require.Equal(t, "offline", merged.Results[0].Todo.Content)
+
+		require.Equal(t, "new-1", merged.Results[1].ClientID)
+		require.NotZero(t, merged.Results[1].TodoID)
+		require.Equal(t, "created offline", merged.Results[1].Todo.Title)
+
+		var lww todo.PushChangesResponseDTO
+		response = sync(http.MethodPost, "http://localhost:8080/sync", fmt.Sprintf(`{"changes":[
+			{"todo_id":%d,"base_version":1,"changed_at":"2100-01-01T00:00:00Z","fields":{"title":"last writer"}}]}`, kept.TodoID), &lww)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "client", lww.Results[0].Conflict.Resolution)
+		require.Equal(t, "last writer", lww.Results[0].Todo.Title)
+
+		response = sync(http.MethodGet, "http://localhost:8080/sync?since=invalid", "", nil)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+}
+
+func credentialsHelper(t *testing.T) (int, string) {
+	return credentialsHelperWith(t, "username1", "email1")
+}
+
+func credentialsHelperWith(t *testing.T, username string, email string) (int, string) {
 
 	_, err := us.Create(context.Background(), &user.CreateUserCommand{
-		UserName: "username1",
-		Email:    "email1",
+		UserName: username,
+		Email:    email,
 		Password: "password1",
 	})
 	require.NoError(t, err)
 	requestDTO := user.LoginUserRequestDTO{
-		UserEmail:    "email1",
+		UserEmail:    email,
 		UserPassword: "password1",
 	}
 	marshalled, err := json.Marshal(&requestDTO)
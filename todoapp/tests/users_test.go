@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/stretchr/testify/require"
 	"io"
 	"kuberneteslab/todoapp/pkg/user"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func TestIntegrationUsers(t *testing.T) {
@@ -40,6 +42,51 @@ func TestIntegrationUsers(t *testing.T) {
 
 	})
 
+	t.Run("create user twice with the same idempotency key should replay the first response for the same email only", func(t *testing.T) {
+		key := fmt.Sprintf("create-user-%v", time.Now().UnixNano())
+		post := func(requestDTO user.CreateUserRequestDTO) (*http.Response, user.CreateUserResponseDTO) {
+			marshalled, err := json.Marshal(&requestDTO)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/user", bytes.NewBuffer(marshalled))
+			require.NoError(t, err)
+			req.Header.Set("Idempotency-Key", key)
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			bytesReaded, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			var responseDTO user.CreateUserResponseDTO
+			if res.StatusCode == http.StatusOK {
+				require.NoError(t, json.Unmarshal(bytesReaded, &responseDTO))
+			}
+			return res, responseDTO
+		}
+
+		first, firstDTO := post(user.CreateUserRequestDTO{UserName: "username", Email: "email", Password: "password"})
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: firstDTO.UserID})
+			require.NoError(t, err)
+		}()
+
+		retry, retryDTO := post(user.CreateUserRequestDTO{UserName: "username", Email: "email", Password: "password"})
+		require.Equal(t, http.StatusOK, retry.StatusCode)
+		require.Equal(t, "true", retry.Header.Get("Idempotent-Replayed"))
+		require.Equal(t, firstDTO.UserID, retryDTO.UserID)
+
+		mismatched, _ := post(user.CreateUserRequestDTO{UserName: "username", Email: " EMAIL ", Password: "password"})
+		require.Equal(t, http.StatusUnprocessableEntity, mismatched.StatusCode)
+
+		other, otherDTO := post(user.CreateUserRequestDTO{UserName: "username2", Email: "email2", Password: "password"})
+		require.Equal(t, http.StatusOK, other.StatusCode)
+		require.Empty(t, other.Header.Get("Idempotent-Replayed"))
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: otherDTO.UserID})
+			require.NoError(t, err)
+		}()
+		require.NotEqual(t, firstDTO.UserID, otherDTO.UserID)
+	})
+
 	t.Run("create user with an used email or username should return bad request", func(t *testing.T) {
 		u, err := us.Create(context.Background(), &user.CreateUserCommand{
 			UserName: "username",
@@ -130,4 +177,104 @@ func TestIntegrationUsers(t *testing.T) {
 
 	})
 
+	t.Run("refresh a token should rotate it and revoke the session when an old one is reused", func(t *testing.T) {
+		u, err := us.Create(context.Background(), &user.CreateUserCommand{
+			UserName: "username",
+			Email:    "email",
+			Password: "password",
+		})
+		require.NoError(t, err)
+		defer func() {
+			_, err = us.Delete(context.Background(), &user.DeleteUserCommand{UserID: u.UserID})
+			require.NoError(t, err)
+		}()
+
+		login := loginHelper(t, "email", "password")
+
+		res, refreshed := refreshHelper(t, login.RefreshToken)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+		require.Equal(t, u.UserID, refreshed.UserID)
+		require.Equal(t, http.StatusOK, authorizedGetHelper(t, "http://localhost:8080/users/me/todos", refreshed.Token).StatusCode)
+
+		res, _ = refreshHelper(t, login.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		require.Equal(t, http.StatusUnauthorized, authorizedGetHelper(t, "http://localhost:8080/users/me/todos", refreshed.Token).StatusCode)
+		res, _ = refreshHelper(t, refreshed.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("logout should revoke the tokens of the session only", func(t *testing.T) {
+		u, err := us.Create(context.Background(), &user.CreateUserCommand{
+			UserName: "username",
+			Email:    "email",
+			Password: "password",
+		})
+		require.NoError(t, err)
+		defer func() {
+			_, err = us.Delete(context.Background(), &user.DeleteUserCommand{UserID: u.UserID})
+			require.NoError(t, err)
+		}()
+
+		login := loginHelper(t, "email", "password")
+		other := loginHelper(t, "email", "password")
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/user/logout", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		require.Equal(t, http.StatusUnauthorized, authorizedGetHelper(t, "http://localhost:8080/users/me/todos", login.Token).StatusCode)
+		res, _ = refreshHelper(t, login.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		require.Equal(t, http.StatusOK, authorizedGetHelper(t, "http://localhost:8080/users/me/todos", other.Token).StatusCode)
+	})
+
+	t.Run("get the public keys in local auth mode should return not found", func(t *testing.T) {
+		res, err := http.Get("http://localhost:8080/.well-known/paseto-keys")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+func loginHelper(t *testing.T, email string, password string) user.LoginUserResponseDTO {
+	marshalled, err := json.Marshal(&user.LoginUserRequestDTO{UserEmail: email, UserPassword: password})
+	require.NoError(t, err)
+
+	res, err := http.Post("http://localhost:8080/user/login", "application/json", bytes.NewBuffer(marshalled))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	bytesReaded, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	var responseDTO user.LoginUserResponseDTO
+	err = json.Unmarshal(bytesReaded, &responseDTO)
+	require.NoError(t, err)
+	return responseDTO
+}
+
+func refreshHelper(t *testing.T, refreshToken string) (*http.Response, user.LoginUserResponseDTO) {
+	marshalled, err := json.Marshal(&user.RefreshTokenRequestDTO{RefreshToken: refreshToken})
+	require.NoError(t, err)
+
+	res, err := http.Post("http://localhost:8080/user/token/refresh", "application/json", bytes.NewBuffer(marshalled))
+	require.NoError(t, err)
+	bytesReaded, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	var responseDTO user.LoginUserResponseDTO
+	json.Unmarshal(bytesReaded, &responseDTO)
+	return res, responseDTO
+}
+
+func authorizedGetHelper(t *testing.T, url string, token string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return res
 }
@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"kuberneteslab/todoapp/pkg/todo"
+	"kuberneteslab/todoapp/pkg/user"
+	"kuberneteslab/todoapp/pkg/webhook"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type receivedDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+func TestIntegrationWebhooks(t *testing.T) {
+
+	t.Run("create a todo with a webhook should post a signed delivery and log it", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		received := make(chan receivedDelivery, 4)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- receivedDelivery{header: r.Header, body: body}
+		}))
+		defer receiver.Close()
+
+		created := createWebhookHelper(t, token, webhook.CreateWebhookRequestDTO{URL: receiver.URL, Secret: "0123456789abcdef", Events: []string{webhook.EventCreated}})
+		require.Equal(t, "0123456789abcdef", created.Secret)
+		require.Equal(t, []string{webhook.EventCreated}, created.Events)
+
+		todoDTO := createTodoHelper(t, userID, token)
+
+		var delivery receivedDelivery
+		select {
+		case delivery = <-received:
+		case <-time.After(10 * time.Second):
+			t.Fatal("no delivery received")
+		}
+		require.Equal(t, webhook.EventCreated, delivery.header.Get(webhook.EventHeader))
+
+		signature := delivery.header.Get(webhook.SignatureHeader)
+		timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, webhook.Sign("0123456789abcdef", time.Unix(timestamp, 0), delivery.body), signature)
+
+		var payload map[string]interface{}
+		err = json.Unmarshal(delivery.body, &payload)
+		require.NoError(t, err)
+		require.Equal(t, webhook.EventCreated, payload["event"])
+		require.Equal(t, float64(todoDTO.TodoID), payload["todo_id"])
+		require.Equal(t, "title1", payload["todo"].(map[string]interface{})["title"])
+
+		deliveries := getDeliveriesHelper(t, token, created.WebhookID)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, webhook.StatusSucceeded, deliveries[0].Status)
+		require.Equal(t, 1, deliveries[0].Attempts)
+		require.Equal(t, http.StatusOK, *deliveries[0].LastStatusCode)
+		require.Equal(t, delivery.header.Get(webhook.DeliveryHeader), fmt.Sprint(deliveries[0].DeliveryID))
+
+		url := fmt.Sprintf("http://localhost:8080/webhooks/%v/deliveries/%v/redeliver", created.WebhookID, deliveries[0].DeliveryID)
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+		select {
+		case redelivered := <-received:
+			require.Equal(t, delivery.body, redelivered.body)
+			require.NotEqual(t, delivery.header.Get(webhook.DeliveryHeader), redelivered.header.Get(webhook.DeliveryHeader))
+		case <-time.After(10 * time.Second):
+			t.Fatal("no redelivery received")
+		}
+	})
+
+	t.Run("delivery to a failing receiver should be scheduled for a retry", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		attempted := make(chan struct{}, 4)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			attempted <- struct{}{}
+		}))
+		defer receiver.Close()
+
+		created := createWebhookHelper(t, token, webhook.CreateWebhookRequestDTO{URL: receiver.URL})
+		require.Len(t, created.Events, len(webhook.AllEvents))
+		require.GreaterOrEqual(t, len(created.Secret), webhook.MinSecretLength)
+
+		createTodoHelper(t, userID, token)
+
+		select {
+		case <-attempted:
+		case <-time.After(10 * time.Second):
+			t.Fatal("no delivery attempted")
+		}
+
+		var deliveries []webhook.ResponseDeliveryDTO
+		require.Eventually(t, func() bool {
+			deliveries = getDeliveriesHelper(t, token, created.WebhookID)
+			return len(deliveries) == 1 && deliveries[0].Attempts == 1
+		}, 5*time.Second, 100*time.Millisecond)
+		require.Equal(t, webhook.StatusPending, deliveries[0].Status)
+		require.Equal(t, http.StatusInternalServerError, *deliveries[0].LastStatusCode)
+		require.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+	})
+
+	t.Run("create a webhook with an invalid url should return bad request", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		marshalled, err := json.Marshal(&webhook.CreateWebhookRequestDTO{URL: "ftp://example.com"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/webhooks", bytes.NewBuffer(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("get the deliveries of a webhook of another user should return not found", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		otherID, otherToken := credentialsHelperWith(t, "username2", "email2")
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+			_, err = us.Delete(context.Background(), &user.DeleteUserCommand{UserID: otherID})
+			require.NoError(t, err)
+		}()
+
+		created := createWebhookHelper(t, token, webhook.CreateWebhookRequestDTO{URL: "http://example.com/hook"})
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/webhooks/%v/deliveries", created.WebhookID), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherToken))
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+}
+
+func TestIntegrationWebhookDispatch(t *testing.T) {
+
+	t.Run("dispatch from two dispatchers to a slow receiver should send each delivery once", func(t *testing.T) {
+		userID, token := credentialsHelper(t)
+		defer func() {
+			_, err := us.Delete(context.Background(), &user.DeleteUserCommand{UserID: userID})
+			require.NoError(t, err)
+		}()
+
+		var mu sync.Mutex
+		sent := map[string]int{}
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			sent[r.Header.Get(webhook.DeliveryHeader)]++
+			mu.Unlock()
+			time.Sleep(500 * time.Millisecond)
+		}))
+		defer receiver.Close()
+
+		created := createWebhookHelper(t, token, webhook.CreateWebhookRequestDTO{URL: receiver.URL, Events: []string{webhook.EventCreated}})
+		const todos = 6
+		for i := 0; i < todos; i++ {
+			_, err := ts.Create(context.Background(), &todo.CreateTodoCommand{UserID: userID, Title: fmt.Sprint("todo ", i)})
+			require.NoError(t, err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := whs.DeliverDue(context.Background(), todos)
+				require.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		require.Eventually(t, func() bool {
+			deliveries := getDeliveriesHelper(t, token, created.WebhookID)
+			for _, delivery := range deliveries {
+				if delivery.Status != webhook.StatusSucceeded {
+					return false
+				}
+			}
+			return len(deliveries) == todos
+		}, 10*time.Second, 100*time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, sent, todos)
+		for id, count := range sent {
+			require.Equal(t, 1, count, "delivery %v was sent %v times", id, count)
+		}
+	})
+}
+
+func createWebhookHelper(t *testing.T, token string, dto webhook.CreateWebhookRequestDTO) webhook.CreateWebhookResponseDTO {
+
+	marshalled, err := json.Marshal(&dto)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/webhooks", bytes.NewBuffer(marshalled))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	response, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	bytesReaded, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	var responseDTO webhook.CreateWebhookResponseDTO
+	err = json.Unmarshal(bytesReaded, &responseDTO)
+	require.NoError(t, err)
+	return responseDTO
+}
+
+func getDeliveriesHelper(t *testing.T, token string, webhookID int) []webhook.ResponseDeliveryDTO {
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8080/webhooks/%v/deliveries", webhookID), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	response, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	bytesReaded, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	var responseDTO webhook.GetDeliveriesResponseDTO
+	err = json.Unmarshal(bytesReaded, &responseDTO)
+	require.NoError(t, err)
+	return responseDTO.Deliveries
+}